@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+// -attest writes an in-toto statement, with a SLSA provenance predicate, of
+// the vendoring step: what it read, the project's module files and the
+// modules with their go.sum hashes, and the digests of the files it wrote.
+// The statement is left unsigned, for CI to sign it with its own keys.
+
+const (
+	intotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	attestBuildType     = "https://github.com/ansoda/modvendor/copy/v1"
+	attestBuilderID     = "https://github.com/ansoda/modvendor"
+)
+
+type intotoStatement struct {
+	Type          string                `json:"_type"`
+	Subject       []*resourceDescriptor `json:"subject"`
+	PredicateType string                `json:"predicateType"`
+	Predicate     *slsaProvenance       `json:"predicate"`
+}
+
+// resourceDescriptor is an artifact of an attestation, digests are keyed by
+// the algorithm names of the in-toto digest set.
+type resourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition struct {
+		BuildType            string                 `json:"buildType"`
+		ExternalParameters   map[string]interface{} `json:"externalParameters"`
+		ResolvedDependencies []*resourceDescriptor  `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version,omitempty"`
+		} `json:"builder"`
+		Metadata struct {
+			FinishedOn string `json:"finishedOn,omitempty"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// writeAttestation writes the statement of the run recorded by manifest, in
+// the project in cwd, to path.
+func writeAttestation(path, cwd string, manifest *Manifest) error {
+	st, err := newAttestation(cwd, manifest)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func newAttestation(cwd string, manifest *Manifest) (*intotoStatement, error) {
+	st := &intotoStatement{Type: intotoStatementType, Subject: []*resourceDescriptor{}, PredicateType: slsaProvenanceType, Predicate: &slsaProvenance{}}
+	for _, mm := range manifest.Modules {
+		for _, f := range mm.Files {
+			a, sum := checksum.Split(f.Sum)
+			st.Subject = append(st.Subject, &resourceDescriptor{Name: outName(f.Path), Digest: map[string]string{string(a): sum}})
+		}
+		// Generated files aren't checksummed in the manifest.
+		for _, file := range mm.Generated {
+			sum, err := fileSum(outPath(cwd, file), checksum.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			st.Subject = append(st.Subject, &resourceDescriptor{Name: outName(file), Digest: map[string]string{"sha256": sum}})
+		}
+	}
+	sort.Slice(st.Subject, func(i, j int) bool {
+		return st.Subject[i].Name < st.Subject[j].Name
+	})
+
+	p := st.Predicate
+	p.BuildDefinition.BuildType = attestBuildType
+	p.BuildDefinition.ExternalParameters = map[string]interface{}{"patterns": manifest.Patterns, "out": outName("")}
+	deps, err := attestedProjectFiles(cwd)
+	if err != nil {
+		return nil, err
+	}
+	for _, mm := range manifest.Modules {
+		dep := &resourceDescriptor{URI: "pkg:golang/" + mm.Path + "@" + mm.Version}
+		if mm.GoSum != "" {
+			dep.Digest = map[string]string{"dirHash": mm.GoSum}
+		}
+		if mm.Replace != "" {
+			dep.Annotations = map[string]string{"replace": joinNonEmpty(mm.Replace, mm.ReplaceVersion)}
+		}
+		deps = append(deps, dep)
+	}
+	p.BuildDefinition.ResolvedDependencies = deps
+
+	v := currentVersion()
+	p.RunDetails.Builder.ID = attestBuilderID
+	p.RunDetails.Builder.Version = map[string]string{"modvendor": v.Version, "go": v.Go}
+	if !manifest.Generated.IsZero() {
+		p.RunDetails.Metadata.FinishedOn = manifest.Generated.UTC().Format(time.RFC3339)
+	}
+	return st, nil
+}
+
+// attestedProjectFiles returns the files of the project in cwd telling what
+// is vendored which exist, named relative to it.
+func attestedProjectFiles(cwd string) ([]*resourceDescriptor, error) {
+	files, err := moduleFiles(cwd)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(cwd, "vendor", "modules.txt"), filepath.Join(cwd, configName), filepath.Join(cwd, lockName))
+	var deps []*resourceDescriptor
+	for _, file := range files {
+		sum, err := fileSum(file, checksum.SHA256)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, err := filepath.Rel(cwd, file)
+		if err != nil {
+			name = file
+		}
+		deps = append(deps, &resourceDescriptor{Name: filepath.ToSlash(name), Digest: map[string]string{"sha256": sum}})
+	}
+	return deps, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"sort"
+	"time"
+)
+
+// auditName is the file, within the output directory, runs changing it
+// append a record of what they changed to, one JSON object per line, with
+// -audit.
+const auditName = ".modvendor-audit.jsonl"
+
+// auditRecord is a line of the audit log: who ran what, when, and the
+// files, relative to the output directory, it added, changed and removed.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Host    string    `json:"host,omitempty"`
+	Command string    `json:"command"`
+	Args    []string  `json:"args"`
+	Version string    `json:"version"`
+	Added   []string  `json:"added"`
+	Changed []string  `json:"changed"`
+	Removed []string  `json:"removed"`
+}
+
+// appendAudit appends the record of the command run with args, which
+// changed the output directory of the project in cwd from what previous
+// recorded to what current does, either may be nil, copying entries.
+func appendAudit(cwd, command string, args []string, previous, current *Manifest, entries []*vendorEntry) error {
+	r := &auditRecord{
+		Time:    time.Now().UTC().Truncate(time.Second),
+		User:    auditUser(),
+		Command: command,
+		Args:    append([]string{}, args...),
+		Version: currentVersion().Version,
+	}
+	r.Host, _ = os.Hostname()
+	r.Added, r.Changed, r.Removed = auditChanges(previous, current, entries)
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(outPath(cwd, auditName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// auditUser returns the name of the user running modvendor.
+func auditUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, v := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(v); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// auditChanges returns the files current records and previous doesn't,
+// those of entries copied again although previous records them, as their
+// content changed, and those only previous records, sorted.
+func auditChanges(previous, current *Manifest, entries []*vendorEntry) (added, changed, removed []string) {
+	before, after := manifestSums(previous), manifestSums(current)
+	added, changed, removed = []string{}, []string{}, []string{}
+	copied := map[string]bool{}
+	for _, e := range entries {
+		copied[e.Dest] = !e.Unchanged
+	}
+	for file := range after {
+		if _, ok := before[file]; !ok {
+			added = append(added, file)
+		} else if copied[file] {
+			changed = append(changed, file)
+		}
+	}
+	for file := range before {
+		if _, ok := after[file]; !ok {
+			removed = append(removed, file)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// manifestSums maps the files of m, nil for none, to their checksums.
+func manifestSums(m *Manifest) map[string]string {
+	sums := map[string]string{}
+	if m == nil {
+		return sums
+	}
+	for _, mm := range m.Modules {
+		for _, f := range mm.Files {
+			sums[f.Path] = f.Sum
+		}
+		for _, file := range mm.Generated {
+			sums[file] = ""
+		}
+	}
+	return sums
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupName is the directory, in the project root, -backup moves the
+// files a run replaces or removes to.
+const backupName = ".modvendor-backup"
+
+// backup collects the files a run replaces in a timestamped directory, laid
+// out as the output directory. A nil backup keeps nothing.
+type backup struct {
+	Dir   string
+	Files int
+}
+
+func newBackup(cwd string) *backup {
+	return &backup{Dir: filepath.Join(cwd, backupName, time.Now().Format("20060102-150405"))}
+}
+
+// save moves dest, relative to the output directory, into the backup.
+func (b *backup) save(cwd, dest string) error {
+	path := filepath.Join(b.Dir, filepath.FromSlash(dest))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(outPath(cwd, dest), path); err != nil {
+		return err
+	}
+	b.Files++
+	return nil
+}
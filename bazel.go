@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bazelBuildName is the file, within the output directory of each module,
+// generated by -bazel.
+const bazelBuildName = "BUILD.bazel"
+
+var (
+	bazelSrcExts = map[string]bool{".c": true, ".cc": true, ".cpp": true, ".cxx": true, ".s": true, ".S": true}
+	bazelHdrExts = map[string]bool{".h": true, ".hh": true, ".hpp": true, ".hxx": true, ".inc": true}
+)
+
+// writeBazelBuilds writes, for every module with assets among entries, a
+// BUILD.bazel into the module's output directory declaring them. A
+// BUILD.bazel modvendor didn't generate is left alone. It returns the
+// generated file per module path, relative to the output directory.
+func writeBazelBuilds(cwd string, modules []*Mod, entries []*vendorEntry) (map[string]string, error) {
+	byMod := map[*Mod][]string{}
+	for _, e := range entries {
+		byMod[e.Mod] = append(byMod[e.Mod], strings.TrimPrefix(e.Dest, e.Mod.ImportPath+"/"))
+	}
+
+	builds := map[string]string{}
+	for _, mod := range modules {
+		files := byMod[mod]
+		if len(files) == 0 {
+			continue
+		}
+		dest := path.Join(mod.ImportPath, bazelBuildName)
+		if data, err := os.ReadFile(outPath(cwd, dest)); err == nil && !bytes.HasPrefix(data, []byte(bazelHeader)) {
+			warn(outName(dest), "not overwriting %s, it wasn't generated by modvendor", outName(dest))
+			continue
+		}
+		sort.Strings(files)
+		if err := os.WriteFile(outPath(cwd, dest), bazelBuild(mod, files), 0644); err != nil {
+			return nil, err
+		}
+		builds[mod.ImportPath] = dest
+	}
+	return builds, nil
+}
+
+const bazelHeader = "# Code generated by modvendor; DO NOT EDIT.\n"
+
+// bazelBuild renders the BUILD.bazel of mod, files are slash separated and
+// relative to the module root.
+func bazelBuild(mod *Mod, files []string) []byte {
+	var srcs, hdrs []string
+	for _, f := range files {
+		switch ext := path.Ext(f); {
+		case bazelSrcExts[ext]:
+			srcs = append(srcs, f)
+		case bazelHdrExts[ext]:
+			hdrs = append(hdrs, f)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(bazelHeader)
+	fmt.Fprintf(&b, "# Assets modvendor vendored from %s.\n\n", mod)
+	b.WriteString("package(default_visibility = [\"//visibility:public\"])\n\n")
+	b.WriteString("filegroup(\n    name = \"modvendor_assets\",\n")
+	writeBazelList(&b, "srcs", files)
+	b.WriteString(")\n")
+	if len(srcs) > 0 || len(hdrs) > 0 {
+		b.WriteString("\ncc_library(\n    name = \"modvendor_cc\",\n")
+		writeBazelList(&b, "srcs", srcs)
+		writeBazelList(&b, "hdrs", hdrs)
+		// Sources include headers relative to the module root.
+		b.WriteString("    includes = [\".\"],\n")
+		b.WriteString(")\n")
+	}
+	return b.Bytes()
+}
+
+func writeBazelList(b *bytes.Buffer, attr string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "    %s = [\n", attr)
+	for _, v := range values {
+		fmt.Fprintf(b, "        %s,\n", strconv.Quote(v))
+	}
+	b.WriteString("    ],\n")
+}
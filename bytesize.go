@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag.Value of a size in bytes, with an optional K, M or G
+// suffix (powers of 1024).
+type byteSize int64
+
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(v string) error {
+	mult := int64(1)
+	switch u := strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(v, "B"), "i")); {
+	case strings.HasSuffix(u, "K"):
+		mult, v = 1<<10, u[:len(u)-1]
+	case strings.HasSuffix(u, "M"):
+		mult, v = 1<<20, u[:len(u)-1]
+	case strings.HasSuffix(u, "G"):
+		mult, v = 1<<30, u[:len(u)-1]
+	default:
+		v = u
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q, e.g. 512K or 20M", v)
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
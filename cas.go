@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/internal/copyer"
+)
+
+// contentStore holds the content of copied files by checksum, in the user
+// cache directory, so that every project, branch and worktree of the user
+// copies a given file from the module cache once. It is only a cache,
+// failing to read or write it is not an error.
+type contentStore struct {
+	Dir string
+}
+
+// store is the content store of the run, nil unless -cas is given.
+var store *contentStore
+
+// openContentStore returns the store of the user cache directory, or nil
+// if there is none.
+func openContentStore() *contentStore {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	return &contentStore{Dir: filepath.Join(dir, "modvendor", "cas")}
+}
+
+// object returns the path of the content with the checksum, those of other
+// algorithms than sha256 are kept apart.
+func (s *contentStore) object(sum string) string {
+	a, digest := checksum.Split(sum)
+	if a != checksum.SHA256 {
+		return filepath.Join(s.Dir, string(a), digest[:2], digest)
+	}
+	return filepath.Join(s.Dir, digest[:2], digest)
+}
+
+// materialize writes the content of the source of e to dst from the store,
+// where the checksum of the source is known and the store holds it. It
+// reports whether it did.
+func (s *contentStore) materialize(sources *sourceFiles, e *vendorEntry, dst string) bool {
+	if s == nil {
+		return false
+	}
+	sum, ok := cachedSum(sources, e)
+	if !ok || len(sum) < 2 {
+		return false
+	}
+	obj := s.object(sum)
+	info, err := os.Stat(obj)
+	if err != nil {
+		return false
+	}
+
+	// Vendored files get the mode and modification time of their source,
+	// not those of the object.
+	src, err := sources.stat(e)
+	if err != nil || info.Size() != src.Size() {
+		return false
+	}
+	mode, modTime := src.Mode(), src.ModTime()
+
+	copyer.Ops.Wait(1)
+	err = copyer.Clone(obj, dst)
+	if err != nil {
+		err = copyer.Regular(obj, dst, info, nil)
+	}
+	if err == nil {
+		err = os.Chmod(dst, mode.Perm()|0644)
+	}
+	if err == nil {
+		err = os.Chtimes(dst, modTime, modTime)
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return false
+	}
+	e.Sum = sum
+	return true
+}
+
+// add stores the copied file at path with the checksum, unless the
+// store holds it already.
+func (s *contentStore) add(path, sum string) {
+	if s == nil || len(sum) < 2 {
+		return
+	}
+	obj := s.object(sum)
+	if _, err := os.Stat(obj); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(obj), os.ModePerm); err != nil {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	// Objects appear whole, runs of other projects may be reading them.
+	tmp, err := os.CreateTemp(filepath.Dir(obj), filepath.Base(obj)+".tmp*")
+	if err != nil {
+		return
+	}
+	_ = tmp.Close()
+	err = copyer.Clone(path, tmp.Name())
+	if err != nil {
+		err = copyer.Regular(path, tmp.Name(), info, nil)
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0444)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), obj)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var applyCmd = &command{
+	Name:  "apply",
+	Short: "copy the files of a plan written by copy -write-plan",
+	Run:   runApply,
+}
+
+// runApply runs copy -plan, the plan file comes last for the copy flags to
+// come first.
+func runApply(args []string) error {
+	if len(args) == 0 || args[len(args)-1] == "-h" || args[len(args)-1] == "-help" {
+		fmt.Fprintf(os.Stderr, "Usage: modvendor apply [copy flags] plan.json\n\nApply copies the files of a plan written by modvendor copy -write-plan into\n./vendor/, as copy -plan does. It fails, writing nothing, when the modules of\nthe plan aren't those of vendor/modules.txt or the content of a file changed\nsince the plan was made. See modvendor copy -h for the flags.\n")
+		if len(args) == 0 {
+			return errUsage
+		}
+		return nil
+	}
+	plan := args[len(args)-1]
+	if strings.HasPrefix(plan, "-") {
+		return whoops("apply needs the plan file last, e.g. modvendor apply -v plan.json")
+	}
+	return runCopy(append(append([]string{}, args[:len(args)-1]...), "-plan", plan))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+var benchCmd = &command{
+	Name:  "bench",
+	Short: "measure how fast the patterns are globbed and copied, into a temporary directory",
+	Run:   runBench,
+}
+
+// benchRun is the outcome of globbing and copying once.
+type benchRun struct {
+	Files   int
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+func (r benchRun) String() string {
+	secs := r.Elapsed.Seconds()
+	return fmt.Sprintf("%d files, %s in %s (%.0f files/s, %s/s)", r.Files, formatSize(r.Bytes), r.Elapsed.Round(time.Millisecond),
+		float64(r.Files)/secs, formatSize(int64(float64(r.Bytes)/secs)))
+}
+
+func runBench(args []string) error {
+	flags := newFlagSet("bench", "[-n runs] [flags]",
+		"Bench globs the modules and copies the files copy would vendor with the given\nflags into a temporary directory, removed afterwards, n times. It reports the\nthroughput of each run and the median, to compare modvendor releases and\nmachines. ./vendor/ is left alone.")
+	runsFlag := flags.Int("n", 5, "number of runs")
+	dirFlag := flags.String("dir", "", "directory to copy into temporary directories of, the system temporary directory by default")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	_ = flags.Parse(args)
+	if *runsFlag < 1 {
+		return whoops("-n must be at least 1")
+	}
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+
+	var runs []benchRun
+	for i := 0; i < *runsFlag; i++ {
+		dest, err := os.MkdirTemp(*dirFlag, "modvendor-bench")
+		if err != nil {
+			return fmt.Errorf("%w - unable to create a temporary directory", err)
+		}
+
+		start := time.Now()
+		for _, mod := range modules {
+			mod.Timing = modTiming{}
+		}
+		entries, err := planCopy(modules, &opts)
+		if err == nil {
+			err = copyEntries(dest, entries, opts.verbose, opts.parallelism(), false)
+		}
+		if err != nil {
+			_ = os.RemoveAll(dest)
+			return err
+		}
+		run := benchRun{Files: len(entries), Elapsed: time.Since(start)}
+		for _, mod := range modules {
+			run.Bytes += mod.Timing.Bytes
+		}
+
+		if err := os.RemoveAll(dest); err != nil {
+			return fmt.Errorf("%w - unable to remove %s", err, dest)
+		}
+		fmt.Printf("run %d: %s\n", i+1, run)
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Elapsed < runs[j].Elapsed
+	})
+	fmt.Printf("median: %s\n", runs[len(runs)/2])
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+var cleanCmd = &command{
+	Name:  "clean",
+	Short: "remove the files modvendor copied from ./vendor/",
+	Run:   runClean,
+}
+
+func runClean(args []string) error {
+	flags := newFlagSet("clean", "[flags]",
+		"Clean removes every file recorded in ./vendor/"+manifestName+", directories left\nempty and the manifest itself. Files put in place by `go mod vendor` are kept.")
+	dryRunFlag := flags.Bool("n", false, "print the files that would be removed without removing them")
+	verboseFlag := flags.Bool("v", false, "verbose output")
+	waitFlag := flags.Duration("wait", 0, "how long to wait for another run writing to the output directory, instead of failing right away")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	auditFlag := flags.Bool("audit", cfg.Audit, "append who ran clean, when, and the files removed to ./vendor/"+auditName)
+	registerOutDir(flags, cfg)
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := loadManifest(cwd)
+	if err != nil {
+		return err
+	}
+	vendorDir := outPath(cwd, "")
+	if !*dryRunFlag {
+		unlock, err := lockOutDir(cwd, *waitFlag)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	for _, mm := range manifest.Modules {
+		files := mm.Files[:len(mm.Files):len(mm.Files)]
+		for _, path := range mm.Generated {
+			files = append(files, ManifestFile{Path: path})
+		}
+		for _, f := range files {
+			if *dryRunFlag || *verboseFlag {
+				fmt.Printf("removing %s\n", outName(f.Path))
+			}
+			if *dryRunFlag {
+				continue
+			}
+			path := outPath(cwd, f.Path)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("%w - unable to remove %s", err, outName(f.Path))
+			}
+			removeEmptyDirs(vendorDir, filepath.Dir(path))
+		}
+	}
+
+	if *dryRunFlag {
+		return nil
+	}
+	// Runs before checksums were written have none.
+	for _, a := range checksum.Algorithms {
+		if err := os.Remove(outPath(cwd, sumsName(a))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("%w - unable to remove %s", err, outName(sumsName(a)))
+		}
+	}
+	if err := os.Remove(manifestPath(cwd)); err != nil {
+		return fmt.Errorf("%w - unable to remove %s", err, outName(manifestName))
+	}
+	if *auditFlag {
+		if err := appendAudit(cwd, "clean", args, manifest, nil, nil); err != nil {
+			return fmt.Errorf("%w - unable to append to %s", err, outName(auditName))
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents up to, but excluding, root for
+// as long as they are empty.
+func removeEmptyDirs(root, dir string) {
+	for len(dir) > len(root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
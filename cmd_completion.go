@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var completionCmd = &command{
+	Name:  "completion",
+	Short: "print a bash, zsh, fish or powershell completion script",
+}
+
+// runCompletion walks the commands, which refer to it.
+func init() {
+	completionCmd.Run = runCompletion
+}
+
+// completionFlag is a flag of a command as listed by "modvendor <cmd> -h".
+type completionFlag struct {
+	Name  string
+	Usage string
+}
+
+// moduleFlags take module paths from ./vendor/modules.txt as argument.
+var moduleFlags = []string{"only", "skip"}
+
+func runCompletion(args []string) error {
+	flags := newFlagSet("completion", "bash|zsh|fish|powershell",
+		"Completion prints a script completing modvendor commands and flags, module\npaths of -only and -skip are looked up from ./vendor/modules.txt of the\nproject at completion time. For example, for bash:\n\n\tsource <(modvendor completion bash)")
+	modulesFlag := flags.Bool("modules", false, "print the module paths of ./vendor/modules.txt, as used by the completion scripts")
+	_ = flags.Parse(args)
+
+	if *modulesFlag {
+		printCompletionModules()
+		return nil
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	generate, ok := map[string]func(map[string][]completionFlag) string{
+		"bash":       bashCompletion,
+		"zsh":        zshCompletion,
+		"fish":       fishCompletion,
+		"powershell": powershellCompletion,
+	}[flags.Arg(0)]
+	if !ok {
+		return whoops("unsupported shell %q, use bash, zsh, fish or powershell", flags.Arg(0))
+	}
+
+	cmdFlags, err := commandFlags()
+	if err != nil {
+		return fmt.Errorf("%w - unable to list command flags", err)
+	}
+	fmt.Print(generate(cmdFlags))
+	return nil
+}
+
+// printCompletionModules prints nothing outside of a vendored project, a
+// completion script has nowhere to report errors.
+func printCompletionModules() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "vendor", "modules.txt")); err != nil {
+		return
+	}
+	modules, err := readModulesTxt(cwd)
+	if err != nil {
+		return
+	}
+	for _, mod := range modules {
+		fmt.Println(mod.ImportPath)
+	}
+}
+
+// commandFlags lists the flags of every command from its usage message, flag
+// sets only exist while a command runs.
+func commandFlags() (map[string][]completionFlag, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	all := map[string][]completionFlag{}
+	for _, cmd := range commands {
+		// -h exits zero once the usage is printed.
+		out, err := exec.Command(exe, cmd.Name, "-h").CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("%s -h: %v", cmd.Name, err)
+		}
+		all[cmd.Name] = parseFlagUsage(string(out))
+	}
+	return all, nil
+}
+
+// parseFlagUsage parses the flag.PrintDefaults part of a usage message, the
+// first line of each usage is kept.
+func parseFlagUsage(usage string) []completionFlag {
+	var flags []completionFlag
+	scanner := bufio.NewScanner(strings.NewReader(usage))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "  -"):
+			name := strings.Fields(line)[0][1:]
+			flags = append(flags, completionFlag{Name: name})
+			// Flags without a usage type fit it on the same line.
+			if i := strings.Index(line, "\t"); i >= 0 {
+				flags[len(flags)-1].Usage = strings.TrimSpace(line[i+1:])
+			}
+		case strings.HasPrefix(line, "    \t") && len(flags) > 0 && flags[len(flags)-1].Usage == "":
+			flags[len(flags)-1].Usage = strings.TrimSpace(line)
+		}
+	}
+	return flags
+}
+
+func completionCommands() []string {
+	names := []string{"help"}
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+	return names
+}
+
+func flagNames(flags []completionFlag) string {
+	var names []string
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// quote single quotes s for the shell, esc is what a single quote within s
+// turns into.
+func quote(s, esc string) string {
+	return "'" + strings.ReplaceAll(s, "'", esc) + "'"
+}
+
+func bashCompletion(cmdFlags map[string][]completionFlag) string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for modvendor, generated by "modvendor completion bash".
+
+_modvendor() {
+	local cur prev cmd
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	cmd="${COMP_WORDS[1]}"
+	# -flag=value is split at the "=".
+	if [[ "$prev" == "=" ]]; then
+		prev="${COMP_WORDS[COMP_CWORD-2]}"
+	fi
+
+	case "$prev" in
+	`)
+	b.WriteString("-" + strings.Join(moduleFlags, "|-"))
+	b.WriteString(`)
+		local prefix=""
+		if [[ "$cur" == *,* ]]; then
+			prefix="${cur%,*},"
+		fi
+		COMPREPLY=($(compgen -P "$prefix" -W "$(modvendor completion -modules 2>/dev/null)" -- "${cur##*,}"))
+		return
+		;;
+	esac
+
+	if [[ $COMP_CWORD -eq 1 && "$cur" != -* ]]; then
+		COMPREPLY=($(compgen -W "`)
+	b.WriteString(strings.Join(completionCommands(), " "))
+	b.WriteString(`" -- "$cur"))
+		return
+	fi
+
+	case "$cmd" in
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n\t\t;;\n", cmd.Name, quote(flagNames(cmdFlags[cmd.Name]), `'\''`))
+	}
+	fmt.Fprintf(&b, "\t-*)\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n\t\t;;\n", quote(flagNames(cmdFlags[copyCmd.Name]), `'\''`))
+	b.WriteString(`	esac
+}
+
+complete -o default -F _modvendor modvendor
+`)
+	return b.String()
+}
+
+func zshCompletion(cmdFlags map[string][]completionFlag) string {
+	var b strings.Builder
+	b.WriteString(`#compdef modvendor
+# zsh completion for modvendor, generated by "modvendor completion zsh".
+
+_modvendor() {
+	local cmd=$words[2]
+	local -a cmds flags
+
+	case $words[CURRENT-1] in
+	`)
+	b.WriteString("-" + strings.Join(moduleFlags, "|-"))
+	b.WriteString(`)
+		_values -s , 'module' ${(f)"$(modvendor completion -modules 2>/dev/null)"}
+		return
+		;;
+	esac
+
+	if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then
+		cmds=(
+			'help:print the usage of a command'
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t\t\t%s\n", quote(cmd.Name+":"+cmd.Short, `'\''`))
+	}
+	b.WriteString(`		)
+		_describe 'command' cmds
+		return
+	fi
+
+	[[ $cmd == -* ]] && cmd=copy
+	case $cmd in
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t%s)\n\t\tflags=(\n", cmd.Name)
+		for _, f := range cmdFlags[cmd.Name] {
+			fmt.Fprintf(&b, "\t\t\t%s\n", quote("-"+f.Name+":"+f.Usage, `'\''`))
+		}
+		b.WriteString("\t\t)\n\t\t;;\n")
+	}
+	b.WriteString(`	esac
+	_describe 'flag' flags || _files
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+	_modvendor "$@"
+else
+	compdef _modvendor modvendor
+fi
+`)
+	return b.String()
+}
+
+func fishCompletion(cmdFlags map[string][]completionFlag) string {
+	esc := `\'`
+	var b strings.Builder
+	b.WriteString(`# fish completion for modvendor, generated by "modvendor completion fish".
+
+complete -c modvendor -n __fish_use_subcommand -a help -d 'print the usage of a command'
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c modvendor -f -n __fish_use_subcommand -a %s -d %s\n", cmd.Name, quote(cmd.Short, esc))
+	}
+	b.WriteString("\n# Flags without a command run copy.\n")
+	for _, f := range cmdFlags[copyCmd.Name] {
+		fmt.Fprintf(&b, "complete -c modvendor -n __fish_use_subcommand -o %s -d %s\n", f.Name, quote(f.Usage, esc))
+	}
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\n")
+		for _, f := range cmdFlags[cmd.Name] {
+			fmt.Fprintf(&b, "complete -c modvendor -n '__fish_seen_subcommand_from %s' -o %s -d %s\n", cmd.Name, f.Name, quote(f.Usage, esc))
+		}
+	}
+	b.WriteString("\n")
+	for _, name := range moduleFlags {
+		fmt.Fprintf(&b, "complete -c modvendor -o %s -x -a '(modvendor completion -modules 2>/dev/null)'\n", name)
+	}
+	return b.String()
+}
+
+func powershellCompletion(cmdFlags map[string][]completionFlag) string {
+	esc := `''`
+	var b strings.Builder
+	b.WriteString(`# powershell completion for modvendor, generated by "modvendor completion powershell".
+
+Register-ArgumentCompleter -Native -CommandName modvendor -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+
+	$commands = @{
+		'help' = 'print the usage of a command'
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t\t%s = %s\n", quote(cmd.Name, esc), quote(cmd.Short, esc))
+	}
+	b.WriteString("\t}\n\t$flags = @{\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\t\t%s = @(\n", quote(cmd.Name, esc))
+		for _, f := range cmdFlags[cmd.Name] {
+			fmt.Fprintf(&b, "\t\t\t,@(%s, %s)\n", quote("-"+f.Name, esc), quote(f.Usage, esc))
+		}
+		b.WriteString("\t\t)\n")
+	}
+	b.WriteString("\t}\n\t$moduleFlags = @(")
+	for i, name := range moduleFlags {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(quote("-"+name, esc))
+	}
+	b.WriteString(`)
+
+	$words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+	if ($wordToComplete -ne '') {
+		$words = @($words | Select-Object -SkipLast 1)
+	}
+	$prev = if ($words.Count -gt 0) { $words[-1] } else { '' }
+
+	if ($moduleFlags -contains $prev) {
+		$prefix = ''
+		$partial = $wordToComplete
+		if ($wordToComplete.Contains(',')) {
+			$prefix = $wordToComplete.Substring(0, $wordToComplete.LastIndexOf(',') + 1)
+			$partial = $wordToComplete.Substring($prefix.Length)
+		}
+		modvendor completion -modules 2>$null | Where-Object { $_ -like "$partial*" } | ForEach-Object {
+			[System.Management.Automation.CompletionResult]::new("$prefix$_", $_, 'ParameterValue', $_)
+		}
+		return
+	}
+
+	if ($words.Count -eq 0 -and -not $wordToComplete.StartsWith('-')) {
+		$commands.GetEnumerator() | Where-Object { $_.Key -like "$wordToComplete*" } | Sort-Object Key | ForEach-Object {
+			[System.Management.Automation.CompletionResult]::new($_.Key, $_.Key, 'Command', $_.Value)
+		}
+		return
+	}
+
+	$cmd = if ($words.Count -gt 0 -and -not $words[0].StartsWith('-')) { $words[0] } else { 'copy' }
+	foreach ($f in $flags[$cmd]) {
+		if ($f[0] -like "$wordToComplete*") {
+			[System.Management.Automation.CompletionResult]::new($f[0], $f[0], 'ParameterName', $f[1])
+		}
+	}
+}
+`)
+	return b.String()
+}
@@ -0,0 +1,1056 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/internal/copyer"
+	"github.com/ansoda/modvendor/vendoring"
+	"golang.org/x/sync/errgroup"
+)
+
+var copyCmd = &command{
+	Name:  "copy",
+	Short: "copy files matching the patterns into ./vendor/ (default)",
+	Run:   runCopy,
+}
+
+func runCopy(args []string) error {
+	flags := newFlagSet("copy", "[flags]",
+		"Copy copies the files of vendored modules matching the -copy patterns into\n./vendor/ and records them in ./vendor/"+manifestName+".")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	verifyFlag := flags.Bool("verify", false, "verify module cache content against go.sum after copying")
+	retractFlag := flags.Bool("retracted", false, "warn when vendoring from retracted module versions (may require network access)")
+	checkFlag := flags.Bool("check", false, "exit non-zero, without writing anything, when ./vendor/ is out of date and print the offending files")
+	embedFlag := flags.Bool("embed", false, "generate a package per module embedding its non-Go assets, requires -o outside of ./vendor/")
+	depfileFlag := flags.String("depfile", "", "write a Make/Ninja depfile listing the module cache files vendored to the given path")
+	bazelFlag := flags.Bool("bazel", false, "generate a BUILD.bazel per module with filegroup and cc_library targets for its assets")
+	lockedFlag := flags.Bool("locked", false, "fail, without writing anything, when the plan drifts from ./"+lockName)
+	backupFlag := flags.Bool("backup", false, "move vendored files about to be overwritten or removed to ./"+backupName+"/<timestamp>/")
+	waitFlag := flags.Duration("wait", 0, "how long to wait for another run writing to the output directory, instead of failing right away")
+	timingsFlag := flags.Bool("timings", false, "print the files, bytes and time of each module once done, slowest first")
+	jsonFlag := flags.String("json", "", "write the -timings summary as JSON to the given file")
+	var bwLimit byteSize
+	flags.Var(&bwLimit, "bwlimit", "limit copying to the given bytes per second, e.g. 20M, to go easy on shared disks")
+	flags.Var(&progressSize, "progress-size", "report the progress of copying files from this size on, and resume them when interrupted")
+	deltaFlag := flags.Bool("delta", false, "update large vendored files in place, rewriting only the blocks that changed")
+	casFlag := flags.Bool("cas", false, "keep copied files in a store of the user cache directory, shared by every project and worktree, and copy them from there")
+	iopsFlag := flags.Int64("iops-limit", 0, "limit copying to the given I/O operations per second, every file created and buffer read counts as one")
+	dedupeFlag := flags.Bool("dedupe", false, "hardlink vendored files with identical content to each other to save space")
+	flags.Bool("force", false, "copy even when nothing changed since the last run") // see skippable
+	interactiveFlag := flags.Bool("interactive", false, "show the changes to ./vendor/ grouped by module and ask for confirmation before writing")
+	keepGoingFlag := flags.Bool("keep-going", false, "write the files that copied when others fail to, leaving those out of ./vendor/ and the manifest, and exit non-zero listing them")
+	attestFlag := flags.String("attest", "", "write an in-toto statement with SLSA provenance of the run, from the module files and go.sum hashes to the digests of the files written, to the given file for CI to sign")
+	archiveFlag := flags.String("archive", "", "write the files to the given .tar.gz, .tgz, .tar or .zip archive instead of ./vendor/, named as they would be vendored")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	writePlanFlag := flags.String("write-plan", "", "write what copy would vendor, with the checksums of the files, to the given JSON file instead of copying, see modvendor apply")
+	planFlag := flags.String("plan", "", "copy the files of a plan written by -write-plan instead of matching the -copy patterns, failing if any of them changed since")
+	preHookFlag := flags.String("pre-hook", cfg.PreHook, "shell command to run in the project root before planning the copy, e.g. to regenerate files of a local replace")
+	postHookFlag := flags.String("post-hook", cfg.PostHook, "shell command to run in the project root once files were copied, with the plan in $MODVENDOR_PLAN")
+	scanFlag := flags.String("scan", cfg.Scan, "shell command to run in the staging directory over the files copied, e.g. a virus or vulnerability scanner, a failing scan leaves ./vendor/ as it was")
+	auditFlag := flags.Bool("audit", cfg.Audit, "append who ran copy, when, with which flags, and the files added, changed and removed to ./vendor/"+auditName)
+	quarantineFlag := flags.String("quarantine", cfg.Quarantine, "write the files failing -safe, licenses:, -secrets fail, -max-size or -scan to the given directory, with a report, instead of failing the run")
+	secretsFlag := flags.String("secrets", cfg.Secrets, "screen the text files to vendor for secrets such as AWS keys and private keys: warn of them, or fail writing nothing")
+	reproducibleFlag := flags.Bool("reproducible", cfg.Reproducible, "normalize the modes and modification times of the files, to $SOURCE_DATE_EPOCH if set, and record no machine paths nor times in the manifest")
+	containerFlag := flags.Bool("container", cfg.Container, "give the files mode 0644, or 0755 if executable, and their directories 0755, without setuid, setgid or sticky bits, for container builds")
+	chownFlag := flags.String("chown", cfg.Chown, "give the files and their directories the numeric owner uid:gid, e.g. to map them to the user of a rootless container build")
+	_ = flags.Parse(args)
+
+	if *secretsFlag != "" && !containsString(secretPolicies, *secretsFlag) {
+		return whoops("invalid -secrets %q, use %s", *secretsFlag, strings.Join(secretPolicies, " or "))
+	}
+	var chown *owner
+	if *chownFlag != "" {
+		if runtime.GOOS == "windows" {
+			return whoops("-chown isn't supported on windows")
+		}
+		if chown, err = parseOwner(*chownFlag); err != nil {
+			return err
+		}
+	}
+	var modTime time.Time
+	if *reproducibleFlag {
+		if modTime, err = reproducibleTime(); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	opts.quarantine = *quarantineFlag
+	q, err := newQuarantine(cwd, *quarantineFlag, cfg.AllowFiles)
+	if err != nil {
+		return err
+	}
+	handleInterrupts()
+	copyer.Bytes, copyer.Ops = copyer.NewThrottle(int64(bwLimit)), copyer.NewThrottle(*iopsFlag)
+	if *casFlag {
+		store = openContentStore()
+	}
+
+	// Prepare vendor copy patterns
+	copyPat := opts.patterns()
+	var planned *PlanFile
+	if *planFlag != "" {
+		if *writePlanFlag != "" {
+			return whoops("-plan can't be combined with -write-plan")
+		}
+		if planned, err = readPlanFile(*planFlag); err != nil {
+			return fmt.Errorf("%w - unable to read the plan", err)
+		}
+		copyPat = planned.Patterns
+	}
+	if len(copyPat) == 0 {
+		return whoops("-copy argument is empty, nothing to copy.")
+	}
+
+	// Packages in ./vendor/ are those of modules.txt, no others build.
+	if *embedFlag && outName("") == "vendor" {
+		return whoops("-embed requires -o outside of ./vendor/, e.g. -o third_party")
+	}
+
+	if *archiveFlag != "" && vendoring.ArchiveDestination(*archiveFlag, io.Discard) == nil {
+		return whoops("unknown archive format of %s, use .tar.gz, .tgz, .tar or .zip", *archiveFlag)
+	}
+
+	// The hook may write anything, -check writes nothing: that of the
+	// configuration isn't run, one passed is refused.
+	if *checkFlag {
+		passed := false
+		flags.Visit(func(f *flag.Flag) {
+			passed = passed || f.Name == "pre-hook"
+		})
+		if passed {
+			return whoops("-check can't be combined with -pre-hook, which may write files")
+		}
+		*preHookFlag = ""
+	}
+	if *attestFlag != "" && (*checkFlag || *archiveFlag != "" || *writePlanFlag != "") {
+		return whoops("-attest can't be combined with -check, -archive or -write-plan, which leave %s/ as it is", outName(""))
+	}
+	// Neither stages the files it writes.
+	if *scanFlag != "" && (*archiveFlag != "" || *deltaFlag) {
+		return whoops("-scan can't be combined with -archive or -delta, which write files without staging them")
+	}
+	if *retractFlag && offline {
+		return whoops("-retracted can't be combined with -offline, it asks the go command which may download")
+	}
+
+	// Updating in place would change the hardlinked copies, or those to
+	// be backed up, as well.
+	if *deltaFlag && (*dedupeFlag || *backupFlag) {
+		return whoops("-delta can't be combined with -dedupe or -backup")
+	}
+
+	// What the hook generates is planned, and fingerprinted, like the rest.
+	if *preHookFlag != "" {
+		if err := runHook(cwd, *preHookFlag, nil); err != nil {
+			if interrupted() {
+				return errInterrupted("nothing written")
+			}
+			return fmt.Errorf("%w - pre-hook %q failed", err, *preHookFlag)
+		}
+	}
+
+	// Running on every build costs next to nothing while nothing changed.
+	// Runs verifying the module cache don't record a state to skip them, nor
+	// those with plugins, which may decide otherwise from one run to the next.
+	state := ""
+	if !*verifyFlag && planned == nil && len(opts.plugins) == 0 {
+		state = copyState(cwd, flags, modTime)
+	}
+	previous, err := previousManifest(cwd)
+	if err != nil {
+		return err
+	}
+	if skippable(flags) && upToDate(cwd, previous, state) {
+		fmt.Printf("%s/ is up to date\n", outName(""))
+		if *attestFlag != "" {
+			if err := writeAttestation(*attestFlag, cwd, previous); err != nil {
+				return fmt.Errorf("%w - unable to write %s", err, *attestFlag)
+			}
+		}
+		if *depfileFlag != "" {
+			if err := writeDepfile(*depfileFlag, cwd, manifestDeps(previous)); err != nil {
+				return fmt.Errorf("%w - unable to write %s", err, *depfileFlag)
+			}
+		}
+		return nil
+	}
+
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	if planned != nil {
+		err = planned.apply(modules)
+	} else {
+		err = buildVendorLists(modules, &opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Authors retract releases for a reason, let the user know.
+	if *retractFlag {
+		if err := warnRetracted(modules); err != nil {
+			return err
+		}
+	}
+
+	// Local replaces may not correspond to any reproducible revision.
+	for _, mod := range modules {
+		if mod.VCS == nil || len(mod.VendorList) == 0 {
+			continue
+		}
+		if mod.VCS.System == "" {
+			warn("go.mod", "%s is replaced by %s which has no version control information, vendored files may not be reproducible", mod.ImportPath, mod.SourcePath)
+		} else if mod.VCS.Dirty {
+			warn("go.mod", "%s is replaced by %s which has uncommitted changes, vendored files may not be reproducible", mod.ImportPath, mod.SourcePath)
+		}
+	}
+
+	entries, err := planVendorEntries(modules)
+	if err != nil {
+		return err
+	}
+
+	if err := checkLock(cwd, &opts, modules, entries, *lockedFlag); err != nil {
+		return err
+	}
+	if exts := opts.deniedTypes(); len(exts) > 0 {
+		if err := checkDeniedTypes(exts, entries, q); err != nil {
+			return err
+		}
+	}
+	if opts.licenses != nil {
+		if err := checkLicenses(opts.licenses, entries, opts.verbose, q); err != nil {
+			return err
+		}
+	}
+	if *secretsFlag != "" {
+		if err := checkSecrets(*secretsFlag, entries, q); err != nil {
+			return err
+		}
+	}
+	if q.dir != "" && opts.maxSize > 0 {
+		if err := checkSizes(opts.maxSize, entries, q); err != nil {
+			return err
+		}
+	}
+	entries = q.filter(entries)
+	if len(opts.allowed) > 0 {
+		if err := checkModuleLicenses(opts.allowed, modules, opts.verbose); err != nil {
+			return err
+		}
+	}
+
+	// Checked before taking the run lock, which creates the output
+	// directory.
+	if *checkFlag {
+		return checkEntries(cwd, modules, entries, previous)
+	}
+
+	if *writePlanFlag != "" {
+		p, err := newPlanFile(copyPat, modules, entries)
+		if err != nil {
+			return fmt.Errorf("%w - unable to hash the planned files", err)
+		}
+		if err := writePlanFile(*writePlanFlag, p); err != nil {
+			return fmt.Errorf("%w - unable to write %s", err, *writePlanFlag)
+		}
+		fmt.Printf("wrote the plan of %d files to %s, run modvendor apply %s to copy them\n", len(entries), *writePlanFlag, *writePlanFlag)
+		return nil
+	}
+
+	// Archives are written instead of, not next to, the output directory.
+	if *archiveFlag != "" {
+		if err := writeArchive(*archiveFlag, entries, *containerFlag, opts.verbose); err != nil {
+			if interrupted() {
+				return errInterrupted("%s not written", *archiveFlag)
+			}
+			return fmt.Errorf("%w - unable to write %s", err, *archiveFlag)
+		}
+		fmt.Printf("wrote %d files to %s\n", len(entries), *archiveFlag)
+		return nil
+	}
+
+	unlock, err := lockOutDir(cwd, *waitFlag)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another run may have written the output directory since.
+	if previous, err = previousManifest(cwd); err != nil {
+		return err
+	}
+	others := opts.splitManifest(previous)
+	if *interactiveFlag {
+		ok, err := confirmEntries(cwd, modules, entries, previous)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("nothing written")
+			return nil
+		}
+	}
+
+	// Files are only moved into place once all of them copied, a failed run
+	// leaves the output directory as it was.
+	staging := outPath(cwd, stagingName)
+	if err := clearStaging(staging); err != nil {
+		return fmt.Errorf("%w - unable to remove %s left by an earlier run", err, outName(stagingName))
+	}
+	var backup *backup
+	if *backupFlag {
+		backup = newBackup(cwd)
+	}
+	markUnchanged(cwd, entries, previous, *dedupeFlag || *reproducibleFlag)
+	if *deltaFlag {
+		markDelta(cwd, entries)
+	}
+	if err := copyEntries(staging, entries, opts.verbose, opts.parallelism(), *keepGoingFlag); err != nil {
+		return err
+	}
+	// Files failing to copy are left out of the output directory and the
+	// manifest, and reported once everything else is done.
+	var copyFailures []error
+	entries, copyFailures = copiedEntries(entries)
+	if planned != nil {
+		if err := planned.check(entries); err != nil {
+			_ = clearStaging(staging)
+			return fmt.Errorf("%w - nothing written to %s/", err, outName(""))
+		}
+	}
+	// Moving the files into place is quick, once started the run is seen
+	// through to a consistent output directory and manifest.
+	if interrupted() {
+		_ = clearStaging(staging)
+		return errInterrupted("copied every file, nothing written to %s/", outName(""))
+	}
+	if *scanFlag != "" {
+		if err := runScanner(cwd, staging, *scanFlag, copyPat, entries); err != nil {
+			// The files it fails on alone may be excused.
+			excused := false
+			if q.active() {
+				failing, serr := scanEach(cwd, staging, *scanFlag, copyPat, entries)
+				if serr != nil {
+					_ = clearStaging(staging)
+					return fmt.Errorf("%w - unable to scan the files one by one", serr)
+				}
+				excused = len(failing) > 0
+				for _, e := range failing {
+					if !q.excuse(e, "scan", fmt.Sprintf("the scanner %q fails on it", *scanFlag)) {
+						excused = false
+					}
+				}
+			}
+			if !excused {
+				_ = clearStaging(staging)
+				return fmt.Errorf("%w - scanner %q failed, nothing written to %s/", err, *scanFlag, outName(""))
+			}
+			entries = q.filter(entries)
+		}
+	}
+	if err := moveStaged(cwd, staging, entries, backup); err != nil {
+		return fmt.Errorf("%w - unable to move files from %s into place", err, outName(stagingName))
+	}
+	if err := q.write(); err != nil {
+		return fmt.Errorf("%w - unable to write the quarantine %s", err, q.relDir(cwd))
+	}
+	if len(q.files) > 0 {
+		fmt.Printf("quarantined %d files to %s, review %s and list those to vendor in allow-files: of %s\n", len(q.files), q.relDir(cwd), filepath.Join(q.relDir(cwd), quarantineReport), configName)
+	}
+	if err := patchEntries(cwd, entries, opts.verbose); err != nil {
+		return err
+	}
+
+	if *dedupeFlag {
+		linked, saved, err := dedupeEntries(cwd, entries, opts.verbose)
+		if err != nil {
+			return fmt.Errorf("%w - unable to hardlink identical files", err)
+		}
+		if linked > 0 {
+			fmt.Printf("hardlinked %d identical files, saving %s\n", linked, formatSize(saved))
+		}
+	}
+
+	// Remove what the previous run copied and isn't vendored anymore.
+	vendorDir := outPath(cwd, "")
+	for _, c := range staleFiles(modules, entries, previous) {
+		if opts.verbose {
+			fmt.Printf("removing %s\n", c.Dest)
+		}
+		path := outPath(cwd, c.Dest)
+		var err error
+		if backup != nil {
+			err = backup.save(cwd, c.Dest)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("%w - unable to remove %s", err, outName(c.Dest))
+		}
+		removeEmptyDirs(vendorDir, filepath.Dir(path))
+	}
+	if backup != nil && backup.Files > 0 {
+		rel, err := filepath.Rel(cwd, backup.Dir)
+		if err != nil {
+			rel = backup.Dir
+		}
+		fmt.Printf("backed up %d replaced files to %s\n", backup.Files, rel)
+	}
+
+	// Generated files are recorded per module path.
+	generated := map[string][]string{}
+	for _, gen := range []struct {
+		enabled bool
+		write   func(string, []*Mod, []*vendorEntry) (map[string]string, error)
+		what    string
+	}{
+		{*embedFlag, writeEmbeds, "embedding packages"},
+		{*bazelFlag, writeBazelBuilds, "BUILD.bazel files"},
+	} {
+		if !gen.enabled {
+			continue
+		}
+		files, err := gen.write(cwd, modules, entries)
+		if err != nil {
+			return fmt.Errorf("%w - unable to generate %s", err, gen.what)
+		}
+		for mod, file := range files {
+			generated[mod] = append(generated[mod], file)
+		}
+	}
+	if previous != nil {
+		for _, mm := range previous.Modules {
+			for _, file := range mm.Generated {
+				if containsString(generated[mm.Path], file) {
+					continue
+				}
+				path := outPath(cwd, file)
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("%w - unable to remove %s", err, outName(file))
+				}
+				removeEmptyDirs(vendorDir, filepath.Dir(path))
+			}
+		}
+	}
+
+	// Without go.sum, say with only local replaces, modules get no hash.
+	sums, _ := loadGoSum(cwd)
+	manifest := newManifest(copyPat, modules, entries, sums)
+	manifest.State = state
+	for _, mm := range manifest.Modules {
+		mm.Generated = generated[mm.Path]
+		sort.Strings(mm.Generated)
+	}
+	manifest.Modules = append(manifest.Modules, others...)
+	if *reproducibleFlag {
+		manifest.makeReproducible(cwd, modTime)
+	}
+	if err := writeManifest(manifestPath(cwd), manifest); err != nil {
+		return fmt.Errorf("%w - unable to write %s", err, manifestName)
+	}
+	if err := writeSums(outPath(cwd, sumsName(sumAlgorithm)), manifest); err != nil {
+		return fmt.Errorf("%w - unable to write %s", err, sumsName(sumAlgorithm))
+	}
+	// Those of the algorithm used before are out of date.
+	for _, a := range checksum.Algorithms {
+		if a != sumAlgorithm {
+			_ = os.Remove(outPath(cwd, sumsName(a)))
+		}
+	}
+	if *reproducibleFlag || *containerFlag || chown != nil {
+		files := []string{manifestName, sumsName(sumAlgorithm)}
+		for _, mm := range manifest.Modules {
+			for _, f := range mm.Files {
+				files = append(files, f.Path)
+			}
+			files = append(files, mm.Generated...)
+		}
+		switch {
+		case *reproducibleFlag:
+			err = normalizeFiles(cwd, files, modTime)
+		case *containerFlag:
+			err = normalizeModes(cwd, files)
+		}
+		if err == nil && chown != nil {
+			err = chownFiles(cwd, files, chown)
+		}
+		if err != nil {
+			return fmt.Errorf("%w - unable to normalize the files of %s/", err, outName(""))
+		}
+	}
+
+	if *auditFlag {
+		if err := appendAudit(cwd, "copy", args, previous, manifest, entries); err != nil {
+			return fmt.Errorf("%w - unable to append to %s", err, outName(auditName))
+		}
+	}
+
+	if *attestFlag != "" {
+		if err := writeAttestation(*attestFlag, cwd, manifest); err != nil {
+			return fmt.Errorf("%w - unable to write %s", err, *attestFlag)
+		}
+	}
+	if *depfileFlag != "" {
+		if err := writeDepfile(*depfileFlag, cwd, entryDeps(entries)); err != nil {
+			return fmt.Errorf("%w - unable to write %s", err, *depfileFlag)
+		}
+	}
+	if interrupted() {
+		return errInterrupted("%s/ and its manifest were updated in full, skipped -verify, -timings and the post-hook", outName(""))
+	}
+
+	// Make sure the files we just copied come from untampered module content.
+	if *verifyFlag {
+		sums, err := loadGoSum(cwd)
+		if err != nil {
+			return err
+		}
+		for _, mod := range modules {
+			if len(mod.VendorList) == 0 {
+				continue
+			}
+			err := verifyMod(mod, sums)
+			switch {
+			case errors.Is(err, errPrivateNoSum):
+				warn("go.sum", "%s", err.Error())
+			case err != nil:
+				return err
+			case opts.verbose:
+				fmt.Printf("verified %s%s\n", mod.ImportPath, privateNote(mod.ImportPath))
+			}
+		}
+	}
+
+	if *postHookFlag != "" {
+		if err := runHook(cwd, *postHookFlag, newHookPlan(copyPat, entries)); err != nil {
+			return fmt.Errorf("%w - post-hook %q failed, the files are copied", err, *postHookFlag)
+		}
+	}
+
+	summary := newRunSummary(time.Since(start), modules)
+	if *timingsFlag {
+		summary.print()
+	}
+	if *jsonFlag != "" {
+		if err := summary.writeJSON(*jsonFlag); err != nil {
+			return fmt.Errorf("%w - unable to write %s", err, *jsonFlag)
+		}
+	}
+	if len(copyFailures) > 0 {
+		return failures(copyFailures, "%d files failed to copy and were left out of %s/", len(copyFailures), outName(""))
+	}
+	return nil
+}
+
+// checkEntries fails when copying entries would change ./vendor/.
+func checkEntries(cwd string, modules []*Mod, entries []*vendorEntry, previous *Manifest) error {
+	changes, err := diffVendor(cwd, modules, entries, previous)
+	if err != nil {
+		return fmt.Errorf("%w - unable to compare ./vendor/", err)
+	}
+	for _, c := range changes {
+		if outputFormat == "github" {
+			problem(outName(c.Dest), "%s is out of date (%s), run modvendor copy", outName(c.Dest), map[byte]string{'A': "missing", 'M': "modified", 'D': "no longer vendored"}[c.Kind])
+			continue
+		}
+		problem(outName(c.Dest), "%c\t%s", c.Kind, outName(c.Dest))
+	}
+	outdated := len(changes) > 0
+	if previous == nil && len(entries) > 0 {
+		problem(outName(manifestName), "missing %s", outName(manifestName))
+		outdated = true
+	}
+	if outdated {
+		return failed("%s/ is out of date, run modvendor copy", outName(""))
+	}
+	return nil
+}
+
+// confirmEntries shows the changes copying entries makes to ./vendor/ and
+// asks on stdin whether to go ahead.
+func confirmEntries(cwd string, modules []*Mod, entries []*vendorEntry, previous *Manifest) (bool, error) {
+	changes, err := diffVendor(cwd, modules, entries, previous)
+	if err != nil {
+		return false, fmt.Errorf("%w - unable to compare ./vendor/", err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return true, nil
+	}
+
+	byModule := map[string][]vendorChange{}
+	for _, c := range changes {
+		byModule[c.Module] = append(byModule[c.Module], c)
+	}
+	counts := map[byte]int{}
+	show := func(title string, changes []vendorChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Printf("%s:\n", title)
+		for _, c := range changes {
+			fmt.Printf("\t%c\t%s\n", c.Kind, outName(c.Dest))
+			counts[c.Kind]++
+		}
+	}
+	for _, mod := range modules {
+		show(mod.String(), byModule[mod.ImportPath])
+		delete(byModule, mod.ImportPath)
+	}
+	// Modules vendored by the previous run only.
+	if previous != nil {
+		for _, mm := range previous.Modules {
+			show(mm.String(), byModule[mm.Path])
+			delete(byModule, mm.Path)
+		}
+	}
+
+	fmt.Printf("\n%d added, %d modified, %d removed. Write these changes to %s/? [y/N] ", counts['A'], counts['M'], counts['D'], outName(""))
+	answers := make(chan string, 1)
+	go func() {
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answers <- answer
+	}()
+	var answer string
+	select {
+	case answer = <-answers:
+	case <-runCtx.Done():
+		fmt.Println()
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func warnRetracted(modules []*Mod) error {
+	var versions []string
+	for _, mod := range modules {
+		switch {
+		case len(mod.VendorList) == 0:
+		case mod.SourceVersion != "":
+			versions = append(versions, mod.SourcePath+"@"+mod.SourceVersion)
+		case mod.SourcePath == "":
+			versions = append(versions, mod.ImportPath+"@"+mod.Version)
+		}
+	}
+	retracted, err := retractedVersions(versions)
+	if err != nil {
+		return fmt.Errorf("%w - unable to check for retracted versions", err)
+	}
+	for _, v := range versions {
+		if rationale, ok := retracted[v]; ok {
+			warn("go.mod", "vendoring files from retracted version %s: %s", v, strings.Join(rationale, "; "))
+		}
+	}
+	return nil
+}
+
+// stagingName is the directory, within the output directory, files are
+// copied to before being moved into place.
+const stagingName = ".modvendor-staging"
+
+// copyEntries copies mod vendor list files to the staging directory and
+// records the checksum of each copied file, jobs modules at a time. Files
+// failing to copy don't stop the others, all of them are reported once done
+// and the staging directory is emptied, partial copies of large files are
+// kept to resume from. With keepGoing, failing entries get their Err set
+// instead, and the others are left in the staging directory.
+func copyEntries(staging string, entries []*vendorEntry, verbose bool, jobs int, keepGoing bool) error {
+	byMod := map[*Mod][]*vendorEntry{}
+	var mods []*Mod
+	for _, e := range entries {
+		if byMod[e.Mod] == nil {
+			mods = append(mods, e.Mod)
+		}
+		byMod[e.Mod] = append(byMod[e.Mod], e)
+	}
+
+	dirs := &copyer.DirSet{}
+	var mu sync.Mutex
+	var failed []error
+	var copied, toCopy int64
+	for _, e := range entries {
+		if !e.Unchanged && !e.Delta {
+			toCopy++
+		}
+	}
+	var g errgroup.Group
+	g.SetLimit(jobs)
+	for _, mod := range mods {
+		mod, entries := mod, byMod[mod]
+		g.Go(func() error {
+			start := time.Now()
+			defer func() {
+				mod.Timing.Copy = time.Since(start)
+			}()
+			defer traceRegion("copy", mod.ImportPath).End()
+			// Zip readers aren't shared between goroutines.
+			sources := newSourceFiles()
+			defer sources.Close()
+			for _, e := range entries {
+				if e.Unchanged || e.Delta {
+					continue
+				}
+				// Files being copied are finished, no others started.
+				if interrupted() {
+					return runCtx.Err()
+				}
+				if verbose {
+					fmt.Printf("vendoring %s\n", e.Dest)
+				}
+				if err := copyEntry(sources, dirs, staging, e); err != nil {
+					if interrupted() {
+						return runCtx.Err()
+					}
+					mu.Lock()
+					failed = append(failed, err)
+					mu.Unlock()
+					e.Err = err
+					continue
+				}
+				if key, err := sourceKey(sources, e); err == nil {
+					loadHashCache().put(key, e.Sum)
+				}
+				store.add(filepath.Join(staging, filepath.FromSlash(e.Dest)), e.Sum)
+				mod.Timing.Copied++
+				atomic.AddInt64(&copied, 1)
+				if info, err := sources.stat(e); err == nil {
+					mod.Timing.Bytes += info.Size()
+				}
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		_ = clearStaging(staging)
+		if interrupted() {
+			return errInterrupted("copied %d of %d files, nothing written to %s/", atomic.LoadInt64(&copied), toCopy, outName(""))
+		}
+		return err
+	}
+	saveHashCache()
+	if len(failed) == 0 || keepGoing {
+		return nil
+	}
+	_ = clearStaging(staging)
+	if len(failed) == 1 {
+		return failed[0]
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Error() < failed[j].Error()
+	})
+	return failures(failed, "%d of %d files failed to copy, nothing written to %s/", len(failed), toCopy, outName(""))
+}
+
+// copiedEntries returns the entries of those that didn't fail to copy, and
+// the errors of the others.
+func copiedEntries(entries []*vendorEntry) ([]*vendorEntry, []error) {
+	copied := entries[:0:0]
+	var failed []error
+	for _, e := range entries {
+		if e.Err != nil {
+			failed = append(failed, e.Err)
+			continue
+		}
+		copied = append(copied, e)
+	}
+	return copied, failed
+}
+
+func copyEntry(sources *sourceFiles, dirs *copyer.DirSet, staging string, e *vendorEntry) error {
+	mod := e.Mod
+	localFile := filepath.Join(staging, filepath.FromSlash(e.Dest))
+
+	if err := dirs.MkdirAll(filepath.Dir(localFile)); err != nil {
+		return copyError(e, localFile, err)
+	}
+
+	if store.materialize(sources, e, localFile) {
+		return nil
+	}
+
+	h := sumAlgorithm.New()
+	if mod.Zip != "" {
+		r, err := sources.open(e)
+		if err == nil {
+			var info fs.FileInfo
+			if info, err = sources.stat(e); err == nil {
+				err = extractFile(r, info, localFile, h)
+			}
+			_ = r.Close()
+		}
+		if err != nil {
+			return copyError(e, localFile, err)
+		}
+		e.Sum = sumAlgorithm.Sum(h)
+		return nil
+	}
+
+	// Vendor whatever symlinks point to.
+	src, err := e.resolved()
+	if err != nil {
+		return copyError(e, localFile, err)
+	}
+	info, err := e.stat()
+	if err != nil {
+		return copyError(e, localFile, err)
+	}
+
+	// Clones share the blocks of the module cache where the filesystem
+	// supports it, anything else is copied.
+	if err := copyer.Clone(src, localFile); err == nil {
+		err = os.Chmod(localFile, info.Mode().Perm()|0644)
+		if err == nil {
+			err = os.Chtimes(localFile, info.ModTime(), info.ModTime())
+		}
+		if err == nil {
+			e.Sum, err = sourceSum(sources, e)
+		}
+		if err != nil {
+			return copyError(e, localFile, err)
+		}
+		return nil
+	}
+
+	if info.Size() >= int64(progressSize) && progressSize > 0 && !copyer.IsSparse(info) {
+		key, err := sourceKey(sources, e)
+		if err == nil {
+			err = copyResumable(src, localFile, partialPath(staging, key), e.Dest, info, h)
+		}
+		if err != nil {
+			return copyError(e, localFile, err)
+		}
+		e.Sum = sumAlgorithm.Sum(h)
+		return nil
+	}
+
+	// With the checksum known already, nothing needs to read the content.
+	sum, ok := cachedSum(sources, e)
+	var tee io.Writer
+	if !ok {
+		tee = h
+	}
+	if err := copyer.Regular(src, localFile, info, tee); err != nil {
+		return copyError(e, localFile, err)
+	}
+	if !ok {
+		sum = sumAlgorithm.Sum(h)
+	}
+	e.Sum = sum
+	return nil
+}
+
+// copyError is the error of e failing to copy to dst.
+func copyError(e *vendorEntry, dst string, err error) error {
+	src := e.Src
+	if e.Mod.Zip != "" {
+		src = fmt.Sprintf("%s (%s%s)", e.Mod.Zip, zipPrefix(e.Mod), e.name())
+	}
+	return &vendoring.CopyError{Src: src, Dst: dst, Err: err}
+}
+
+// markUnchanged marks the entries whose vendored file has the size and
+// modification time of its source, and whose checksum was recorded by the run
+// of previous (may be nil). With byContent, as for files hardlinked by
+// -dedupe or normalized by -reproducible, files are compared by the checksum
+// of their source instead of its modification time.
+func markUnchanged(cwd string, entries []*vendorEntry, previous *Manifest, byContent bool) {
+	if previous == nil {
+		return
+	}
+	sums := map[string]string{}
+	copied := map[string]time.Time{}
+	for _, mm := range previous.Modules {
+		for _, f := range mm.Files {
+			sums[f.Path] = f.Sum
+			// Files of older versions were copied by that run at the latest.
+			copied[f.Path] = previous.Generated
+			if f.Copied != nil {
+				copied[f.Path] = *f.Copied
+			}
+		}
+	}
+
+	sources := newSourceFiles()
+	defer sources.Close()
+	for _, e := range entries {
+		// Files checksummed by another algorithm are copied again.
+		sum, ok := sums[e.Dest]
+		if !ok || checksum.Of(sum) != sumAlgorithm {
+			continue
+		}
+		info, err := os.Lstat(outPath(cwd, e.Dest))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		src, err := sources.stat(e)
+		if err != nil || info.Size() != src.Size() {
+			continue
+		}
+		if !info.ModTime().Equal(src.ModTime()) {
+			// Hardlinked files share the modification time of one of
+			// their sources, normalized ones have none of theirs: compare
+			// the content of the source.
+			if !byContent {
+				continue
+			}
+			if s, err := sourceSum(sources, e); err != nil || s != sum {
+				continue
+			}
+		}
+		e.Sum = sum
+		e.Copied = copied[e.Dest]
+		e.Unchanged = true
+	}
+}
+
+// moveStaged renames the staged files of entries into the output directory
+// and removes the staging directory. Renames within a directory tree don't
+// fail for lack of space. Files with other content than their replacement
+// are moved to b first, unless it is nil.
+func moveStaged(cwd, staging string, entries []*vendorEntry, b *backup) error {
+	defer traceRegion("move", "").End()
+	dirs := &copyer.DirSet{}
+	for _, e := range entries {
+		if e.Unchanged || e.Delta {
+			continue
+		}
+		dest := outPath(cwd, e.Dest)
+		if err := dirs.MkdirAll(filepath.Dir(dest)); err != nil {
+			return err
+		}
+		if sum, err := fileSum(dest, sumAlgorithm); b != nil && err == nil && sum != e.Sum {
+			if err := b.save(cwd, e.Dest); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(filepath.Join(staging, filepath.FromSlash(e.Dest)), dest); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(staging)
+}
+
+func copyFile(src, dst string) (int64, error) {
+	srcStat, err := os.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if !srcStat.Mode().IsRegular() {
+		return 0, fmt.Errorf("%s is not a regular file", src)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = dstFile.Close()
+	}()
+
+	return io.Copy(dstFile, srcFile)
+}
+
+// writeArchive writes the files of entries to the archive out, named as
+// within the project once vendored, with the modes of -container if
+// container.
+func writeArchive(out string, entries []*vendorEntry, container, verbose bool) error {
+	// Write next to the destination so a failed run leaves no partial archive.
+	tmp, err := os.CreateTemp(filepath.Dir(out), filepath.Base(out)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	dst := vendoring.ArchiveDestination(out, tmp)
+	sources := newSourceFiles()
+	defer sources.Close()
+	for _, e := range entries {
+		if verbose {
+			fmt.Printf("archiving %s\n", e.Dest)
+		}
+		name := outName(e.Dest)
+		if !fs.ValidPath(name) {
+			name = e.Dest
+		}
+		info, err := sources.stat(e)
+		if err != nil {
+			return err
+		}
+		r, err := sources.open(e)
+		if err != nil {
+			return err
+		}
+		if container {
+			info = containerInfo{info}
+		}
+		err = vendoring.Write(runCtx, dst, name, info, copyer.Throttled(r))
+		_ = r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	err = dst.Close()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Temporary files are private to the user.
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), out)
+}
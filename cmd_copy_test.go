@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/vendoring/vendortest"
+)
+
+// copyProject writes a project vendoring example.com/foo, makes it the
+// working directory for the test, and returns its directory.
+func copyProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	fixture := &vendortest.Fixture{Modules: []vendortest.Module{{
+		Path:    "example.com/foo",
+		Version: "v1.0.0",
+		Files:   map[string]string{"foo.go": "package foo\n", "csrc/a.c": "int a;\n", "csrc/b.c": "int b;\n"},
+	}}}
+	if err := fixture.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOMODCACHE", filepath.Join(dir, vendortest.ModCacheDir))
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cwd := filepath.Join(dir, vendortest.ProjectDir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(cwd); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		outDir, sumAlgorithm = "vendor", checksum.SHA256
+	})
+	return cwd
+}
+
+// snapshot returns the files of dir with their modes and content, nil if
+// dir doesn't exist.
+func snapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	var files map[string]string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if files == nil {
+			files = map[string]string{}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		content := ""
+		if !d.IsDir() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			content = string(data)
+		}
+		files[path] = info.Mode().String() + " " + content
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCopyCheckWritesNothing(t *testing.T) {
+	cwd := copyProject(t)
+	if err := runCopy([]string{"-copy", "**/*.c"}); err != nil {
+		t.Fatal(err)
+	}
+	// Stale: a file modified, another deleted.
+	vendored := filepath.Join(cwd, "vendor", "example.com", "foo", "csrc")
+	if err := os.WriteFile(filepath.Join(vendored, "a.c"), []byte("int modified;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(vendored, "b.c")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		dir  string
+	}{
+		{"stale", []string{"-check", "-copy", "**/*.c"}, "vendor"},
+		{"never copied", []string{"-check", "-copy", "**/*.c", "-o", "third_party"}, "third_party"},
+	}
+	for _, tt := range tests {
+		dir := filepath.Join(cwd, tt.dir)
+		before := snapshot(t, dir)
+		var exit *exitError
+		if err := runCopy(tt.args); !errors.As(err, &exit) || exit.code != 1 {
+			t.Errorf("%s: copy %q = %v, want it out of date", tt.name, tt.args, err)
+		}
+		if after := snapshot(t, dir); !reflect.DeepEqual(after, before) {
+			t.Errorf("%s: copy %q changed %s from %q to %q", tt.name, tt.args, tt.dir, before, after)
+		}
+	}
+}
+
+func TestCopyCheckPreHook(t *testing.T) {
+	cwd := copyProject(t)
+	var usage *usageError
+	if err := runCopy([]string{"-check", "-pre-hook", "touch hooked", "-copy", "**/*.c"}); !errors.As(err, &usage) {
+		t.Errorf("copy -check -pre-hook = %v, want a usage error", err)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "hooked")); !os.IsNotExist(err) {
+		t.Errorf("copy -check ran the pre hook: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "fmt"
+
+var diffCmd = &command{
+	Name:  "diff",
+	Short: "preview the changes copy would make to ./vendor/",
+	Run:   runDiff,
+}
+
+func runDiff(args []string) error {
+	flags := newFlagSet("diff", "[flags]",
+		"Diff compares the files copy would vendor with the given flags against the\ncurrent content of ./vendor/ and prints them as added (A), modified (M) or\nremoved (D), one per line like `git diff --name-status`. Nothing is written.")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	entries, err := planCopy(modules, &opts)
+	if err != nil {
+		return err
+	}
+
+	previous, err := previousManifest(cwd)
+	if err != nil {
+		return err
+	}
+	opts.splitManifest(previous)
+	changes, err := diffVendor(cwd, modules, entries, previous)
+	if err != nil {
+		return fmt.Errorf("%w - unable to compare ./vendor/", err)
+	}
+
+	counts := map[byte]int{}
+	for _, c := range changes {
+		fmt.Printf("%c\t%s\n", c.Kind, outName(c.Dest))
+		counts[c.Kind]++
+	}
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return nil
+	}
+	fmt.Printf("\n%d files changed: %d added, %d modified, %d removed\n", len(changes), counts['A'], counts['M'], counts['D'])
+	return nil
+}
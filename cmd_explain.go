@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var explainCmd = &command{
+	Name:  "explain",
+	Short: "explain why a file under ./vendor/ is vendored",
+	Run:   runExplain,
+}
+
+func runExplain(args []string) error {
+	flags := newFlagSet("explain", "[flags] vendor/<path>...",
+		"Explain prints, for each given file, which pattern matched it, which module\nand package caused its inclusion and where in the module cache it is copied\nfrom. Pass the same flags that were used for copy.")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	entries, err := planCopy(modules, &opts)
+	if err != nil {
+		return err
+	}
+	byDest := map[string]*vendorEntry{}
+	for _, e := range entries {
+		byDest[e.Dest] = e
+	}
+
+	includes := map[string]bool{}
+	for _, dir := range opts.includes() {
+		includes[dir] = true
+	}
+
+	failed := false
+	for i, arg := range flags.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		dest := vendorRelPath(cwd, arg)
+		fmt.Println(outName(dest))
+
+		e, ok := byDest[dest]
+		if !ok {
+			fmt.Println("  not vendored by modvendor with these flags")
+			failed = true
+			continue
+		}
+
+		fmt.Printf("  module:   %s\n", e.Mod)
+		pkg := e.Match.Pkg
+		switch {
+		case includes[pkg]:
+			pkg += " (-include)"
+		case opts.fullCopy && pkg == e.Mod.ImportPath:
+			pkg += " (-fullcopy)"
+		}
+		fmt.Printf("  package:  %s\n", pkg)
+		for _, pat := range e.Match.Patterns {
+			if pat == "" {
+				pat = `"" (-copy is empty, every file matches)`
+			}
+			fmt.Printf("  pattern:  %s\n", pat)
+		}
+		if e.Mod.Zip != "" {
+			fmt.Printf("  source:   %s (%s%s)\n", e.Mod.Zip, zipPrefix(e.Mod), filepath.ToSlash(e.Src[len(e.Mod.Dir)+1:]))
+		} else {
+			fmt.Printf("  source:   %s\n", e.Src)
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+// vendorRelPath turns a path given on the command line, either relative to
+// the project root (vendor/...) or absolute, into a slash separated path
+// relative to the output directory.
+func vendorRelPath(cwd, path string) string {
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(outPath(cwd, ""), path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	return strings.TrimPrefix(path, outName("")+"/")
+}
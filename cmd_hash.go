@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+var hashCmd = &command{
+	Name:  "hash",
+	Short: "print a hash of what copy would vendor, as a build cache key",
+	Run:   runHash,
+}
+
+func runHash(args []string) error {
+	flags := newFlagSet("hash", "[flags]",
+		"Hash prints a hash of the plan copy would carry out: the resolved module versions\nand replace targets, the copy patterns, the output directory and the checksum of\nevery file vendored, by destination. It only changes when one of these does, so\nbuild systems can key their caches on it and skip vendoring while it holds.")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	entries, err := planCopy(modules, &opts)
+	if err != nil {
+		return err
+	}
+
+	l, err := newLock(opts.patterns(), modules, entries)
+	if err != nil {
+		return fmt.Errorf("%w - unable to hash vendored files", err)
+	}
+	if opts.verbose {
+		for _, m := range l.Modules {
+			fmt.Printf("%s %s %s (%d files)\n", m.Path, m.Version, m.Hash, m.Files)
+		}
+	}
+	fmt.Println(planHash(l))
+	return nil
+}
+
+// planHash returns the hex SHA256 of the plan locked in l, as copied to the
+// output directory. Modules are in the order of vendor/modules.txt, and
+// their hashes don't depend on the order files were planned in.
+func planHash(l *Lock) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "modvendor plan hash 1\n")
+	fmt.Fprintf(h, "out %q\n", outDir)
+	fmt.Fprintf(h, "patterns %q\n", strings.Join(l.Patterns, " "))
+	for _, m := range l.Modules {
+		fmt.Fprintf(h, "module %q %q %q %q %d %s\n", m.Path, m.Version, m.Replace, m.ReplaceVersion, m.Files, m.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var initCmd = &command{
+	Name:  "init",
+	Short: "propose copy patterns for cgo dependencies in ./" + configName,
+	Run:   runInit,
+}
+
+// cgoExts are the extensions of the non-Go files the go command builds into
+// a package, see go/build.Package.
+var cgoExts = map[string]bool{
+	".c": true, ".h": true,
+	".cc": true, ".cpp": true, ".cxx": true, ".hh": true, ".hpp": true, ".hxx": true,
+	".m": true, ".s": true, ".S": true, ".sx": true,
+	".f": true, ".F": true, ".for": true, ".f90": true,
+	".swig": true, ".swigcxx": true, ".syso": true,
+}
+
+// cgoModule is a module with vendored packages using cgo, and the extensions
+// of its files missing from ./vendor/.
+type cgoModule struct {
+	Mod  *Mod
+	Exts map[string]bool
+}
+
+func runInit(args []string) error {
+	flags := newFlagSet("init", "[flags]",
+		"Init looks for vendored packages using cgo, proposes copy patterns for the\nfiles of their modules `go mod vendor` left out and writes them to a starter\n./"+configName+". The values of "+configName+" are the defaults of the flags of\nthe same name.")
+	forceFlag := flags.Bool("f", false, "overwrite an existing ./"+configName)
+	dryRunFlag := flags.Bool("n", false, "print the configuration instead of writing it")
+	opts := vendorOptions{fullCopy: true}
+	flags.BoolVar(&opts.fetch, "fetch", false, "download modules missing from the module cache from GOPROXY")
+	flags.BoolVar(&opts.verbose, "v", false, "verbose output")
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	cfgPath := filepath.Join(cwd, configName)
+	if _, err := os.Stat(cfgPath); err == nil && !*forceFlag && !*dryRunFlag {
+		return whoops("%s already exists, pass -f to overwrite it", configName)
+	}
+
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	if err := buildVendorLists(modules, &opts); err != nil {
+		return err
+	}
+
+	cgo, err := findCgoModules(cwd, modules)
+	if err != nil {
+		return fmt.Errorf("%w - unable to inspect modules", err)
+	}
+	if opts.verbose {
+		for _, c := range cgo {
+			fmt.Printf("%s uses cgo, missing %s files\n", c.Mod.ImportPath, strings.Join(sortedKeys(c.Exts), " "))
+		}
+	}
+
+	data := starterConfig(cgo)
+	if *dryRunFlag {
+		fmt.Print(data)
+		return nil
+	}
+	if err := os.WriteFile(cfgPath, []byte(data), 0644); err != nil {
+		return fmt.Errorf("%w - unable to write %s", err, configName)
+	}
+	fmt.Printf("wrote %s, %d of %d modules use cgo\n", configName, len(cgo), len(modules))
+	return nil
+}
+
+// findCgoModules returns the modules with vendored packages importing "C"
+// whose cgo sources aren't all in ./vendor/ already.
+func findCgoModules(cwd string, modules []*Mod) ([]*cgoModule, error) {
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	var cgo []*cgoModule
+	for _, mod := range modules {
+		pkgDirs := map[string]bool{}
+		for _, pkg := range mod.VendoredPkgs {
+			pkgDirs[pkg] = true
+		}
+
+		usesCgo := false
+		exts := map[string]bool{}
+		for file := range mod.VendorList {
+			e := &vendorEntry{Mod: mod, Src: file, Dest: filepath.ToSlash(mod.ImportPath + file[len(mod.Dir):])}
+			if ext := path.Ext(e.Dest); cgoExts[ext] {
+				if _, err := os.Stat(filepath.Join(cwd, "vendor", filepath.FromSlash(e.Dest))); os.IsNotExist(err) {
+					exts[ext] = true
+				}
+				continue
+			}
+			if usesCgo || !pkgDirs[path.Dir(e.Dest)] || !strings.HasSuffix(e.Dest, ".go") || strings.HasSuffix(e.Dest, "_test.go") {
+				continue
+			}
+			ok, err := importsC(sources, e)
+			if err != nil {
+				return nil, err
+			}
+			usesCgo = ok
+		}
+		if usesCgo && len(exts) > 0 {
+			cgo = append(cgo, &cgoModule{Mod: mod, Exts: exts})
+		}
+	}
+	return cgo, nil
+}
+
+func importsC(sources *sourceFiles, e *vendorEntry) (bool, error) {
+	r, err := sources.open(e)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	f, err := parser.ParseFile(token.NewFileSet(), e.Src, r, parser.ImportsOnly)
+	if err != nil {
+		// The go command would refuse to build it, not our business.
+		return false, nil
+	}
+	for _, imp := range f.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == "C" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// starterConfig renders the configuration proposed for the cgo modules, with
+// every setting explained.
+func starterConfig(cgo []*cgoModule) string {
+	var b strings.Builder
+	b.WriteString(`# modvendor configuration, written by "modvendor init".
+#
+# The settings are the defaults of the flags of the same name, flags given on
+# the command line take precedence.
+
+`)
+	exts := map[string]bool{}
+	for _, c := range cgo {
+		for ext := range c.Exts {
+			exts[ext] = true
+		}
+	}
+	if len(cgo) == 0 {
+		b.WriteString("# Files to copy into ./vendor/, as with -copy. No vendored package uses cgo\n# with sources `go mod vendor` leaves out.\ncopy: []\n")
+	} else {
+		b.WriteString("# Files to copy into ./vendor/, as with -copy. Proposed for the cgo sources\n# `go mod vendor` leaves out of:\n")
+		for _, c := range cgo {
+			fmt.Fprintf(&b, "#   %s\n", c.Mod.ImportPath)
+		}
+		b.WriteString("copy:\n")
+		for _, ext := range sortedKeys(exts) {
+			fmt.Fprintf(&b, "  - %q\n", "**/*"+ext)
+		}
+	}
+	b.WriteString(`
+# Copy the matching files of the whole module rather than only those within
+# the packages listed in vendor/modules.txt.
+fullcopy: true
+
+# Additional directories to copy which aren't packages in vendor/modules.txt,
+# as with -include.
+include: []
+
+# Module path patterns, as in GOPRIVATE, to vendor the files of only, or to
+# leave alone.
+`)
+	b.WriteString("only: []\n")
+	b.WriteString("skip: []\n")
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package main
+
+import "fmt"
+
+var listCmd = &command{
+	Name:  "list",
+	Short: "print the files the patterns would vendor, without copying",
+	Run:   runList,
+}
+
+func runList(args []string) error {
+	flags := newFlagSet("list", "[flags]",
+		"List prints, per module, the files copy would vendor with the given flags\nalong with their sizes. Nothing is written.")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	entries, err := planCopy(modules, &opts)
+	if err != nil {
+		return err
+	}
+
+	byMod := map[*Mod][]*vendorEntry{}
+	for _, e := range entries {
+		byMod[e.Mod] = append(byMod[e.Mod], e)
+	}
+
+	var totalFiles int
+	var totalSize int64
+	for _, mod := range modules {
+		modEntries := byMod[mod]
+		if len(modEntries) == 0 {
+			if opts.verbose {
+				fmt.Printf("%s\n  no files\n\n", mod)
+			}
+			continue
+		}
+
+		sizes, err := entrySizes(modEntries)
+		if err != nil {
+			return err
+		}
+		var size int64
+		fmt.Println(mod)
+		for i, e := range modEntries {
+			fmt.Printf("  %10s  %s\n", formatSize(sizes[i]), outName(e.Dest))
+			size += sizes[i]
+		}
+		fmt.Printf("  %d files, %s\n\n", len(modEntries), formatSize(size))
+		totalFiles += len(modEntries)
+		totalSize += size
+	}
+	fmt.Printf("total: %d files, %s\n", totalFiles, formatSize(totalSize))
+	return nil
+}
+
+// entrySizes returns the size of the source of each of entries.
+func entrySizes(entries []*vendorEntry) ([]int64, error) {
+	sizes := make([]int64, len(entries))
+	sources := newSourceFiles()
+	defer sources.Close()
+	for i, e := range entries {
+		info, err := sources.stat(e)
+		if err != nil {
+			return nil, fmt.Errorf("%w - unable to stat %s", err, e.Src)
+		}
+		sizes[i] = info.Size()
+	}
+	return sizes, nil
+}
+
+// formatSize formats n bytes for humans, e.g. "12.3 KiB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
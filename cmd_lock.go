@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var lockCmd = &command{
+	Name:  "lock",
+	Short: "pin the modules, patterns and content vendored in ./" + lockName,
+	Run:   runLock,
+}
+
+func runLock(args []string) error {
+	flags := newFlagSet("lock", "[flags]",
+		"Lock records the resolved module versions, replace targets, the copy patterns\nand a hash of the files copy would vendor per module in ./"+lockName+".\nCopy warns when the current state drifts from the lock, and fails with -locked.")
+	var opts vendorOptions
+	if err := opts.register(flags); err != nil {
+		return err
+	}
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	modules, err := loadModules(cwd, &opts)
+	if err != nil {
+		return err
+	}
+	entries, err := planCopy(modules, &opts)
+	if err != nil {
+		return err
+	}
+
+	l, err := newLock(opts.patterns(), modules, entries)
+	if err != nil {
+		return fmt.Errorf("%w - unable to hash vendored files", err)
+	}
+	if err := writeLock(lockPath(cwd), l); err != nil {
+		return fmt.Errorf("%w - unable to write %s", err, lockName)
+	}
+	if opts.verbose {
+		for _, m := range l.Modules {
+			fmt.Printf("locked %s %s (%d files)\n", m.Path, m.Version, m.Files)
+		}
+	}
+	fmt.Printf("wrote %s, %d modules locked\n", lockName, len(l.Modules))
+	return nil
+}
+
+// checkLock compares the plan with ./modvendor.lock, if any. Drift is a
+// warning, or a failure when locked is set.
+func checkLock(cwd string, opts *vendorOptions, modules []*Mod, entries []*vendorEntry, locked bool) error {
+	l, err := readLock(lockPath(cwd))
+	if os.IsNotExist(err) {
+		if locked {
+			return whoops("-locked needs a %s, run modvendor lock first", lockName)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w - unable to read %s", err, lockName)
+	}
+	current, err := newLock(opts.patterns(), modules, entries)
+	if err != nil {
+		return fmt.Errorf("%w - unable to hash vendored files", err)
+	}
+
+	drift := l.drift(current, opts.selected)
+	for _, d := range drift {
+		if locked {
+			problem(lockName, "drift: %s", d)
+		} else {
+			warn(lockName, "%s drift: %s", lockName, d)
+		}
+	}
+	if locked && len(drift) > 0 {
+		return failed("%s is out of date, run modvendor lock", lockName)
+	}
+	return nil
+}
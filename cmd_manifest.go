@@ -0,0 +1,188 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var manifestCmd = &command{
+	Name:  "manifest",
+	Short: "print the manifest of the files copied into ./vendor/ as JSON, CSV or protobuf",
+	Run:   runManifest,
+}
+
+// manifestSchema is the protobuf schema of the proto format.
+//
+//go:embed manifest.proto
+var manifestSchema string
+
+// manifestFormats write a manifest for consumers of each format, files
+// sorted by path within modules.
+var manifestFormats = map[string]func(w io.Writer, m *Manifest) error{
+	"json":  writeManifestJSON,
+	"csv":   writeManifestCSV,
+	"proto": writeManifestProto,
+}
+
+func runManifest(args []string) error {
+	flags := newFlagSet("manifest", "[flags]",
+		"Manifest prints what the last copy run recorded it copied, for other tools to\ningest: as JSON, the manifest as modvendor keeps it, as CSV, a row per file, or\nas protobuf, a binary Manifest message of the schema printed by -schema.")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	format := flags.String("format", "json", "format to print the manifest in: "+strings.Join(manifestFormatNames(), ", "))
+	schema := flags.Bool("schema", false, "print the protobuf schema of the proto format instead")
+	_ = flags.Parse(args)
+
+	if *schema {
+		fmt.Print(manifestSchema)
+		return nil
+	}
+	write, ok := manifestFormats[*format]
+	if !ok {
+		return whoops("unknown manifest format %q, use %s", *format, strings.Join(manifestFormatNames(), ", "))
+	}
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := loadManifest(cwd)
+	if err != nil {
+		return err
+	}
+	for _, mm := range manifest.Modules {
+		sort.Slice(mm.Files, func(i, j int) bool {
+			return mm.Files[i].Path < mm.Files[j].Path
+		})
+	}
+	return write(os.Stdout, manifest)
+}
+
+func manifestFormatNames() []string {
+	names := make([]string, 0, len(manifestFormats))
+	for name := range manifestFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeManifestJSON(w io.Writer, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writeManifestCSV writes a header, then a row per file with its module.
+func writeManifestCSV(w io.Writer, m *Manifest) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"path", "checksum", "module", "version", "replace", "replace_version", "go_sum", "source", "copied"})
+	for _, mm := range m.Modules {
+		for i := range mm.Files {
+			f := &mm.Files[i]
+			_ = cw.Write([]string{f.Path, f.Sum, mm.Path, mm.Version, mm.Replace, mm.ReplaceVersion, mm.GoSum, mm.Source(f), f.copiedTime()})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeManifestProto writes m as a Manifest message of manifest.proto.
+func writeManifestProto(w io.Writer, m *Manifest) error {
+	var b protoBuf
+	b.string(1, m.Generated.UTC().Format(time.RFC3339))
+	var tool protoBuf
+	tool.string(1, m.Tool.Version)
+	tool.string(2, m.Tool.Revision)
+	tool.bool(3, m.Tool.Modified)
+	tool.string(4, m.Tool.Go)
+	b.message(2, tool)
+	for _, p := range m.Patterns {
+		b.string(3, p)
+	}
+	for _, mm := range m.Modules {
+		var mod protoBuf
+		mod.string(1, mm.Path)
+		mod.string(2, mm.Version)
+		mod.string(3, mm.Replace)
+		mod.string(4, mm.ReplaceVersion)
+		if mm.VCS != nil {
+			var vcs protoBuf
+			vcs.string(1, mm.VCS.System)
+			vcs.string(2, mm.VCS.Revision)
+			vcs.bool(3, mm.VCS.Dirty)
+			mod.message(5, vcs)
+		}
+		mod.string(6, mm.Dir)
+		mod.string(7, mm.Zip)
+		for i := range mm.Files {
+			f := &mm.Files[i]
+			var file protoBuf
+			file.string(1, f.Path)
+			file.string(2, f.Sum)
+			file.string(3, mm.Source(f))
+			file.string(4, f.copiedTime())
+			mod.message(8, file)
+		}
+		for _, g := range mm.Generated {
+			mod.string(9, g)
+		}
+		mod.string(10, mm.GoSum)
+		b.message(4, mod)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// protoBuf encodes protobuf messages, leaving out fields of proto3 default
+// values as the official encoders do.
+type protoBuf []byte
+
+func (b *protoBuf) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protoBuf) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuf) bytes(field int, p []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(p)))
+	*b = append(*b, p...)
+}
+
+func (b *protoBuf) string(field int, s string) {
+	if s != "" {
+		b.bytes(field, []byte(s))
+	}
+}
+
+func (b *protoBuf) bool(field int, v bool) {
+	if v {
+		b.tag(field, 0)
+		b.varint(1)
+	}
+}
+
+// message encodes m as a message field, even empty as it is set.
+func (b *protoBuf) message(field int, m protoBuf) {
+	b.bytes(field, m)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+var multiCmd = &command{
+	Name:  "multi",
+	Short: "run copy in several module roots at once, walking shared modules once",
+	Run:   runMulti,
+}
+
+func runMulti(args []string) error {
+	flags := newFlagSet("multi", "[-dir directory] [-work] [root...] [-- copy flags]",
+		"Multi runs copy with the given flags in each module root in turn, those given\nand those found under -dir: directories with a go.mod and a vendor/modules.txt,\nnot within vendor/, testdata/ or directories starting with . or _. With -work,\nthe modules of the go.work in use vendored on their own are roots as well, once\nchecked they vendor every module at the same version. Each root reads its own\n"+configName+". Modules required by several roots are walked once, for the\npatterns and packages they are vendored with.")
+	dirFlag := flags.String("dir", "", "also run copy in the module roots found under the directory")
+	workFlag := flags.Bool("work", false, "also run copy in the modules of the go.work in use with a vendor/modules.txt, once checked they vendor the same versions")
+
+	// The roots run up to "--", the copy flags follow.
+	var copyArgs []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, copyArgs = args[:i], args[i+1:]
+			break
+		}
+	}
+	_ = flags.Parse(args)
+	// Appending to the arguments would overwrite the copy flags.
+	roots := append([]string(nil), flags.Args()...)
+	if *dirFlag != "" {
+		found, err := findModuleRoots(*dirFlag)
+		if err != nil {
+			return fmt.Errorf("%w - unable to find the module roots under %s", err, *dirFlag)
+		}
+		roots = append(roots, found...)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if *workFlag {
+		work := findGoWork(wd)
+		if work == "" {
+			return whoops("-work requires a go.work, none is in use in %s", wd)
+		}
+		members, err := workMembers(work)
+		if err != nil {
+			return fmt.Errorf("%w - unable to read %s", err, work)
+		}
+		if len(members) == 0 {
+			return whoops("no module of %s has a vendor/modules.txt, run go mod vendor in them with GOWORK=off, or go work vendor to vendor the workspace as a whole", work)
+		}
+		if err := checkWorkVersions(members); err != nil {
+			return err
+		}
+		for _, dir := range members {
+			if rel, err := filepath.Rel(wd, dir); err == nil {
+				dir = rel
+			}
+			roots = append(roots, dir)
+		}
+	}
+	if len(roots) == 0 {
+		return whoops("no module root to run copy in, give them, -dir or -work")
+	}
+
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+	handleInterrupts()
+	walks = &walkCache{lists: map[string]map[string]*vendorMatch{}}
+	defer func() {
+		walks = nil
+	}()
+
+	var failedRoots []string
+	for i, root := range roots {
+		if interrupted() {
+			return errInterrupted("%d of %d module roots left", len(roots)-i, len(roots))
+		}
+		fmt.Printf("# %s\n", root)
+		dir := root
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(wd, dir)
+		}
+		if err := os.Chdir(dir); err != nil {
+			fmt.Printf("Error! %s - unable to enter the module root\n", err.Error())
+			failedRoots = append(failedRoots, root)
+			continue
+		}
+		resetProject()
+		if err := runCopy(copyArgs); err != nil {
+			_ = exitStatus(err)
+			failedRoots = append(failedRoots, root)
+		}
+	}
+	if len(failedRoots) > 0 {
+		return failed("copy failed in %d of %d module roots: %s", len(failedRoots), len(roots), strings.Join(failedRoots, " "))
+	}
+	return nil
+}
+
+// resetProject forgets what was read of the previous project, before running
+// in another one.
+func resetProject() {
+	goSum = nil
+	sumAlgorithm = checksum.SHA256
+	outDir = "vendor"
+}
+
+// findModuleRoots returns the directories under dir with a go.mod and a
+// vendor/modules.txt, in lexical order, skipping those the go command
+// ignores as well as vendor directories.
+func findModuleRoots(dir string) ([]string, error) {
+	var roots []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		if fileExists(filepath.Join(path, "go.mod")) && fileExists(filepath.Join(path, "vendor", "modules.txt")) {
+			roots = append(roots, path)
+		}
+		return nil
+	})
+	return roots, err
+}
+
+// workMembers returns the directories of the modules of the go.work file
+// with a vendor/modules.txt, vendored on their own.
+func workMembers(work string) ([]string, error) {
+	dirs, err := workModules(work)
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	for _, dir := range dirs {
+		if fileExists(filepath.Join(dir, "vendor", "modules.txt")) {
+			members = append(members, dir)
+		}
+	}
+	return members, nil
+}
+
+// checkWorkVersions fails, printing them, when the modules of a workspace
+// vendor a module at different versions or replacements: their copies of
+// its files would differ.
+func checkWorkVersions(members []string) error {
+	type vendored struct {
+		version string
+		dirs    []string
+	}
+	var paths []string
+	byPath := map[string][]*vendored{}
+	for _, dir := range members {
+		modules, err := readModulesTxt(dir)
+		if err != nil {
+			return fmt.Errorf("%w of %s", err, dir)
+		}
+		for _, mod := range modules {
+			version := mod.Version
+			if mod.SourcePath != "" {
+				source := mod.SourcePath
+				if mod.SourceVersion == "" && !filepath.IsAbs(source) {
+					source = filepath.Join(dir, source)
+				}
+				version += " => " + joinNonEmpty(source, mod.SourceVersion)
+			}
+			if byPath[mod.ImportPath] == nil {
+				paths = append(paths, mod.ImportPath)
+			}
+			found := false
+			for _, v := range byPath[mod.ImportPath] {
+				if v.version == version {
+					v.dirs, found = append(v.dirs, dir), true
+					break
+				}
+			}
+			if !found {
+				byPath[mod.ImportPath] = append(byPath[mod.ImportPath], &vendored{version, []string{dir}})
+			}
+		}
+	}
+
+	inconsistent := 0
+	for _, path := range paths {
+		versions := byPath[path]
+		if len(versions) < 2 {
+			continue
+		}
+		inconsistent++
+		for _, v := range versions {
+			problem(filepath.Join(v.dirs[0], "vendor", "modules.txt"), "inconsistent: %s %s in %s", path, v.version, strings.Join(v.dirs, " "))
+		}
+	}
+	if inconsistent > 0 {
+		return failed("%d modules are vendored at different versions by the modules of the workspace, nothing written; vendor them with the same requirements", inconsistent)
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// walks, set by multi, keeps the files matched in modules for the next
+// project requiring them.
+var walks *walkCache
+
+// walkCache maps a module, the packages it is vendored for and how files are
+// matched to the files matched, see walkKey.
+type walkCache struct {
+	mu    sync.Mutex
+	lists map[string]map[string]*vendorMatch
+}
+
+// walkKey identifies the files buildVendorList matches in mod.
+func walkKey(copyPat []string, opts *vendorOptions, mod *Mod) string {
+	return strings.Join([]string{
+		mod.ImportPath, mod.Version, mod.Dir, mod.Zip,
+		strings.Join(mod.Pkgs, ","),
+		strings.Join(copyPat, " "),
+		opts.maxSize.String(), opts.quarantine,
+	}, "\x00")
+}
+
+// get returns a copy of the files matched for key, plugins being free to
+// change it.
+func (c *walkCache) get(key string) (map[string]*vendorMatch, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list, ok := c.lists[key]
+	if !ok {
+		return nil, false
+	}
+	return copyVendorList(list), true
+}
+
+func (c *walkCache) put(key string, list map[string]*vendorMatch) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lists[key] = copyVendorList(list)
+}
+
+func copyVendorList(list map[string]*vendorMatch) map[string]*vendorMatch {
+	c := make(map[string]*vendorMatch, len(list))
+	for file, m := range list {
+		m := *m
+		c[file] = &m
+	}
+	return c
+}
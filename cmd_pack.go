@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/plan"
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+var packCmd = &command{
+	Name:  "pack",
+	Short: "write ./vendor/ to a deterministic tar.gz, tar or zip archive",
+	Run:   runPack,
+}
+
+// packTime is the modification time of every archived file, archives of the
+// same tree are byte for byte identical.
+var packTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// packFile is a regular file of the vendor tree.
+type packFile struct {
+	Name string // slash separated, starting with vendor/
+	Path string
+	Exec bool
+}
+
+func runPack(args []string) error {
+	flags := newFlagSet("pack", "-o file",
+		"Pack writes the whole of ./vendor/, including the files modvendor copied and its\nmanifest, to an archive for build environments receiving sources as a single\nartifact. The format follows the extension of the output file: .tar.gz, .tgz,\n.tar or .zip. Archives of the same tree are identical, whatever the file\nmodification times and owners.")
+	outFlag := flags.String("o", "vendor.tar.gz", "archive to write")
+	verboseFlag := flags.Bool("v", false, "verbose output")
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	handleInterrupts()
+	vendorDir := filepath.Join(cwd, "vendor")
+	out, err := filepath.Abs(*outFlag)
+	if err != nil {
+		return fmt.Errorf("%w - invalid output path", err)
+	}
+	if ok, _ := plan.WithinRoot(vendorDir, filepath.Dir(out)); ok {
+		return whoops("the archive can't be written into ./vendor/ itself")
+	}
+
+	if vendoring.ArchiveDestination(out, io.Discard) == nil {
+		return whoops("unknown archive format of %s, use .tar.gz, .tgz, .tar or .zip", *outFlag)
+	}
+
+	files, err := listPackFiles(cwd)
+	if err != nil {
+		return fmt.Errorf("%w - unable to list ./vendor/", err)
+	}
+
+	// Write next to the destination so a failed run leaves no partial archive.
+	tmp, err := os.CreateTemp(filepath.Dir(out), filepath.Base(out)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("%w - unable to create %s", err, *outFlag)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	err = writePack(vendoring.ArchiveDestination(out, tmp), files)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Temporary files are private to the user.
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), out)
+	}
+	if err != nil && interrupted() {
+		return errInterrupted("%s not written", *outFlag)
+	}
+	if err != nil {
+		return fmt.Errorf("%w - unable to write %s", err, *outFlag)
+	}
+
+	if *verboseFlag {
+		for _, f := range files {
+			fmt.Println(f.Name)
+		}
+	}
+	fmt.Printf("packed %d files into %s\n", len(files), *outFlag)
+	return nil
+}
+
+// listPackFiles lists the files of ./vendor/ sorted by name. Symlinks are
+// archived as the file they point to.
+func listPackFiles(cwd string) ([]packFile, error) {
+	var files []packFile
+	err := filepath.WalkDir(filepath.Join(cwd, "vendor"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(cwd, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, packFile{Name: filepath.ToSlash(rel), Path: path, Exec: info.Mode()&0111 != 0})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, err
+}
+
+// packInfo describes a packed file, whatever its modification time and
+// permissions within ./vendor/.
+type packInfo struct {
+	name string
+	size int64
+	exec bool
+}
+
+func (i packInfo) Name() string       { return path.Base(i.name) }
+func (i packInfo) Size() int64        { return i.size }
+func (i packInfo) ModTime() time.Time { return packTime }
+func (i packInfo) IsDir() bool        { return false }
+func (i packInfo) Sys() interface{}   { return nil }
+
+func (i packInfo) Mode() fs.FileMode {
+	if i.exec {
+		return 0755
+	}
+	return 0644
+}
+
+// writePack writes files to the archive dst.
+func writePack(dst vendoring.Destination, files []packFile) error {
+	for _, f := range files {
+		r, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		info, err := r.Stat()
+		if err == nil {
+			err = vendoring.Write(runCtx, dst, f.Name, packInfo{name: f.Name, size: info.Size(), exec: f.Exec}, r)
+		}
+		_ = r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return dst.Close()
+}
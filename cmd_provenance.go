@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+var provenanceCmd = &command{
+	Name:  "provenance",
+	Short: "print where a file under ./vendor/ came from: module, go.sum hash, source and copy time",
+	Run:   runProvenance,
+}
+
+// provenance is the record of a vendored file, as printed by -json.
+type provenance struct {
+	Path           string `json:"path"` // relative to the output directory
+	Module         string `json:"module"`
+	Version        string `json:"version"`
+	Replace        string `json:"replace,omitempty"`
+	ReplaceVersion string `json:"replaceVersion,omitempty"`
+	GoSum          string `json:"goSum,omitempty"`
+	Private        bool   `json:"private,omitempty"` // see privateModule
+	Source         string `json:"source"`
+	Sum            string `json:"sha256"` // prefixed with the algorithm unless sha256
+	Copied         string `json:"copied,omitempty"`
+}
+
+func runProvenance(args []string) error {
+	flags := newFlagSet("provenance", "[flags] vendor/<path>...",
+		"Provenance prints, for each given file, what the manifest recorded when it was\ncopied: the module path and version, and replacement if any, the go.sum hash of\nthe module, the source path, the checksum of the file and when it was copied.")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	jsonFlag := flags.Bool("json", false, "print the records as a JSON array")
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := loadManifest(cwd)
+	if err != nil {
+		return err
+	}
+	type recorded struct {
+		mm *ManifestModule
+		f  *ManifestFile
+	}
+	byPath := map[string]recorded{}
+	for _, mm := range manifest.Modules {
+		for i := range mm.Files {
+			byPath[mm.Files[i].Path] = recorded{mm, &mm.Files[i]}
+		}
+	}
+
+	failed := false
+	records := []*provenance{}
+	for _, arg := range flags.Args() {
+		path := vendorRelPath(cwd, arg)
+		r, ok := byPath[path]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s was not copied by modvendor\n", outName(path))
+			failed = true
+			continue
+		}
+		records = append(records, &provenance{
+			Path:           path,
+			Module:         r.mm.Path,
+			Version:        r.mm.Version,
+			Replace:        r.mm.Replace,
+			ReplaceVersion: r.mm.ReplaceVersion,
+			GoSum:          r.mm.GoSum,
+			Private:        privateModule(r.mm.Path),
+			Source:         r.mm.Source(r.f),
+			Sum:            r.f.Sum,
+			Copied:         r.f.copiedTime(),
+		})
+	}
+
+	if *jsonFlag {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		for i, p := range records {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(outName(p.Path))
+			fmt.Printf("  module:   %s %s\n", p.Module, p.Version)
+			if p.Replace != "" {
+				fmt.Printf("  replace:  %s\n", joinNonEmpty(p.Replace, p.ReplaceVersion))
+			}
+			fmt.Printf("  go.sum:   %s\n", orUnknown(p.GoSum))
+			if p.Private {
+				fmt.Printf("  private:  not in the checksum database, go.sum only\n")
+			}
+			fmt.Printf("  source:   %s\n", p.Source)
+			fmt.Printf("  checksum: %s\n", p.Sum)
+			fmt.Printf("  copied:   %s\n", orUnknown(p.Copied))
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+func joinNonEmpty(a, b string) string {
+	if b == "" {
+		return a
+	}
+	return a + " " + b
+}
+
+// orUnknown returns s, or what an empty s means to readers.
+func orUnknown(s string) string {
+	if s == "" {
+		return "(not recorded)"
+	}
+	return s
+}
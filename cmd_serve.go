@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+var serveCmd = &command{
+	Name:  "serve",
+	Short: "serve the status and manifest of ./vendor/ and re-run copy over localhost HTTP",
+	Run:   runServe,
+}
+
+func runServe(args []string) error {
+	flags := newFlagSet("serve", "[-addr host:port] [-- copy flags]",
+		"Serve answers over HTTP, on a loopback address only, for IDE plugins and build\ndaemons:\n\n\tGET  /status    the status of the vendored files of every module, as JSON\n\tGET  /manifest  ./vendor/"+manifestName+"\n\tPOST /sync      runs copy with the given flags, and returns its output\n\nRequests sent by web pages, with an Origin header, are refused.")
+	addrFlag := flags.String("addr", "127.0.0.1:7766", "loopback address to listen on")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(*addrFlag)
+	if err != nil {
+		return whoops("invalid -addr %s, use host:port", *addrFlag)
+	}
+	if !loopbackHost(host) {
+		return whoops("-addr %s isn't a loopback address, serve only listens locally", *addrFlag)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%w - unable to locate modvendor", err)
+	}
+
+	ln, err := net.Listen("tcp", *addrFlag)
+	if err != nil {
+		return fmt.Errorf("%w - unable to listen on %s", err, *addrFlag)
+	}
+	s := &server{cwd: cwd, exe: exe, copyArgs: flags.Args()}
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	handleInterrupts()
+	go func() {
+		<-runCtx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+	fmt.Printf("serving %s/ on http://%s\n", outName(""), ln.Addr())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// server answers the requests of serve.
+type server struct {
+	cwd      string
+	exe      string
+	copyArgs []string
+
+	// mu serializes syncs, as runs of copy lock the output directory.
+	mu sync.Mutex
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.status)
+	mux.HandleFunc("/manifest", s.manifest)
+	mux.HandleFunc("/sync", s.sync)
+	return localOnly(mux)
+}
+
+// localOnly refuses requests to other hosts, as DNS rebinding sends, and
+// those of web pages.
+func localOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !loopbackHost(host) || r.Header.Get("Origin") != "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveStatus is the answer to GET /status.
+type serveStatus struct {
+	LastRun *time.Time         `json:"lastRun,omitempty"`
+	Modules []*moduleStatusRow `json:"modules"`
+}
+
+func (s *server) status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	manifest, err := previousManifest(s.cwd)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	st := &serveStatus{}
+	if manifest == nil {
+		manifest = &Manifest{}
+	} else {
+		st.LastRun = &manifest.Generated
+	}
+	if st.Modules, err = moduleStatuses(s.cwd, manifest); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *server) manifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := os.ReadFile(manifestPath(s.cwd))
+	if os.IsNotExist(err) {
+		http.Error(w, "modvendor never ran", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// serveSync is the answer to POST /sync.
+type serveSync struct {
+	OK       bool   `json:"ok"`
+	Output   string `json:"output"`
+	Duration string `json:"duration"`
+}
+
+func (s *server) sync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out bytes.Buffer
+	// Copy writes where serve reads, unless its flags say otherwise.
+	cmd := exec.CommandContext(r.Context(), s.exe, append([]string{"copy", "-o", outDir}, s.copyArgs...)...)
+	cmd.Dir = s.cwd
+	cmd.Stdout, cmd.Stderr = &out, &out
+	start := time.Now()
+	err := cmd.Run()
+	res := &serveSync{OK: err == nil, Output: out.String(), Duration: time.Since(start).Round(time.Millisecond).String()}
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		fmt.Printf("Warning! copy failed: %s\n", err.Error())
+	} else {
+		fmt.Printf("copied in %s\n", res.Duration)
+	}
+	writeJSON(w, status, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
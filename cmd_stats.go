@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+)
+
+var statsCmd = &command{
+	Name:  "stats",
+	Short: "report the files and bytes each module contributes to ./vendor/",
+	Run:   runStats,
+}
+
+// moduleStats is what the assets of a module add to ./vendor/.
+type moduleStats struct {
+	Path  string
+	Files int
+	Size  int64
+	Exts  map[string]int64 // bytes per file extension
+}
+
+func runStats(args []string) error {
+	flags := newFlagSet("stats", "[flags]",
+		"Stats reports how many files and bytes the assets of each module, as recorded\nin ./vendor/"+manifestName+", contribute to ./vendor/, heaviest first.")
+	topFlag := flags.Int("top", 0, "also list the given number of largest vendored files")
+	extFlag := flags.Bool("ext", false, "break the size of each module down by file extension")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := loadManifest(cwd)
+	if err != nil {
+		return err
+	}
+
+	type vendorFile struct {
+		Path string
+		Size int64
+	}
+	var files []vendorFile
+	var stats []*moduleStats
+	var total moduleStats
+	for _, mm := range manifest.Modules {
+		s := &moduleStats{Path: mm.Path, Exts: map[string]int64{}}
+		for _, f := range mm.Files {
+			// Missing files contribute nothing, verify reports them.
+			info, err := os.Stat(outPath(cwd, f.Path))
+			if err != nil {
+				continue
+			}
+			s.Files++
+			s.Size += info.Size()
+			s.Exts[path.Ext(f.Path)] += info.Size()
+			files = append(files, vendorFile{f.Path, info.Size()})
+		}
+		total.Files += s.Files
+		total.Size += s.Size
+		stats = append(stats, s)
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Size > stats[j].Size
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MODULE\tFILES\tSIZE\tSHARE")
+	for _, s := range stats {
+		_, _ = fmt.Fprintf(w, "%s%s\t%d\t%s\t%s\n", s.Path, privateNote(s.Path), s.Files, formatSize(s.Size), share(s.Size, total.Size))
+		if !*extFlag {
+			continue
+		}
+		exts := make([]string, 0, len(s.Exts))
+		for ext := range s.Exts {
+			exts = append(exts, ext)
+		}
+		sort.Slice(exts, func(i, j int) bool {
+			return s.Exts[exts[i]] > s.Exts[exts[j]]
+		})
+		for _, ext := range exts {
+			name := ext
+			if name == "" {
+				name = "(none)"
+			}
+			_, _ = fmt.Fprintf(w, "  %s\t\t%s\t%s\n", name, formatSize(s.Exts[ext]), share(s.Exts[ext], s.Size))
+		}
+	}
+	_, _ = fmt.Fprintf(w, "total\t%d\t%s\t\n", total.Files, formatSize(total.Size))
+	_ = w.Flush()
+
+	if *topFlag > 0 {
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].Size > files[j].Size
+		})
+		if len(files) > *topFlag {
+			files = files[:*topFlag]
+		}
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, f := range files {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", outName(f.Path), formatSize(f.Size))
+		}
+		_ = w.Flush()
+	}
+	return nil
+}
+
+// share formats n as a percentage of total.
+func share(n, total int64) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
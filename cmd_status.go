@@ -0,0 +1,203 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+var statusCmd = &command{
+	Name:  "status",
+	Short: "summarize the state of vendored assets per module",
+	Run:   runStatus,
+}
+
+func runStatus(args []string) error {
+	flags := newFlagSet("status", "",
+		"Status reports, per module in ./vendor/modules.txt, whether assets are vendored,\nwhether they still match ./vendor/"+manifestName+" and the module cache, and\ntheir total size, along with when modvendor last ran.")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := previousManifest(cwd)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		fmt.Println("modvendor never ran, no assets are vendored")
+		return nil
+	}
+	fmt.Printf("last run: %s\n\n", manifest.Generated.Local().Format("2006-01-02 15:04:05 MST"))
+
+	rows, err := moduleStatuses(cwd, manifest)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MODULE\tVERSION\tFILES\tSIZE\tSTATUS")
+	for _, row := range rows {
+		if row.Private {
+			row.Status = append(row.Status, "private")
+		}
+		if !row.Vendored {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t-\t-\t%s\n", row.Path, row.Version, strings.Join(row.Status, ", "))
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", row.Path, row.Version, row.Files, formatSize(row.Size), strings.Join(row.Status, ", "))
+	}
+	_ = w.Flush()
+	return nil
+}
+
+// moduleStatusRow is the status of a module, as status prints it.
+type moduleStatusRow struct {
+	Path     string   `json:"path"`
+	Version  string   `json:"version"`
+	Vendored bool     `json:"vendored"`
+	Files    int      `json:"files"`
+	Size     int64    `json:"size"`
+	Status   []string `json:"status"`
+	Private  bool     `json:"private,omitempty"` // see privateModule
+}
+
+// moduleStatuses returns the status of every module of ./vendor/modules.txt,
+// then of those the manifest records which aren't in it anymore.
+func moduleStatuses(cwd string, manifest *Manifest) ([]*moduleStatusRow, error) {
+	byPath := map[string]*ManifestModule{}
+	for _, mm := range manifest.Modules {
+		byPath[mm.Path] = mm
+	}
+
+	modules, err := readModulesTxt(cwd)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*moduleStatusRow
+	for _, mod := range modules {
+		mm, ok := byPath[mod.ImportPath]
+		if !ok {
+			rows = append(rows, &moduleStatusRow{Path: mod.ImportPath, Version: mod.Version, Status: []string{"no assets"}})
+			continue
+		}
+		delete(byPath, mod.ImportPath)
+
+		size, status := moduleStatus(cwd, mm)
+		if mm.Version != mod.Version {
+			status = append([]string{"modules.txt has " + mod.Version}, status...)
+		}
+		if len(status) == 0 {
+			status = []string{"ok"}
+		}
+		rows = append(rows, &moduleStatusRow{Path: mm.Path, Version: mm.Version, Vendored: true, Files: len(mm.Files), Size: size, Status: status})
+	}
+	// Modules dropped from modules.txt since the last run.
+	for _, mm := range manifest.Modules {
+		if _, ok := byPath[mm.Path]; ok {
+			size, _ := moduleStatus(cwd, mm)
+			rows = append(rows, &moduleStatusRow{Path: mm.Path, Version: mm.Version, Vendored: true, Files: len(mm.Files), Size: size, Status: []string{"not in modules.txt anymore"}})
+		}
+	}
+	for _, row := range rows {
+		row.Private = privateModule(row.Path)
+	}
+	return rows, nil
+}
+
+// moduleStatus returns the size of the vendored files of mm and a list of
+// problems, if any.
+func moduleStatus(cwd string, mm *ManifestModule) (int64, []string) {
+	var size int64
+	var missing, modified, outdated int
+	cacheMissing := false
+
+	var zipFiles map[string]*zip.File
+	if mm.Zip != "" {
+		zr, err := zip.OpenReader(mm.Zip)
+		if err != nil {
+			cacheMissing = true
+		} else {
+			defer func() {
+				_ = zr.Close()
+			}()
+			zipFiles = map[string]*zip.File{}
+			for _, f := range zr.File {
+				zipFiles[mm.Zip+"!"+f.Name] = f
+			}
+		}
+	} else if _, err := os.Stat(mm.Dir); err != nil {
+		cacheMissing = true
+	}
+
+	for i := range mm.Files {
+		f := &mm.Files[i]
+		vendorFile := outPath(cwd, f.Path)
+		info, err := os.Stat(vendorFile)
+		if err != nil {
+			missing++
+			continue
+		}
+		size += info.Size()
+		if sum, err := fileSum(vendorFile, checksum.Of(f.Sum)); err != nil || sum != f.Sum {
+			modified++
+			continue
+		}
+		if cacheMissing {
+			continue
+		}
+
+		var sum string
+		if zipFiles != nil {
+			sum, err = zipEntrySum(zipFiles[mm.Source(f)], checksum.Of(f.Sum))
+		} else {
+			sum, err = fileSum(mm.Source(f), checksum.Of(f.Sum))
+		}
+		if err != nil || sum != f.Sum {
+			outdated++
+		}
+	}
+
+	var status []string
+	if missing > 0 {
+		status = append(status, fmt.Sprintf("%d missing", missing))
+	}
+	if modified > 0 {
+		status = append(status, fmt.Sprintf("%d modified", modified))
+	}
+	if cacheMissing {
+		status = append(status, "module cache missing")
+	} else if outdated > 0 {
+		status = append(status, fmt.Sprintf("%d out of date with module cache", outdated))
+	}
+	return size, status
+}
+
+func zipEntrySum(f *zip.File, a checksum.Algorithm) (string, error) {
+	if f == nil {
+		return "", os.ErrNotExist
+	}
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	h := a.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return a.Sum(h), nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/internal/copyer"
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+var verifyCmd = &command{
+	Name:  "verify",
+	Short: "check that ./vendor/ still matches what modvendor copied",
+	Run:   runVerify,
+}
+
+func runVerify(args []string) error {
+	flags := newFlagSet("verify", "[flags]",
+		"Verify checks that every file recorded in ./vendor/"+manifestName+" exists and is\nunmodified. With -gosum the module cache content of the recorded modules is\nverified against go.sum too. With -deep every recorded file is byte-compared\nwith its source in the module cache, not trusting the manifest checksums. With\n-strict files neither go mod vendor nor modvendor wrote fail the check too.")
+	goSumFlag := flags.Bool("gosum", false, "also verify module cache content against go.sum")
+	deepFlag := flags.Bool("deep", false, "also byte-compare vendored files with the module cache")
+	strictFlag := flags.Bool("strict", false, "also fail on files under ./vendor/ that neither go mod vendor nor modvendor wrote")
+	verboseFlag := flags.Bool("v", false, "verbose output")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	registerOutput(flags)
+	_ = flags.Parse(args)
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := loadManifest(cwd)
+	if err != nil {
+		return err
+	}
+
+	report, err := vendoring.Verify(runCtx, vendoring.Options{Dir: outPath(cwd, "")})
+	if err != nil {
+		return err
+	}
+	for _, p := range report.Problems {
+		problem(outName(p.File), "%s: %s", p.Kind, outName(p.File))
+	}
+	failed := !report.OK()
+	if *verboseFlag {
+		for _, mm := range manifest.Modules {
+			fmt.Printf("checked %s (%d files)\n", mm.Path, len(mm.Files))
+		}
+	}
+
+	if *strictFlag {
+		unknown, err := unknownFiles(cwd, manifest)
+		if err != nil {
+			return fmt.Errorf("%w - unable to list the files of %s/", err, outName(""))
+		}
+		for _, file := range unknown {
+			problem(outName(file), "unknown: %s", outName(file))
+		}
+		failed = failed || len(unknown) > 0
+	}
+
+	if *deepFlag {
+		ok, err := verifyDeep(cwd, manifest, *verboseFlag)
+		if err != nil {
+			return err
+		}
+		failed = failed || !ok
+	}
+
+	if *goSumFlag {
+		recorded := map[string]bool{}
+		for _, mm := range manifest.Modules {
+			recorded[mm.Path] = true
+		}
+		sums, err := loadGoSum(cwd)
+		if err != nil {
+			return err
+		}
+		modules, err := loadModules(cwd, &vendorOptions{})
+		if err != nil {
+			return err
+		}
+		for _, mod := range modules {
+			if !recorded[mod.ImportPath] {
+				continue
+			}
+			err := verifyMod(mod, sums)
+			switch {
+			case errors.Is(err, errPrivateNoSum):
+				warn("go.sum", "%s", err.Error())
+			case err != nil:
+				problem("go.sum", "Error! %s", err.Error())
+				failed = true
+			case *verboseFlag:
+				fmt.Printf("verified %s%s\n", mod.ImportPath, privateNote(mod.ImportPath))
+			}
+		}
+	}
+
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+// verifyDeep byte-compares the files recorded in manifest with their source
+// in the module cache, as located by ./vendor/modules.txt and the replace
+// directives rather than the manifest. It reports whether all of them match.
+func verifyDeep(cwd string, manifest *Manifest, verbose bool) (bool, error) {
+	loaded, err := loadModules(cwd, &vendorOptions{})
+	if err != nil {
+		return false, err
+	}
+	modules := map[string]*Mod{}
+	for _, mod := range loaded {
+		modules[mod.ImportPath] = mod
+	}
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	ok := true
+	for _, mm := range manifest.Modules {
+		mod := modules[mm.Path]
+		if mod == nil {
+			problem(outName(manifestName), "unknown module: %s is not in vendor/modules.txt", mm.Path)
+			ok = false
+			continue
+		}
+		for i := range mm.Files {
+			f := &mm.Files[i]
+			e := &vendorEntry{Mod: mod, Src: filepath.Join(mod.Dir, filepath.FromSlash(mm.Name(f))), Dest: f.Path}
+			off, same, err := compareSource(sources, e, outPath(cwd, f.Path))
+			switch {
+			case os.IsNotExist(err):
+				// Missing vendored files were reported already.
+				if _, err := os.Stat(outPath(cwd, f.Path)); err == nil {
+					problem(outName(f.Path), "no source: %s is not in %s", outName(f.Path), mod)
+					ok = false
+				}
+			case err != nil:
+				return false, fmt.Errorf("%w - unable to compare %s", err, outName(f.Path))
+			case !same:
+				problem(outName(f.Path), "differs: %s from %s at byte %d", outName(f.Path), mod, off)
+				ok = false
+			}
+		}
+		if verbose {
+			fmt.Printf("compared %s (%d files)\n", mm.Path, len(mm.Files))
+		}
+	}
+	return ok, nil
+}
+
+// compareSource compares the source of e with the file at path byte by byte,
+// it returns the offset of the first difference.
+func compareSource(sources *sourceFiles, e *vendorEntry, path string) (int64, bool, error) {
+	src, err := sources.open(e)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	dst, err := os.Open(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		_ = dst.Close()
+	}()
+
+	a, b := bufio.NewReader(src), bufio.NewReader(dst)
+	for off := int64(0); ; off++ {
+		x, errA := a.ReadByte()
+		y, errB := b.ReadByte()
+		if errA == io.EOF && errB == io.EOF {
+			return off, true, nil
+		}
+		if errA != nil && errA != io.EOF {
+			return off, false, errA
+		}
+		if errB != nil && errB != io.EOF {
+			return off, false, errB
+		}
+		if errA != nil || errB != nil || x != y {
+			return off, false, nil
+		}
+	}
+}
+
+// loadManifest reads the manifest of the project in cwd, a missing manifest
+// means modvendor never ran.
+func loadManifest(cwd string) (*Manifest, error) {
+	manifest, err := readManifest(manifestPath(cwd))
+	if os.IsNotExist(err) {
+		return nil, whoops("cannot find %s, first run `modvendor copy` and try again", outName(manifestName))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w - unable to read %s", err, outName(manifestName))
+	}
+	manifest.resolveDirs(cwd)
+	return manifest, nil
+}
+
+// previousManifest returns the manifest of the last run, or nil when there is
+// none.
+func previousManifest(cwd string) (*Manifest, error) {
+	manifest, err := readManifest(manifestPath(cwd))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w - unable to read %s", err, outName(manifestName))
+	}
+	manifest.resolveDirs(cwd)
+	return manifest, nil
+}
+
+// fileSum returns the checksum of the file content, by the algorithm.
+func fileSum(path string, a checksum.Algorithm) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := a.New()
+	if _, err := copyer.Buffered(h, f); err != nil {
+		return "", err
+	}
+	return a.Sum(h), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+var versionCmd = &command{
+	Name:  "version",
+	Short: "print modvendor's version, commit and Go toolchain",
+	Run:   runVersion,
+}
+
+// buildVersion identifies the modvendor build, it is recorded in the manifest.
+type buildVersion struct {
+	Version  string `json:"version"`
+	Revision string `json:"revision,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+	Go       string `json:"go"`
+}
+
+func currentVersion() buildVersion {
+	v := buildVersion{Version: "unknown", Go: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	v.Version = info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+}
+
+func (v buildVersion) String() string {
+	s := "modvendor " + v.Version
+	if v.Revision != "" {
+		s += " commit " + v.Revision
+		if v.Modified {
+			s += "-dirty"
+		}
+	}
+	return s + " " + v.Go
+}
+
+func runVersion(args []string) error {
+	flags := newFlagSet("version", "",
+		"Version prints the version of modvendor, the commit it was built from and the\nGo toolchain used to build it.")
+	_ = flags.Parse(args)
+
+	fmt.Printf("%s %s/%s\n", currentVersion(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
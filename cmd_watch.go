@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var watchCmd = &command{
+	Name:  "watch",
+	Short: "re-run copy whenever go.mod, go.sum or local replace targets change",
+	Run:   runWatch,
+}
+
+// watchState is the modification time and size of every watched file.
+type watchState map[string]string
+
+func runWatch(args []string) error {
+	flags := newFlagSet("watch", "[-interval duration] [-- copy flags]",
+		"Watch runs copy with the given flags, then again whenever go.mod, go.sum,\ngo.work, vendor/modules.txt, "+configName+" or any file within the directory of\na local replace changes. Changes are polled for.")
+	intervalFlag := flags.Duration("interval", time.Second, "how often to check for changes")
+	_ = flags.Parse(args)
+	copyArgs := flags.Args()
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%w - unable to locate modvendor", err)
+	}
+
+	state := watchSnapshot(cwd)
+	rerunCopy(exe, copyArgs)
+	for {
+		time.Sleep(*intervalFlag)
+		next := watchSnapshot(cwd)
+		changed := state.changed(next)
+		if len(changed) == 0 {
+			continue
+		}
+		state = next
+		for _, path := range changed {
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = rel
+			}
+			fmt.Printf("changed %s\n", path)
+		}
+		rerunCopy(exe, copyArgs)
+	}
+}
+
+// rerunCopy runs copy in a child process, a failing run is reported and
+// watching goes on.
+func rerunCopy(exe string, args []string) {
+	cmd := exec.Command(exe, append([]string{"copy"}, args...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Warning! copy failed: %s, waiting for changes\n", err.Error())
+		return
+	}
+	fmt.Printf("copied in %s, waiting for changes\n", time.Since(start).Round(time.Millisecond))
+}
+
+// watchSnapshot stats the project files and local replace targets of cwd.
+func watchSnapshot(cwd string) watchState {
+	s := watchState{}
+	files, _ := moduleFiles(cwd)
+	files = append(files,
+		filepath.Join(cwd, "vendor", "modules.txt"),
+		filepath.Join(cwd, configName),
+	)
+	for _, path := range files {
+		s.add(path)
+	}
+
+	// While go.mod is being edited it may not parse, the next snapshot
+	// picks replaces up once it does.
+	replaceDirs, err := loadReplaces(cwd)
+	if err != nil {
+		return s
+	}
+	seen := map[string]bool{}
+	for _, r := range replaceDirs {
+		if !r.local() || seen[r.Dir] {
+			continue
+		}
+		seen[r.Dir] = true
+		_ = filepath.WalkDir(r.Dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			s.add(path)
+			return nil
+		})
+	}
+	return s
+}
+
+func (s watchState) add(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	s[path] = fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+}
+
+// changed returns the files added, removed or modified in next, sorted.
+func (s watchState) changed(next watchState) []string {
+	var paths []string
+	for path, v := range next {
+		if s[path] != v {
+			paths = append(paths, path)
+		}
+	}
+	for path := range s {
+		if _, ok := next[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
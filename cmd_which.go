@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var whichCmd = &command{
+	Name:  "which",
+	Short: "print where a file under ./vendor/ was copied from",
+	Run:   runWhich,
+}
+
+func runWhich(args []string) error {
+	flags := newFlagSet("which", "vendor/<path>...",
+		"Which prints, for each given file, the module cache or replace directory path\nit was copied from on the last run, followed by the module version.")
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	cwd, err := projectRoot()
+	if err != nil {
+		return err
+	}
+	manifest, err := loadManifest(cwd)
+	if err != nil {
+		return err
+	}
+	type recorded struct {
+		mm *ManifestModule
+		f  *ManifestFile
+	}
+	byPath := map[string]recorded{}
+	for _, mm := range manifest.Modules {
+		for i := range mm.Files {
+			byPath[mm.Files[i].Path] = recorded{mm, &mm.Files[i]}
+		}
+	}
+
+	failed := false
+	for _, arg := range flags.Args() {
+		path := vendorRelPath(cwd, arg)
+		r, ok := byPath[path]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s was not copied by modvendor\n", outName(path))
+			failed = true
+			continue
+		}
+		fmt.Printf("%s\t%s\n", r.mm.Source(r.f), r.mm)
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
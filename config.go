@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"gopkg.in/yaml.v3"
+)
+
+const configName = ".modvendor.yml"
+
+// config is the project configuration in ./.modvendor.yml, its values are
+// the defaults of the flags of the same name.
+type config struct {
+	Copy     []string `yaml:"copy"`
+	FullCopy *bool    `yaml:"fullcopy"`
+	Include  []string `yaml:"include"`
+	Only     []string `yaml:"only"`
+	Skip     []string `yaml:"skip"`
+	Out      string   `yaml:"out"`
+	PreHook  string   `yaml:"pre-hook"`
+	PostHook string   `yaml:"post-hook"`
+	Scan     string   `yaml:"scan"`
+	Secrets  string   `yaml:"secrets"`
+
+	// Quarantine and AllowFiles are the directory of -quarantine and the
+	// files excused from the policy checks, see quarantine.go.
+	Quarantine string   `yaml:"quarantine"`
+	AllowFiles []string `yaml:"allow-files"`
+	Checksum   string   `yaml:"checksum"`
+	Safe       bool     `yaml:"safe"`
+	Offline    bool     `yaml:"offline"`
+	Audit      bool     `yaml:"audit"`
+
+	Reproducible bool `yaml:"reproducible"`
+
+	// Container and Chown are the defaults of -container and -chown, see
+	// container.go.
+	Container bool   `yaml:"container"`
+	Chown     string `yaml:"chown"`
+
+	// DenyTypes are the extensions safe denies, instead of defaultDenyTypes.
+	DenyTypes []string `yaml:"deny-types"`
+
+	// Sources tell where the files of modules come from, the first
+	// matching a module is used.
+	Sources []moduleSource `yaml:"sources"`
+
+	// Plugins are commands deciding which files to vendor, see plugin.go.
+	Plugins []string `yaml:"plugins"`
+
+	// Licenses is the policy vendored files are checked against, see
+	// checkLicenses.
+	Licenses *licensePolicy `yaml:"licenses"`
+
+	// AllowedLicenses are the licenses modules may have, see
+	// checkModuleLicenses.
+	AllowedLicenses []string `yaml:"allowed_licenses"`
+}
+
+func readConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// An empty file, or one with comments only, configures nothing.
+	if err := dec.Decode(&c); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadConfig reads the configuration of the project in the current
+// directory, or returns an empty one when there is none. It sets the
+// checksum algorithm.
+func loadConfig() (*config, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	c, err := readConfig(filepath.Join(cwd, configName))
+	if os.IsNotExist(err) {
+		return &config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w - unable to read %s", err, configName)
+	}
+	if sumAlgorithm, err = checksum.Parse(c.Checksum); err != nil {
+		return nil, fmt.Errorf("%w - unable to read %s", err, configName)
+	}
+	return c, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// With -container, copy leaves the files of the output directory as rootless
+// container builds want them, whatever the umask and the modes of the
+// sources: files 0644, or 0755 when executable by anyone, directories 0755,
+// no setuid, setgid or sticky bit, and with -chown the given owner.
+
+// containerMode returns the mode -container gives a file of the mode.
+func containerMode(mode fs.FileMode) fs.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// normalizeModes gives the files, relative to the output directory, their
+// containerMode, and the directories they are in, up to the output
+// directory, 0755.
+func normalizeModes(cwd string, files []string) error {
+	for _, file := range files {
+		path := outPath(cwd, file)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if mode := containerMode(info.Mode()); info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) != mode {
+			if err := os.Chmod(path, mode); err != nil {
+				return err
+			}
+		}
+	}
+	for _, dir := range outputDirs(files) {
+		path := outPath(cwd, dir)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) != 0755 {
+			if err := os.Chmod(path, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// outputDirs returns the directories the files, relative to the output
+// directory, are in, up to it.
+func outputDirs(files []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, file := range files {
+		for dir := filepath.Dir(file); dir != "." && !seen[dir]; dir = filepath.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// owner is the numeric user and group of -chown.
+type owner struct {
+	uid, gid int
+}
+
+// parseOwner parses "uid:gid", as -chown takes it.
+func parseOwner(s string) (*owner, error) {
+	uid, gid, ok := strings.Cut(s, ":")
+	u, uerr := strconv.Atoi(uid)
+	g, gerr := strconv.Atoi(gid)
+	if !ok || uerr != nil || gerr != nil || u < 0 || g < 0 {
+		return nil, whoops("invalid -chown %q, want numeric uid:gid, e.g. 1000:1000", s)
+	}
+	return &owner{u, g}, nil
+}
+
+// chownFiles gives the files, relative to the output directory, and the
+// directories they are in, up to it, the owner.
+func chownFiles(cwd string, files []string, o *owner) error {
+	for _, name := range append(outputDirs(files), files...) {
+		if err := os.Lchown(outPath(cwd, name), o.uid, o.gid); err != nil {
+			return fmt.Errorf("%w - unable to change the owner to %d:%d", err, o.uid, o.gid)
+		}
+	}
+	return nil
+}
+
+// containerInfo is the FileInfo of a source as -container archives it.
+type containerInfo struct {
+	fs.FileInfo
+}
+
+func (i containerInfo) Mode() fs.FileMode {
+	return containerMode(i.FileInfo.Mode())
+}
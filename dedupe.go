@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// dedupeEntries hardlinks the vendored files of entries with the same
+// content and permissions to the first of them, by destination. It returns
+// the number of files linked and the bytes saved.
+func dedupeEntries(cwd string, entries []*vendorEntry, verbose bool) (int, int64, error) {
+	first := map[string]string{}
+	linked, saved := 0, int64(0)
+	for _, e := range entries {
+		path := outPath(cwd, e.Dest)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return linked, saved, err
+		}
+		key := fmt.Sprintf("%s %v", e.Sum, info.Mode())
+		orig, ok := first[key]
+		if !ok {
+			first[key] = path
+			continue
+		}
+		origInfo, err := os.Lstat(orig)
+		if err != nil {
+			return linked, saved, err
+		}
+		if os.SameFile(info, origInfo) {
+			continue
+		}
+
+		// Replace the file at once, it is never missing.
+		tmp := path + ".modvendor-link"
+		_ = os.Remove(tmp)
+		if err := os.Link(orig, tmp); err != nil {
+			return linked, saved, err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+			return linked, saved, err
+		}
+		if verbose {
+			fmt.Printf("linked %s to %s\n", e.Dest, orig)
+		}
+		linked++
+		saved += info.Size()
+	}
+	return linked, saved, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ansoda/modvendor/internal/copyer"
+)
+
+// deltaMin is the size from which -delta updates files in place, smaller
+// ones are cheaper to copy whole.
+const deltaMin = 1 << 20
+
+// markDelta marks the changed entries of files from deltaMin on, whose
+// vendored file is to be updated in place rather than copied again.
+func markDelta(cwd string, entries []*vendorEntry) {
+	for _, e := range entries {
+		if e.Unchanged || e.Mod.Zip != "" {
+			continue
+		}
+		info, err := e.stat()
+		if err != nil || !info.Mode().IsRegular() || info.Size() < deltaMin || copyer.IsSparse(info) {
+			continue
+		}
+		dest, err := os.Lstat(outPath(cwd, e.Dest))
+		if err != nil || !dest.Mode().IsRegular() || dest.Size() < deltaMin {
+			continue
+		}
+		e.Delta = true
+	}
+}
+
+// patchEntries updates the vendored files of the entries marked by
+// markDelta, and records their checksum.
+func patchEntries(cwd string, entries []*vendorEntry, verbose bool) error {
+	files, written, size := 0, int64(0), int64(0)
+	for _, e := range entries {
+		if !e.Delta {
+			continue
+		}
+		if verbose {
+			fmt.Printf("updating %s\n", e.Dest)
+		}
+		src, err := e.resolved()
+		if err != nil {
+			return err
+		}
+		info, err := e.stat()
+		if err != nil {
+			return err
+		}
+		h := sumAlgorithm.New()
+		n, err := copyer.Patch(src, outPath(cwd, e.Dest), info, h)
+		if err != nil {
+			return fmt.Errorf("%s - unable to update %s", err.Error(), outName(e.Dest))
+		}
+		e.Sum = sumAlgorithm.Sum(h)
+		files++
+		written += n
+		size += info.Size()
+	}
+	if files > 0 {
+		fmt.Printf("updated %d files in place, writing %s of %s\n", files, formatSize(written), formatSize(size))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// defaultDenyTypes are the extensions -safe denies unless deny-types: lists
+// others: prebuilt libraries and executables, which broad patterns such as
+// "**/*" pick up along with the sources.
+var defaultDenyTypes = []string{".so", ".dll", ".dylib", ".exe", ".jar"}
+
+// deniedTypes returns the extensions -safe denies, none without it.
+func (o *vendorOptions) deniedTypes() []string {
+	if !o.safe {
+		return nil
+	}
+	return denyTypesOf(o.denyTypes)
+}
+
+// denyTypesOf returns the extensions of deny-types:, with or without their
+// dot, defaultDenyTypes if there are none.
+func denyTypesOf(types []string) []string {
+	if len(types) == 0 {
+		return defaultDenyTypes
+	}
+	exts := make([]string, len(types))
+	for i, ext := range types {
+		exts[i] = "." + strings.TrimPrefix(ext, ".")
+	}
+	return exts
+}
+
+// deniedType returns the extension among exts of the slash separated name,
+// ignoring case and the versions of shared libraries as in libz.so.1.2.11,
+// "" if it has none of them.
+func deniedType(name string, exts []string) string {
+	base := strings.ToLower(path.Base(name))
+	for {
+		ext := path.Ext(base)
+		if ext == "" || strings.Trim(ext[1:], "0123456789") != "" {
+			break
+		}
+		base = strings.TrimSuffix(base, ext)
+	}
+	ext := path.Ext(base)
+	for _, denied := range exts {
+		if ext != "" && strings.EqualFold(ext, denied) {
+			return denied
+		}
+	}
+	return ""
+}
+
+// checkDeniedTypes reports the files of entries of the extensions denied,
+// unless q excuses them, failing before any is written.
+func checkDeniedTypes(exts []string, entries []*vendorEntry, q *quarantine) error {
+	denied := 0
+	for _, e := range entries {
+		ext := deniedType(e.Dest, exts)
+		if ext == "" || q.excuse(e, "denied", fmt.Sprintf("a %s file, prebuilt libraries and executables aren't vendored with -safe", ext)) {
+			continue
+		}
+		problem(outName(e.Dest), "denied: %s of %s is a %s file, prebuilt libraries and executables aren't vendored with -safe", outName(e.Dest), e.Mod, ext)
+		denied++
+	}
+	if denied > 0 {
+		return failed("%d files of denied types, nothing written; narrow the copy patterns to leave them out", denied)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// entryDeps returns the sources of entries a depfile lists: module zips
+// rather than the files read from them.
+func entryDeps(entries []*vendorEntry) []string {
+	deps := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Mod.Zip != "" {
+			deps = append(deps, e.Mod.Zip)
+		} else {
+			deps = append(deps, e.Src)
+		}
+	}
+	return deps
+}
+
+// manifestDeps returns the sources of the files m records as entryDeps
+// does, for runs finding the output directory up to date.
+func manifestDeps(m *Manifest) []string {
+	var deps []string
+	for _, mm := range m.Modules {
+		for i := range mm.Files {
+			if mm.Zip != "" {
+				deps = append(deps, mm.Zip)
+			} else {
+				deps = append(deps, mm.Source(&mm.Files[i]))
+			}
+		}
+	}
+	return deps
+}
+
+// writeDepfile writes a Make style depfile to path, making the manifest
+// depend on the sources, see entryDeps, and on the project files deciding
+// what is vendored.
+func writeDepfile(path, cwd string, sources []string) error {
+	seen := map[string]bool{}
+	var deps []string
+	for _, dep := range sources {
+		if !seen[dep] {
+			seen[dep] = true
+			deps = append(deps, dep)
+		}
+	}
+	sort.Strings(deps)
+
+	// Project files changing the plan come first.
+	var project []string
+	for _, name := range []string{"go.mod", "go.sum", filepath.Join("vendor", "modules.txt"), configName} {
+		if _, err := os.Stat(filepath.Join(cwd, name)); err == nil {
+			project = append(project, name)
+		}
+	}
+	deps = append(project, deps...)
+
+	var b strings.Builder
+	b.WriteString(depfileEscape(filepath.ToSlash(outName(manifestName))) + ":")
+	for _, dep := range deps {
+		b.WriteString(" \\\n  " + depfileEscape(filepath.ToSlash(dep)))
+	}
+	b.WriteString("\n")
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// depfileEscape escapes the characters Make and Ninja treat specially in
+// file names.
+func depfileEscape(name string) string {
+	return strings.NewReplacer(" ", `\ `, "#", `\#`, "$", "$$").Replace(name)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path"
+	"sort"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/internal/copyer"
+)
+
+// vendorChange is a difference between the planned and current ./vendor/.
+type vendorChange struct {
+	Kind   byte   // 'A'dded, 'M'odified or 'D'eleted
+	Dest   string // slash separated path relative to ./vendor/
+	Module string
+	Entry  *vendorEntry // nil for deletions
+}
+
+// diffVendor compares the planned entries against the content of ./vendor/.
+// Files copied by the run recorded in manifest (may be nil) and no longer
+// planned are reported deleted. Changes are sorted by path.
+func diffVendor(cwd string, modules []*Mod, entries []*vendorEntry, manifest *Manifest) ([]vendorChange, error) {
+	var changes []vendorChange
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	for _, e := range entries {
+		vendorSum, err := fileSum(outPath(cwd, e.Dest), sumAlgorithm)
+		if os.IsNotExist(err) {
+			changes = append(changes, vendorChange{Kind: 'A', Dest: e.Dest, Module: e.Mod.ImportPath, Entry: e})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		srcSum, err := sourceSum(sources, e)
+		if err != nil {
+			return nil, err
+		}
+		if srcSum != vendorSum {
+			changes = append(changes, vendorChange{Kind: 'M', Dest: e.Dest, Module: e.Mod.ImportPath, Entry: e})
+		}
+	}
+
+	for _, c := range staleFiles(modules, entries, manifest) {
+		if _, err := os.Lstat(outPath(cwd, c.Dest)); err == nil {
+			changes = append(changes, c)
+		}
+	}
+
+	saveHashCache()
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Dest < changes[j].Dest
+	})
+	return changes, nil
+}
+
+// staleFiles returns deletions for the files recorded in manifest (may be
+// nil) which are no longer planned. Files right in a package directory listed
+// in modules.txt are left alone, `go mod vendor` puts those in place.
+func staleFiles(modules []*Mod, entries []*vendorEntry, manifest *Manifest) []vendorChange {
+	if manifest == nil {
+		return nil
+	}
+
+	planned := map[string]bool{}
+	for _, e := range entries {
+		planned[e.Dest] = true
+	}
+	goModVendor := map[string]bool{}
+	for _, mod := range modules {
+		if outName("") != "vendor" {
+			break
+		}
+		for _, pkg := range mod.VendoredPkgs {
+			goModVendor[pkg] = true
+		}
+	}
+
+	var stale []vendorChange
+	for _, mm := range manifest.Modules {
+		for _, f := range mm.Files {
+			if !planned[f.Path] && !goModVendor[path.Dir(f.Path)] {
+				stale = append(stale, vendorChange{Kind: 'D', Dest: f.Path, Module: mm.Path})
+			}
+		}
+	}
+	return stale
+}
+
+// sourceSum returns the checksum of the source content of e, looked up in
+// the hash cache first.
+func sourceSum(sources *sourceFiles, e *vendorEntry) (string, error) {
+	return sourceSumOf(sources, e, sumAlgorithm)
+}
+
+// sourceSumOf is sourceSum with the algorithm a, sums of algorithms other
+// than the configured one are cached apart.
+func sourceSumOf(sources *sourceFiles, e *vendorEntry, a checksum.Algorithm) (string, error) {
+	key, err := sourceKey(sources, e)
+	if err != nil {
+		return "", err
+	}
+	if a != sumAlgorithm {
+		key += "\t" + string(a)
+	}
+	cache := loadHashCache()
+	if sum, ok := cache.get(key, a); ok {
+		return sum, nil
+	}
+
+	r, err := sources.open(e)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	h := a.New()
+	if _, err := copyer.Buffered(h, r); err != nil {
+		return "", err
+	}
+	sum := a.Sum(h)
+	cache.put(key, sum)
+	return sum, nil
+}
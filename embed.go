@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// embedName is the file, within the output directory of each module,
+// generated by -embed.
+const embedName = "modvendor_embed.go"
+
+// writeEmbeds writes, for every module with non-Go assets among entries, a
+// package embedding them into the module's output directory. It returns the
+// generated file per module path, relative to the output directory.
+func writeEmbeds(cwd string, modules []*Mod, entries []*vendorEntry) (map[string]string, error) {
+	byMod := map[*Mod][]string{}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Dest, ".go") {
+			continue
+		}
+		byMod[e.Mod] = append(byMod[e.Mod], strings.TrimPrefix(e.Dest, e.Mod.ImportPath+"/"))
+	}
+
+	embeds := map[string]string{}
+	for _, mod := range modules {
+		files := byMod[mod]
+		if len(files) == 0 {
+			continue
+		}
+		sort.Strings(files)
+		src, err := embedSource(mod, files)
+		if err != nil {
+			return nil, err
+		}
+		dest := path.Join(mod.ImportPath, embedName)
+		if err := os.WriteFile(outPath(cwd, dest), src, 0644); err != nil {
+			return nil, err
+		}
+		embeds[mod.ImportPath] = dest
+	}
+	return embeds, nil
+}
+
+// embedSource renders the embedding package of mod, files are slash separated
+// and relative to the module root.
+func embedSource(mod *Mod, files []string) ([]byte, error) {
+	var b bytes.Buffer
+	name := embedPackageName(mod.ImportPath)
+	b.WriteString("// Code generated by modvendor; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// Package %s embeds the assets modvendor vendored from %s.\n", name, mod)
+	fmt.Fprintf(&b, "package %s\n\nimport \"embed\"\n\n", name)
+	b.WriteString("// FS holds the vendored assets, named by their path within the module.\n//\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "//go:embed %s\n", strconv.Quote(f))
+	}
+	b.WriteString("var FS embed.FS\n")
+	return format.Source(b.Bytes())
+}
+
+// embedPackageName derives a package name from the last element of the module
+// path, ignoring a major version suffix.
+func embedPackageName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(name))
+	if name == "" || name[0] >= '0' && name[0] <= '9' || token.IsKeyword(name) {
+		name = "assets"
+	}
+	return name
+}
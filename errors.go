@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Commands return their errors, main reports them and exits: errors as
+// "Error! ...", mistakes in how modvendor is invoked or set up as
+// "Whoops, ...".
+
+// usageError is a mistake of the user rather than a failure.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// whoops returns the usage error with the message.
+func whoops(format string, args ...interface{}) error {
+	return &usageError{fmt.Errorf(format, args...)}
+}
+
+// exitError exits with code, after printing msg to stderr unless it is
+// empty. Commands return it once they reported what failed themselves.
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.msg
+}
+
+// errReported exits with status 1, what failed was reported already.
+var errReported = &exitError{code: 1}
+
+// errUsage exits with status 2, as for unknown flags, once the usage was
+// printed.
+var errUsage = &exitError{code: 2}
+
+// failed returns the exit error printing the message.
+func failed(format string, args ...interface{}) error {
+	return &exitError{code: 1, msg: fmt.Sprintf(format, args...)}
+}
+
+// failures returns the error listing errs below the message, for failures
+// reported together once everything was tried.
+func failures(errs []error, format string, args ...interface{}) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, format, args...)
+	b.WriteString(":")
+	for _, err := range errs {
+		b.WriteString("\n\t" + strings.ReplaceAll(err.Error(), "\n", "\n\t"))
+	}
+	return &multiError{msg: b.String(), errs: errs}
+}
+
+// multiError is the error of failures, errors.Is and errors.As look into
+// every one of them.
+type multiError struct {
+	msg  string
+	errs []error
+}
+
+func (e *multiError) Error() string {
+	return e.msg
+}
+
+func (e *multiError) Unwrap() []error {
+	return e.errs
+}
+
+// exitStatus reports err and returns the status to exit with.
+func exitStatus(err error) int {
+	var u *usageError
+	var x *exitError
+	switch {
+	case errors.As(err, &u):
+		fmt.Printf("Whoops, %s\n", u.Error())
+		return 1
+	case errors.As(err, &x):
+		if x.msg != "" {
+			fmt.Fprintln(os.Stderr, x.msg)
+		}
+		return x.code
+	}
+	fmt.Printf("Error! %s\n", err.Error())
+	return 1
+}
@@ -4,10 +4,7 @@ go 1.18
 
 require (
 	github.com/mattn/go-zglob v0.0.3
-	github.com/otiai10/copy v1.14.0
-)
-
-require (
-	golang.org/x/sync v0.3.0 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	golang.org/x/sync v0.3.0
+	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8
+	gopkg.in/yaml.v3 v3.0.1
 )
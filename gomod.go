@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ansoda/modvendor/modtxt"
+)
+
+// replacement is the target of a replace directive. Version is empty when
+// the module is replaced with a local directory, Dir is then its absolute path.
+type replacement struct {
+	Path    string
+	Version string
+	Dir     string
+}
+
+func (r replacement) local() bool {
+	return r.Version == "" && isLocalPath(r.Path)
+}
+
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, ".") || strings.HasPrefix(path, "/") || filepath.IsAbs(path)
+}
+
+// replaces maps "<path>@<version>" and "<path>" (all versions) to the
+// replacement declared for them.
+type replaces map[string]replacement
+
+// resolve follows replace directives starting from t until it reaches a module
+// which isn't replaced any further, or a local directory.
+func (r replaces) resolve(t replacement) (replacement, error) {
+	seen := map[string]bool{}
+	for !t.local() {
+		key := t.Path + "@" + t.Version
+		if seen[key] {
+			return t, fmt.Errorf("replace directives for %s form a cycle", key)
+		}
+		seen[key] = true
+
+		next, ok := r[key]
+		if !ok {
+			next, ok = r[t.Path]
+		}
+		if !ok || next.Path == t.Path && next.Version == t.Version {
+			break
+		}
+		t = next
+	}
+	return t, nil
+}
+
+// loadReplaces reads replace directives of the main module's go.mod and, when
+// in workspace mode, go.work. The latter take precedence like they do for the
+// go command.
+func loadReplaces(cwd string) (replaces, error) {
+	r := replaces{}
+	goMods, err := goModFiles(cwd)
+	if err != nil {
+		return nil, err
+	}
+	for _, goMod := range goMods {
+		if err := r.read(goMod); err != nil {
+			return nil, err
+		}
+	}
+	if work := findGoWork(cwd); work != "" {
+		if err := r.read(work); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// findGoWork returns the go.work file in use for cwd, honoring $GOWORK.
+func findGoWork(cwd string) string {
+	if work, ok := os.LookupEnv("GOWORK"); ok {
+		if work == "off" {
+			return ""
+		}
+		return work
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+			return filepath.Join(dir, "go.work")
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// workspaceRoot reports whether cwd is the root of a workspace vendored as a
+// whole, by go work vendor: the directory of the go.work in use, without a
+// go.mod of its own.
+func workspaceRoot(cwd string) bool {
+	if _, err := os.Stat(filepath.Join(cwd, "go.mod")); err == nil {
+		return false
+	}
+	work := findGoWork(cwd)
+	return work != "" && filepath.Dir(work) == cwd
+}
+
+// goModFiles returns the go.mod of the project in cwd, or those of every
+// module of the workspace at a workspace root, whose replace directives and
+// go.sum files all apply.
+func goModFiles(cwd string) ([]string, error) {
+	if !workspaceRoot(cwd) {
+		return []string{filepath.Join(cwd, "go.mod")}, nil
+	}
+	dirs, err := workModules(findGoWork(cwd))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(dirs))
+	for i, dir := range dirs {
+		files[i] = filepath.Join(dir, "go.mod")
+	}
+	return files, nil
+}
+
+// moduleFiles returns goModFiles and goSumFiles, with go.work when in
+// workspace mode: the files telling which modules the project in cwd
+// requires.
+func moduleFiles(cwd string) ([]string, error) {
+	files, err := goModFiles(cwd)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := goSumFiles(cwd)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, sums...)
+	if work := findGoWork(cwd); work != "" {
+		files = append(files, work)
+	}
+	return files, nil
+}
+
+// workModules returns the absolute directories of the modules the use
+// directives of a go.work file list, in their order.
+func workModules(work string) ([]string, error) {
+	data, err := os.ReadFile(work)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	block := ""
+	for n, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		s := strings.Fields(line)
+		switch {
+		case len(s) == 0:
+			continue
+		case block != "":
+			if s[0] == ")" {
+				block = ""
+				continue
+			}
+		case len(s) == 2 && s[1] == "(":
+			block = s[0]
+			continue
+		case s[0] == "use":
+			s = s[1:]
+		default:
+			continue
+		}
+		if block != "" && block != "use" {
+			continue
+		}
+		if len(s) != 1 {
+			return nil, fmt.Errorf("%s:%d: invalid use directive %q", work, n+1, strings.Join(s, " "))
+		}
+		dir, err := unquote(s[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", work, n+1, err)
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(work), dir)
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs, nil
+}
+
+// read adds the replace directives of a go.mod or go.work file to r.
+func (r replaces) read(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	block := ""
+	for n, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		s := strings.Fields(line)
+		switch {
+		case len(s) == 0 || modtxt.IsDirective(s):
+			continue
+		case block != "":
+			if s[0] == ")" {
+				block = ""
+				continue
+			}
+		case len(s) == 2 && s[1] == "(":
+			block = s[0]
+			continue
+		case s[0] == "replace":
+			s = s[1:]
+		default:
+			continue
+		}
+		if block != "" && block != "replace" {
+			continue
+		}
+
+		from, to, err := parseReplace(s)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n+1, err)
+		}
+		if to.local() {
+			to.Dir = to.Path
+			if !filepath.IsAbs(to.Dir) {
+				to.Dir = filepath.Join(filepath.Dir(path), to.Dir)
+			}
+		}
+		r[from] = to
+	}
+	return nil
+}
+
+// parseReplace parses the fields of a replace directive ("a [v] => b [v]").
+func parseReplace(s []string) (string, replacement, error) {
+	for i := range s {
+		var err error
+		if s[i], err = unquote(s[i]); err != nil {
+			return "", replacement{}, err
+		}
+	}
+
+	arrow := -1
+	for i := range s {
+		if s[i] == "=>" {
+			arrow = i
+		}
+	}
+	if arrow < 1 || arrow > 2 || len(s)-arrow < 2 || len(s)-arrow > 3 {
+		return "", replacement{}, fmt.Errorf("invalid replace directive %q", strings.Join(s, " "))
+	}
+
+	from := s[0]
+	if arrow == 2 {
+		from += "@" + s[1]
+	}
+	to := replacement{Path: s[arrow+1]}
+	if len(s)-arrow == 3 {
+		to.Version = s[arrow+2]
+	}
+	return from, to, nil
+}
+
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "`") {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
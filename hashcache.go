@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+// hashCacheMax bounds the number of cached checksums, when exceeded those
+// not looked up by the current run are dropped.
+const hashCacheMax = 200000
+
+// hashCache maps the path, size and modification time of source files to the
+// checksum of their content, across runs and projects. Sums of another
+// algorithm than the one in use are ignored. It is only a
+// cache, failing to read or write it is not an error.
+type hashCache struct {
+	mu    sync.Mutex
+	path  string
+	sums  map[string]string
+	used  map[string]bool
+	dirty bool
+}
+
+var (
+	hashes     *hashCache
+	hashesOnce sync.Once
+)
+
+// loadHashCache reads the cache from the user cache directory once.
+func loadHashCache() *hashCache {
+	hashesOnce.Do(func() {
+		hashes = &hashCache{sums: map[string]string{}, used: map[string]bool{}}
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return
+		}
+		hashes.path = filepath.Join(dir, "modvendor", "hashes.json")
+		if data, err := os.ReadFile(hashes.path); err == nil {
+			_ = json.Unmarshal(data, &hashes.sums)
+		}
+	})
+	return hashes
+}
+
+// sourceKey identifies the content of the source of e, a file in the
+// module cache or an entry of a module zip.
+func sourceKey(sources *sourceFiles, e *vendorEntry) (string, error) {
+	info, err := sources.stat(e)
+	if err != nil {
+		return "", err
+	}
+	size, modTime := info.Size(), info.ModTime()
+	if e.Mod.Zip != "" {
+		h, ok := sources.zipHeader(e)
+		if !ok {
+			return "", fmt.Errorf("%s: no zip header for %s", e.Mod.Zip, e.Src)
+		}
+		return fmt.Sprintf("%s#%s\t%d\t%d\t%08x", e.Mod.Zip, h.Name, size, modTime.UnixNano(), h.CRC32), nil
+	}
+	return fmt.Sprintf("%s\t%d\t%d", e.Src, size, modTime.UnixNano()), nil
+}
+
+// cachedSum returns the cached checksum of the source of e, if any.
+func cachedSum(sources *sourceFiles, e *vendorEntry) (string, bool) {
+	key, err := sourceKey(sources, e)
+	if err != nil {
+		return "", false
+	}
+	return loadHashCache().get(key, sumAlgorithm)
+}
+
+func (c *hashCache) get(key string, a checksum.Algorithm) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sum, ok := c.sums[key]
+	if !ok || checksum.Of(sum) != a {
+		return "", false
+	}
+	c.used[key] = true
+	return sum, true
+}
+
+func (c *hashCache) put(key, sum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sums[key] != sum {
+		c.sums[key] = sum
+		c.dirty = true
+	}
+	c.used[key] = true
+}
+
+// saveHashCache writes the cache back, if it was loaded and changed.
+func saveHashCache() {
+	c := hashes
+	if c == nil || !c.dirty || c.path == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sums) > hashCacheMax {
+		for key := range c.sums {
+			if !c.used[key] {
+				delete(c.sums, key)
+			}
+		}
+	}
+	data, err := json.Marshal(c.sums)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return
+	}
+	// Runs of other projects may be writing it as well.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), "hashes.json.tmp*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	c.dirty = false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// hookPlan is what a copy run vendored, as the post hook reads it from
+// $MODVENDOR_PLAN.
+type hookPlan struct {
+	Patterns []string    `json:"patterns"`
+	Files    []*hookFile `json:"files"`
+}
+
+type hookFile struct {
+	Module    string `json:"module"`
+	Src       string `json:"src"`
+	Dest      string `json:"dest"` // slash separated, relative to the output directory
+	Sum       string `json:"sum"`
+	Unchanged bool   `json:"unchanged,omitempty"`
+}
+
+func newHookPlan(patterns []string, entries []*vendorEntry) *hookPlan {
+	p := &hookPlan{Patterns: patterns, Files: make([]*hookFile, 0, len(entries))}
+	for _, e := range entries {
+		p.Files = append(p.Files, &hookFile{Module: e.Mod.ImportPath, Src: e.Src, Dest: e.Dest, Sum: e.Sum, Unchanged: e.Unchanged})
+	}
+	return p
+}
+
+// runHook runs command with the shell in cwd. The output directory and
+// manifest are passed as $MODVENDOR_OUT and $MODVENDOR_MANIFEST, plan, unless
+// nil, is written to a temporary file passed as $MODVENDOR_PLAN.
+func runHook(cwd, command string, plan *hookPlan) error {
+	return runHookIn(cwd, cwd, command, plan)
+}
+
+// runScanner runs the scanner command in the staging directory, over the
+// files of entries copied there, before they are moved into place. They are
+// listed in $MODVENDOR_PLAN, relative to the staging directory, which is also
+// passed as $MODVENDOR_STAGING.
+func runScanner(cwd, staging, command string, patterns []string, entries []*vendorEntry) error {
+	var staged []*vendorEntry
+	for _, e := range entries {
+		if !e.Unchanged && !e.Delta {
+			staged = append(staged, e)
+		}
+	}
+	if len(staged) == 0 {
+		return nil
+	}
+	return runHookIn(staging, cwd, command, newHookPlan(patterns, staged), "MODVENDOR_STAGING="+staging)
+}
+
+// runHookIn runs command as runHook does, in dir, with vars added to the
+// environment.
+func runHookIn(dir, cwd, command string, plan *hookPlan, vars ...string) error {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	cmd.Dir = dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = commandEnv(append([]string{
+		"MODVENDOR_OUT=" + outPath(cwd, ""),
+		"MODVENDOR_MANIFEST=" + manifestPath(cwd),
+	}, vars...)...)
+
+	if plan != nil {
+		f, err := os.CreateTemp("", "modvendor-plan*.json")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.Remove(f.Name())
+		}()
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(plan)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		path, err := filepath.Abs(f.Name())
+		if err != nil {
+			return err
+		}
+		cmd.Env = append(cmd.Env, "MODVENDOR_PLAN="+path)
+	}
+	return cmd.Run()
+}
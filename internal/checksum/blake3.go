@@ -0,0 +1,233 @@
+package checksum
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// BLAKE3 in hash mode, with 32 byte digests, as specified at
+// https://github.com/BLAKE3-team/BLAKE3-specs. It follows the portable
+// reference implementation, which is fast enough for checksums already.
+
+const (
+	blake3ChunkLen = 1024
+	blake3BlockLen = 64
+
+	chunkStart = 1 << 0
+	chunkEnd   = 1 << 1
+	parent     = 1 << 2
+	root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+	0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+// blake3Schedule is the order of the message words of each round, the
+// permutation applied as many times.
+var blake3Schedule = func() [7][16]uint8 {
+	permutation := [16]uint8{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+	var s [7][16]uint8
+	for i := range s[0] {
+		s[0][i] = uint8(i)
+	}
+	for r := 1; r < 7; r++ {
+		for i, j := range permutation {
+			s[r][i] = s[r-1][j]
+		}
+	}
+	return s
+}()
+
+func g(a, b, c, d, mx, my uint32) (uint32, uint32, uint32, uint32) {
+	a += b + mx
+	d = bits.RotateLeft32(d^a, -16)
+	c += d
+	b = bits.RotateLeft32(b^c, -12)
+	a += b + my
+	d = bits.RotateLeft32(d^a, -8)
+	c += d
+	b = bits.RotateLeft32(b^c, -7)
+	return a, b, c, d
+}
+
+// compress returns the 16 words of the compression of block.
+func compress(cv *[8]uint32, m *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	v0, v1, v2, v3, v4, v5, v6, v7 := cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7]
+	v8, v9, v10, v11 := blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3]
+	v12, v13, v14, v15 := uint32(counter), uint32(counter>>32), blockLen, flags
+	for r := range blake3Schedule {
+		s := &blake3Schedule[r]
+		v0, v4, v8, v12 = g(v0, v4, v8, v12, m[s[0]], m[s[1]])
+		v1, v5, v9, v13 = g(v1, v5, v9, v13, m[s[2]], m[s[3]])
+		v2, v6, v10, v14 = g(v2, v6, v10, v14, m[s[4]], m[s[5]])
+		v3, v7, v11, v15 = g(v3, v7, v11, v15, m[s[6]], m[s[7]])
+		v0, v5, v10, v15 = g(v0, v5, v10, v15, m[s[8]], m[s[9]])
+		v1, v6, v11, v12 = g(v1, v6, v11, v12, m[s[10]], m[s[11]])
+		v2, v7, v8, v13 = g(v2, v7, v8, v13, m[s[12]], m[s[13]])
+		v3, v4, v9, v14 = g(v3, v4, v9, v14, m[s[14]], m[s[15]])
+	}
+	return [16]uint32{
+		v0 ^ v8, v1 ^ v9, v2 ^ v10, v3 ^ v11, v4 ^ v12, v5 ^ v13, v6 ^ v14, v7 ^ v15,
+		v8 ^ cv[0], v9 ^ cv[1], v10 ^ cv[2], v11 ^ cv[3], v12 ^ cv[4], v13 ^ cv[5], v14 ^ cv[6], v15 ^ cv[7],
+	}
+}
+
+func blockWords(b []byte) [16]uint32 {
+	var full [blake3BlockLen]byte
+	copy(full[:], b)
+	var w [16]uint32
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint32(full[4*i:])
+	}
+	return w
+}
+
+// output is a compression yet to be made, the root one or that of a
+// chaining value.
+type output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *output) chainingValue() [8]uint32 {
+	s := compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+	var cv [8]uint32
+	copy(cv[:], s[:8])
+	return cv
+}
+
+func (o *output) rootBytes() [32]byte {
+	s := compress(&o.cv, &o.block, 0, o.blockLen, o.flags|root)
+	var out [32]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(out[4*i:], s[i])
+	}
+	return out
+}
+
+type chunkState struct {
+	cv         [8]uint32
+	counter    uint64
+	block      [blake3BlockLen]byte
+	blockLen   int
+	compressed int // blocks compressed so far
+}
+
+func newChunkState(counter uint64) chunkState {
+	return chunkState{cv: blake3IV, counter: counter}
+}
+
+func (c *chunkState) len() int {
+	return c.compressed*blake3BlockLen + c.blockLen
+}
+
+func (c *chunkState) startFlag() uint32 {
+	if c.compressed == 0 {
+		return chunkStart
+	}
+	return 0
+}
+
+func (c *chunkState) update(p []byte) {
+	for len(p) > 0 {
+		// The last block of a chunk is compressed by output, with chunkEnd.
+		if c.blockLen == blake3BlockLen {
+			w := blockWords(c.block[:])
+			s := compress(&c.cv, &w, c.counter, blake3BlockLen, c.startFlag())
+			copy(c.cv[:], s[:8])
+			c.compressed++
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *chunkState) output() output {
+	return output{
+		cv:       c.cv,
+		block:    blockWords(c.block[:c.blockLen]),
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | chunkEnd,
+	}
+}
+
+func parentOutput(left, right [8]uint32) output {
+	o := output{cv: blake3IV, blockLen: blake3BlockLen, flags: parent}
+	copy(o.block[:8], left[:])
+	copy(o.block[8:], right[:])
+	return o
+}
+
+// blake3 is the hash.Hash of BLAKE3.
+type blake3 struct {
+	chunk chunkState
+	stack [][8]uint32 // chaining values of complete subtrees
+}
+
+func newBlake3() hash.Hash {
+	return &blake3{chunk: newChunkState(0)}
+}
+
+func (b *blake3) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// A full chunk is only finished once more input follows, the last
+		// one is the root unless others came before.
+		if b.chunk.len() == blake3ChunkLen {
+			out := b.chunk.output()
+			b.addChunk(out.chainingValue(), b.chunk.counter+1)
+			b.chunk = newChunkState(b.chunk.counter + 1)
+		}
+		take := blake3ChunkLen - b.chunk.len()
+		if take > len(p) {
+			take = len(p)
+		}
+		b.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// addChunk merges the chaining value of a chunk with those of the completed
+// subtrees, total is the number of chunks so far.
+func (b *blake3) addChunk(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		top := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		out := parentOutput(top, cv)
+		cv = out.chainingValue()
+		total >>= 1
+	}
+	b.stack = append(b.stack, cv)
+}
+
+func (b *blake3) Sum(in []byte) []byte {
+	out := b.chunk.output()
+	for i := len(b.stack) - 1; i >= 0; i-- {
+		out = parentOutput(b.stack[i], out.chainingValue())
+	}
+	sum := out.rootBytes()
+	return append(in, sum[:]...)
+}
+
+func (b *blake3) Reset() {
+	b.chunk = newChunkState(0)
+	b.stack = b.stack[:0]
+}
+
+func (b *blake3) Size() int {
+	return 32
+}
+
+func (b *blake3) BlockSize() int {
+	return blake3BlockLen
+}
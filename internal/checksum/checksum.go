@@ -0,0 +1,73 @@
+// Package checksum names the algorithms checksumming vendored files, and
+// formats their sums.
+package checksum
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Algorithm is a hash algorithm, by the name configured.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+	BLAKE3 Algorithm = "blake3"
+)
+
+// Algorithms are those supported, SHA256 first as the default.
+var Algorithms = []Algorithm{SHA256, SHA512, BLAKE3}
+
+// Parse returns the algorithm of the name, SHA256 for an empty one.
+func Parse(name string) (Algorithm, error) {
+	if name == "" {
+		return SHA256, nil
+	}
+	for _, a := range Algorithms {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown checksum algorithm %q, use sha256, sha512 or blake3", name)
+}
+
+// New returns a new hash of the algorithm.
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case SHA512:
+		return sha512.New()
+	case BLAKE3:
+		return newBlake3()
+	}
+	return sha256.New()
+}
+
+// Sum returns the sum of h, a hash of the algorithm: the hex digest, prefixed
+// with the algorithm and a colon unless SHA256. Sums tell their algorithm,
+// and those of SHA256 read as they always did.
+func (a Algorithm) Sum(h hash.Hash) string {
+	sum := hex.EncodeToString(h.Sum(nil))
+	if a == SHA256 || a == "" {
+		return sum
+	}
+	return string(a) + ":" + sum
+}
+
+// Split returns the algorithm and the hex digest of sum.
+func Split(sum string) (Algorithm, string) {
+	if i := strings.IndexByte(sum, ':'); i >= 0 {
+		return Algorithm(sum[:i]), sum[i+1:]
+	}
+	return SHA256, sum
+}
+
+// Of returns the algorithm of sum.
+func Of(sum string) Algorithm {
+	a, _ := Split(sum)
+	return a
+}
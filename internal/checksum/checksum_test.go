@@ -0,0 +1,94 @@
+package checksum
+
+import (
+	"testing"
+)
+
+func TestBlake3(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{"abc", "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85"},
+	}
+	for _, tt := range tests {
+		h := BLAKE3.New()
+		h.Write([]byte(tt.in))
+		if got := BLAKE3.Sum(h); got != "blake3:"+tt.want {
+			t.Errorf("blake3(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Inputs spanning several chunks hash as the official test vectors, whose
+// inputs are bytes counting modulo 251, however they are written.
+func TestBlake3Writes(t *testing.T) {
+	data := make([]byte, 5*blake3ChunkLen+77)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	vectors := map[int]string{
+		1024: "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7",
+		1025: "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444",
+		2048: "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a",
+		3073: "7124b49501012f81cc7f11ca069ec9226cecb8a2c850cfe644e327d22d3e1cd3",
+	}
+	for _, n := range []int{blake3ChunkLen, blake3ChunkLen + 1, 2 * blake3ChunkLen, 3*blake3ChunkLen + 1, len(data)} {
+		whole := BLAKE3.New()
+		whole.Write(data[:n])
+		want := BLAKE3.Sum(whole)
+		if v, ok := vectors[n]; ok && want != "blake3:"+v {
+			t.Errorf("blake3 of %d bytes = %s, want %s", n, want, v)
+		}
+		for _, size := range []int{1, 63, 64, 1000, 1024} {
+			h := BLAKE3.New()
+			for p := data[:n]; len(p) > 0; {
+				k := size
+				if k > len(p) {
+					k = len(p)
+				}
+				h.Write(p[:k])
+				p = p[k:]
+			}
+			if got := BLAKE3.Sum(h); got != want {
+				t.Errorf("blake3 of %d bytes written %d at a time = %s, want %s", n, size, got, want)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Algorithm
+		ok   bool
+	}{
+		{"", SHA256, true},
+		{"sha256", SHA256, true},
+		{"sha512", SHA512, true},
+		{"blake3", BLAKE3, true},
+		{"md5", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.name)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, a := range Algorithms {
+		h := a.New()
+		h.Write([]byte("abc"))
+		sum := a.Sum(h)
+		alg, digest := Split(sum)
+		if alg != a || len(digest) != 2*h.Size() || Of(sum) != a {
+			t.Errorf("Split(%q) = %q, %q", sum, alg, digest)
+		}
+	}
+	if got := SHA256.Sum(SHA256.New()); got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("sha256 sum = %q, want the hex digest alone", got)
+	}
+}
@@ -0,0 +1,21 @@
+package copyer
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Clone creates dst as a copy-on-write clone of src with clonefile(2),
+// as supported by APFS.
+func Clone(src, dst string) error {
+	return cloneOnce(src, dst, func() error {
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+	}, func(err error) bool {
+		return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV)
+	})
+}
@@ -0,0 +1,40 @@
+package copyer
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Clone creates dst as a copy-on-write clone of src with FICLONE, as
+// supported by btrfs and XFS among others.
+func Clone(src, dst string) error {
+	return cloneOnce(src, dst, func() error {
+		return ficlone(src, dst)
+	}, func(err error) bool {
+		return errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL)
+	})
+}
+
+func ficlone(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	err = unix.IoctlFileClone(int(w.Fd()), int(r.Fd()))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package copyer
+
+import "errors"
+
+// Clone fails, there are no copy-on-write clones to create.
+func Clone(src, dst string) error {
+	return errors.New("copy-on-write clones are not supported on this platform")
+}
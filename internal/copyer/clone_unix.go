@@ -0,0 +1,62 @@
+//go:build darwin || linux
+
+package copyer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// errNoClones is the error of Clone between devices a clone failed between
+// as unsupported before.
+var errNoClones = errors.New("copy-on-write clones are not supported between these filesystems")
+
+// noClones holds the devicePairs clones failed between as unsupported, the
+// files of a run mostly share the same ones and Clone doesn't try them again.
+var noClones sync.Map
+
+// devicePair is the device of a source and that of the directory of a
+// destination.
+type devicePair struct {
+	src, dst uint64
+}
+
+func devices(src, dst string) (devicePair, bool) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return devicePair{}, false
+	}
+	dstInfo, err := os.Stat(filepath.Dir(dst))
+	if err != nil {
+		return devicePair{}, false
+	}
+	s, ok := srcInfo.Sys().(*syscall.Stat_t)
+	d, ok2 := dstInfo.Sys().(*syscall.Stat_t)
+	if !ok || !ok2 {
+		return devicePair{}, false
+	}
+	return devicePair{uint64(s.Dev), uint64(d.Dev)}, true
+}
+
+// cloneOnce runs clone of src to dst, unless it already failed as
+// unsupported between their devices, and remembers if it does. Clones are
+// counted against Ops once made, the copy standing in for one that failed
+// counts itself.
+func cloneOnce(src, dst string, clone func() error, unsupported func(error) bool) error {
+	pair, ok := devices(src, dst)
+	if _, no := noClones.Load(pair); ok && no {
+		return errNoClones
+	}
+	err := clone()
+	if err != nil {
+		if ok && unsupported(err) {
+			noClones.Store(pair, true)
+		}
+		return err
+	}
+	Ops.Wait(1)
+	return nil
+}
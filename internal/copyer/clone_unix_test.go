@@ -0,0 +1,39 @@
+//go:build darwin || linux
+
+package copyer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCloneUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	a, _ := writeFile(t, dir, "a", []byte("a"), 0644, time.Now())
+	b, _ := writeFile(t, dir, "b", []byte("b"), 0644, time.Now())
+	err := Clone(a, filepath.Join(dir, "a.clone"))
+	if err == nil {
+		t.Skip("the filesystem of the test directory supports clones")
+	}
+	if pair, ok := devices(a, filepath.Join(dir, "a.clone")); !ok {
+		t.Fatal("no devices for the files of the test directory")
+	} else if _, no := noClones.Load(pair); !no {
+		t.Skipf("the clone failed otherwise than as unsupported: %v", err)
+	}
+	defer noClones.Range(func(pair, _ interface{}) bool {
+		noClones.Delete(pair)
+		return true
+	})
+
+	// The next file isn't tried, nor is its destination created.
+	dst := filepath.Join(dir, "b.clone")
+	if err := Clone(b, dst); !errors.Is(err, errNoClones) {
+		t.Errorf("Clone after an unsupported one = %v, want %v", err, errNoClones)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("Clone created %s: %v", dst, err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package copyer copies the files modvendor vendors: with pooled buffers,
+// copy-on-write clones, holes of sparse files kept, and limited to rates of
+// bytes and I/O operations per second.
+package copyer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// copyBuffers are reused across the many, mostly small, files of a run.
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 128<<10)
+		return &buf
+	},
+}
+
+// Buffered is io.Copy with a pooled buffer. The buffer is always used,
+// w and r are hidden from io.CopyBuffer's ReaderFrom and WriterTo shortcuts.
+func Buffered(w io.Writer, r io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+	return io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{r}, *buf)
+}
+
+// sparseBlock is the granularity holes are preserved at.
+const sparseBlock = 4096
+
+var zeroBlock [sparseBlock]byte
+
+// Sparse copies r to w, seeking over rather than writing blocks of
+// zeros, so w gets holes where the filesystem supports them.
+func Sparse(w *os.File, r io.Reader, size int64) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	var written int64
+	for {
+		n, err := io.ReadFull(r, *buf)
+		for data := (*buf)[:n]; len(data) > 0; {
+			block := data
+			if len(block) > sparseBlock {
+				block = block[:sparseBlock]
+			}
+			data = data[len(block):]
+			if bytes.Equal(block, zeroBlock[:len(block)]) {
+				if _, err := w.Seek(int64(len(block)), io.SeekCurrent); err != nil {
+					return written, err
+				}
+			} else if _, err := w.Write(block); err != nil {
+				return written, err
+			}
+			written += int64(len(block))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	// A trailing hole isn't written at all.
+	return written, w.Truncate(size)
+}
+
+// Regular copies the regular file src, described by info, to dst,
+// writing the content to tee as well, if not nil. dst gets the permissions
+// of src plus 0644, and its modification time.
+func Regular(src, dst string, info fs.FileInfo, tee io.Writer) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	perm := info.Mode().Perm() | 0644
+	Ops.Wait(1)
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	copyData := Buffered
+	switch {
+	case IsSparse(info):
+		copyData = func(w io.Writer, r io.Reader) (int64, error) {
+			return Sparse(w.(*os.File), r, info.Size())
+		}
+	case tee == nil && Bytes == nil && Ops == nil:
+		// Between two *os.File io.Copy has the kernel copy the content
+		// (copy_file_range, sendfile) where it can.
+		copyData = io.Copy
+	}
+	in := Throttled(r)
+	if tee != nil {
+		in = io.TeeReader(in, tee)
+	}
+	if _, err := copyData(w, in); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// An existing dst keeps its permissions when opened.
+	if err := os.Chmod(dst, perm); err != nil {
+		return err
+	}
+	// Unchanged files are told apart by their modification time.
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// Interruptible returns r failing reads with the error of ctx once it is
+// done.
+func Interruptible(ctx context.Context, r io.Reader) io.Reader {
+	return ctxReader{ctx, r}
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
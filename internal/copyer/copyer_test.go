@@ -0,0 +1,217 @@
+package copyer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes data to name within dir, with the modification time mtime.
+func writeFile(t *testing.T, dir, name string, data []byte, perm os.FileMode, mtime time.Time) (string, os.FileInfo) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, info
+}
+
+func checkCopy(t *testing.T, dst string, want []byte, info os.FileInfo) {
+	t.Helper()
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s has %d bytes, want %d", dst, len(got), len(want))
+	}
+	dinfo, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dinfo.ModTime().Equal(info.ModTime()) {
+		t.Errorf("%s modified %s, want %s", dst, dinfo.ModTime(), info.ModTime())
+	}
+}
+
+func TestRegular(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("modvendor "), 50000)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	src, info := writeFile(t, dir, "src", data, 0600, mtime)
+
+	// An existing destination is overwritten, and gets the new permissions.
+	dst, _ := writeFile(t, dir, "dst", []byte("stale content, longer than nothing"), 0600, time.Now())
+	var tee bytes.Buffer
+	if err := Regular(src, dst, info, &tee); err != nil {
+		t.Fatal(err)
+	}
+	checkCopy(t, dst, data, info)
+	if !bytes.Equal(tee.Bytes(), data) {
+		t.Errorf("tee got %d bytes, want %d", tee.Len(), len(data))
+	}
+	if dinfo, _ := os.Stat(dst); os.PathSeparator == '/' && dinfo.Mode().Perm() != 0644 {
+		t.Errorf("dst has mode %s, want 0644", dinfo.Mode().Perm())
+	}
+
+	// Without a tee the kernel may copy the content.
+	dst2 := filepath.Join(dir, "dst2")
+	if err := Regular(src, dst2, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkCopy(t, dst2, data, info)
+}
+
+func TestRegularThrottled(t *testing.T) {
+	Bytes, Ops = NewThrottle(1<<30), NewThrottle(1<<20)
+	defer func() { Bytes, Ops = nil, nil }()
+
+	dir := t.TempDir()
+	data := []byte("throttled")
+	src, info := writeFile(t, dir, "src", data, 0644, time.Now().Add(-time.Hour))
+	dst := filepath.Join(dir, "dst")
+	if err := Regular(src, dst, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkCopy(t, dst, data, info)
+}
+
+func TestSparse(t *testing.T) {
+	dir := t.TempDir()
+	data := make([]byte, 5*sparseBlock+100)
+	copy(data[sparseBlock:], "data between holes")
+	data[len(data)-1] = 1
+	for _, size := range []int{len(data), 3 * sparseBlock} {
+		want := data[:size]
+		// The trailing block is all zeros when cut at 3 blocks.
+		w, err := os.Create(filepath.Join(dir, "sparse"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := Sparse(w, bytes.NewReader(want), int64(size))
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(size) {
+			t.Errorf("Sparse copied %d bytes, want %d", n, size)
+		}
+		got, err := os.ReadFile(w.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Sparse of %d bytes wrote different content", size)
+		}
+	}
+}
+
+func TestBuffered(t *testing.T) {
+	data := bytes.Repeat([]byte{1, 2, 3}, 100000)
+	var w bytes.Buffer
+	n, err := Buffered(&w, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(w.Bytes(), data) {
+		t.Errorf("Buffered copied %d bytes, want %d", n, len(data))
+	}
+}
+
+func TestInterruptible(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := Interruptible(ctx, bytes.NewReader([]byte("abcdef")))
+	p := make([]byte, 3)
+	if n, err := r.Read(p); n != 3 || err != nil {
+		t.Fatalf("Read = %d, %v, want 3 bytes", n, err)
+	}
+	cancel()
+	if n, err := r.Read(p); n != 0 || err != context.Canceled {
+		t.Errorf("Read once canceled = %d, %v, want context.Canceled", n, err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	dir := t.TempDir()
+	old := bytes.Repeat([]byte("a"), 4*PatchBlock)
+	data := append([]byte(nil), old...)
+	copy(data[2*PatchBlock+10:], "changed")
+	data = append(data, "grown"...)
+
+	mtime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	src, info := writeFile(t, dir, "src", data, 0644, mtime)
+	dst, _ := writeFile(t, dir, "dst", old, 0644, time.Now())
+
+	var tee bytes.Buffer
+	written, err := Patch(src, dst, info, &tee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The changed block and the partial block at the end.
+	if want := int64(PatchBlock + len("grown")); written != want {
+		t.Errorf("Patch wrote %d bytes, want %d", written, want)
+	}
+	checkCopy(t, dst, data, info)
+	if !bytes.Equal(tee.Bytes(), data) {
+		t.Errorf("tee got %d bytes, want %d", tee.Len(), len(data))
+	}
+
+	// Shrinking truncates, without rewriting anything.
+	src, info = writeFile(t, dir, "src", old[:PatchBlock], 0644, mtime)
+	if written, err = Patch(src, dst, info, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 {
+		t.Errorf("Patch wrote %d bytes shrinking, want 0", written)
+	}
+	checkCopy(t, dst, old[:PatchBlock], info)
+}
+
+func TestDirSet(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	var s DirSet
+	if err := s.MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("MkdirAll didn't create %s: %v", dir, err)
+	}
+	// Directories made once aren't looked at again.
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("MkdirAll recreated %s", dir)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	if NewThrottle(0) != nil {
+		t.Error("NewThrottle(0) isn't nil")
+	}
+	var nilThrottle *Throttle
+	nilThrottle.Wait(1 << 30)
+
+	th := NewThrottle(100)
+	start := time.Now()
+	th.Wait(10) // paid for in 100ms, the next wait is delayed
+	th.Wait(1)
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("waits took %s, want at least 100ms", elapsed)
+	}
+}
@@ -0,0 +1,25 @@
+package copyer
+
+import (
+	"os"
+	"sync"
+)
+
+// DirSet remembers the directories it created, so that creating the
+// directory of every file of a directory costs a single os.MkdirAll. It is
+// safe for concurrent use.
+type DirSet struct {
+	made sync.Map
+}
+
+// MkdirAll is os.MkdirAll, for directories the set didn't create yet.
+func (s *DirSet) MkdirAll(dir string) error {
+	if _, ok := s.made.Load(dir); ok {
+		return nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	s.made.Store(dir, true)
+	return nil
+}
@@ -0,0 +1,68 @@
+package copyer
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// PatchBlock is the size of the blocks Patch compares and rewrites.
+const PatchBlock = 64 << 10
+
+// Patch makes dst a copy of src, with the size and mode of info, by
+// rewriting only the blocks that differ. It returns the number of bytes
+// written. The content of src is written to tee as well.
+func Patch(src, dst string, info os.FileInfo, tee io.Writer) (int64, error) {
+	r, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	Ops.Wait(1)
+	w, err := os.OpenFile(dst, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	written := int64(0)
+	rbuf, wbuf := make([]byte, PatchBlock), make([]byte, PatchBlock)
+	tr := io.TeeReader(Throttled(r), tee)
+	for off := int64(0); ; {
+		n, err := io.ReadFull(tr, rbuf)
+		if n > 0 {
+			m, _ := io.ReadFull(w, wbuf[:n])
+			if m != n || !bytes.Equal(rbuf[:n], wbuf[:n]) {
+				if _, err := w.WriteAt(rbuf[:n], off); err != nil {
+					_ = w.Close()
+					return written, err
+				}
+				written += int64(n)
+			}
+			off += int64(n)
+			if _, err := w.Seek(off, io.SeekStart); err != nil {
+				_ = w.Close()
+				return written, err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			_ = w.Close()
+			return written, err
+		}
+	}
+	if err := w.Truncate(info.Size()); err != nil {
+		_ = w.Close()
+		return written, err
+	}
+	if err := w.Close(); err != nil {
+		return written, err
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()|0644); err != nil {
+		return written, err
+	}
+	return written, os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
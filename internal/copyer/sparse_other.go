@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package copyer
+
+import "io/fs"
+
+// IsSparse reports false, holes of files aren't known on this platform.
+func IsSparse(info fs.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package copyer
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// IsSparse reports whether the file of info takes less space on disk than
+// its size, having holes.
+func IsSparse(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int64(st.Blocks)*512 < info.Size()
+}
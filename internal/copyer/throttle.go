@@ -0,0 +1,65 @@
+package copyer
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Throttle limits a rate of units, bytes or I/O operations, per second
+// across goroutines. A nil Throttle doesn't limit anything.
+type Throttle struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time // when the units handed out so far are paid for
+}
+
+// Bytes and Ops limit the bytes read and the files created and buffers read
+// by copies, nil by default.
+var Bytes, Ops *Throttle
+
+// NewThrottle returns a Throttle of perSecond units, nil if it is not
+// positive.
+func NewThrottle(perSecond int64) *Throttle {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &Throttle{rate: float64(perSecond)}
+}
+
+// Wait blocks until n more units fit the rate.
+func (t *Throttle) Wait(n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	delay := t.next.Sub(now)
+	t.next = t.next.Add(time.Duration(float64(n) / t.rate * float64(time.Second)))
+	t.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// throttledReader counts every read as an I/O operation and the bytes read
+// against the throttles.
+type throttledReader struct {
+	r io.Reader
+}
+
+func (r throttledReader) Read(p []byte) (int, error) {
+	Ops.Wait(1)
+	n, err := r.r.Read(p)
+	Bytes.Wait(n)
+	return n, err
+}
+
+// Throttled returns r limited by Bytes and Ops, if set.
+func Throttled(r io.Reader) io.Reader {
+	if Bytes == nil && Ops == nil {
+		return r
+	}
+	return throttledReader{r}
+}
@@ -0,0 +1,260 @@
+// Package license detects the licenses of vendored files, by SPDX
+// identifier, and checks them against an allow and deny policy.
+package license
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Unknown is the license of license files whose text isn't recognized.
+const Unknown = "unknown"
+
+// HeadSize is how much of the start of a file Detect needs, where license
+// headers are.
+const HeadSize = 16 << 10
+
+// IsLicenseFile reports whether the slash separated name is that of a
+// license file, such as LICENSE, COPYING.txt or LICENSE-MIT.
+func IsLicenseFile(name string) bool {
+	base := strings.ToUpper(path.Base(name))
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"} {
+		if strings.HasPrefix(base, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var spdxTag = regexp.MustCompile(`SPDX-License-Identifier:\s*([^\r\n]*)`)
+
+// texts recognize licenses by phrases of their text, which headers quote
+// too, the first matching wins: those quoting others come first.
+var texts = []struct {
+	id      string
+	phrases []string
+}{
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"AGPL-3.0", []string{"gnu affero general public license"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license"}},
+	{"LGPL-2.0", []string{"gnu library general public license"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSD-4-Clause", []string{"redistribution and use in source and binary forms", "all advertising materials"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "endorse or promote products"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted, free of charge, to any person obtaining a copy"}},
+	{"ISC", []string{"permission to use, copy, modify, and", "distribute this software for any purpose with or without fee is hereby granted"}},
+	{"Zlib", []string{"altered source versions must be plainly marked as such"}},
+	{"BSL-1.0", []string{"boost software license"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+}
+
+// Detect returns the license expression of a file, from head, the start of
+// it: that of its SPDX-License-Identifier tag, or else of the license text it
+// quotes, Unknown for license files quoting none known. It returns "" when
+// the file tells no license.
+func Detect(name string, head []byte) string {
+	if m := spdxTag.FindSubmatch(head); m != nil {
+		// Tags end comments in C, e.g. /* SPDX-License-Identifier: MIT */.
+		expr := strings.TrimSpace(string(m[1]))
+		expr = strings.TrimSpace(strings.TrimSuffix(expr, "*/"))
+		expr = strings.TrimSpace(strings.TrimSuffix(expr, "-->"))
+		if expr != "" {
+			return expr
+		}
+	}
+	text := normalize(head)
+	for _, t := range texts {
+		if containsAll(text, t.phrases) {
+			return t.id
+		}
+	}
+	if IsLicenseFile(name) {
+		return Unknown
+	}
+	return ""
+}
+
+// Combine returns the expression of something under every one of the
+// license expressions, e.g. a module with several license files: them joined
+// with AND, once each, "" for none.
+func Combine(exprs []string) string {
+	var parts []string
+	seen := map[string]bool{}
+	for _, expr := range exprs {
+		key := strings.ToLower(expr)
+		if expr == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if len(tokenize(expr)) > 1 {
+			expr = "(" + expr + ")"
+		}
+		parts = append(parts, expr)
+	}
+	if len(parts) == 1 {
+		return strings.TrimSuffix(strings.TrimPrefix(parts[0], "("), ")")
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// normalize lowercases text and collapses comment markers and white space,
+// so phrases match across wrapped and commented lines.
+func normalize(text []byte) string {
+	fields := bytes.FieldsFunc(bytes.ToLower(text), func(r rune) bool {
+		switch r {
+		case ' ', '\t', '\r', '\n', '*', '#', '/', ';':
+			return true
+		}
+		return false
+	})
+	return string(bytes.Join(fields, []byte(" ")))
+}
+
+func containsAll(text string, phrases []string) bool {
+	for _, p := range phrases {
+		if !strings.Contains(text, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy tells which licenses may be vendored. Licenses are SPDX
+// identifiers, compared ignoring case and -only, -or-later or + suffixes.
+type Policy struct {
+	Allow []string // unless empty, the only licenses allowed
+	Deny  []string // licenses never allowed, even when in Allow
+}
+
+// Check returns why the license expression, e.g. "MIT OR Apache-2.0",
+// violates the policy, nil if it doesn't: an expression is allowed when
+// one of its alternatives is, an alternative when all its licenses are.
+// Exceptions of WITH are always allowed.
+func (p *Policy) Check(expr string) error {
+	e := &exprParser{tokens: tokenize(expr)}
+	reason, err := e.or(p)
+	if err == nil && e.pos < len(e.tokens) {
+		err = fmt.Errorf("unexpected %q", e.tokens[e.pos])
+	}
+	if err != nil {
+		return fmt.Errorf("invalid license expression %q: %w", expr, err)
+	}
+	if reason != "" {
+		return fmt.Errorf("%s", reason)
+	}
+	return nil
+}
+
+// check returns why the license is not allowed, "" if it is.
+func (p *Policy) check(id string) string {
+	for _, d := range p.Deny {
+		if sameLicense(d, id) {
+			return id + " is denied"
+		}
+	}
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	for _, a := range p.Allow {
+		if sameLicense(a, id) {
+			return ""
+		}
+	}
+	return id + " is not allowed"
+}
+
+func sameLicense(a, b string) bool {
+	return strings.EqualFold(baseLicense(a), baseLicense(b))
+}
+
+func baseLicense(id string) string {
+	id = strings.TrimSuffix(id, "+")
+	for _, suffix := range []string{"-only", "-or-later"} {
+		if len(id) > len(suffix) && strings.EqualFold(id[len(id)-len(suffix):], suffix) {
+			return id[:len(id)-len(suffix)]
+		}
+	}
+	return id
+}
+
+func tokenize(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+// exprParser evaluates SPDX license expressions against a policy, by
+// precedence: WITH, AND, then OR. Evaluation returns why the expression is
+// not allowed, "" if it is.
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (e *exprParser) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos]
+	}
+	return ""
+}
+
+func (e *exprParser) or(p *Policy) (string, error) {
+	reason, err := e.and(p)
+	for err == nil && strings.EqualFold(e.peek(), "OR") {
+		e.pos++
+		var r string
+		if r, err = e.and(p); r == "" {
+			reason = ""
+		}
+	}
+	return reason, err
+}
+
+func (e *exprParser) and(p *Policy) (string, error) {
+	reason, err := e.license(p)
+	for err == nil && strings.EqualFold(e.peek(), "AND") {
+		e.pos++
+		var r string
+		if r, err = e.license(p); reason == "" {
+			reason = r
+		}
+	}
+	return reason, err
+}
+
+func (e *exprParser) license(p *Policy) (string, error) {
+	tok := e.peek()
+	switch {
+	case tok == "":
+		return "", fmt.Errorf("missing license")
+	case tok == "(":
+		e.pos++
+		reason, err := e.or(p)
+		if err != nil {
+			return "", err
+		}
+		if e.peek() != ")" {
+			return "", fmt.Errorf("missing )")
+		}
+		e.pos++
+		return reason, nil
+	case tok == ")" || strings.EqualFold(tok, "AND") || strings.EqualFold(tok, "OR") || strings.EqualFold(tok, "WITH"):
+		return "", fmt.Errorf("unexpected %q", tok)
+	}
+	e.pos++
+	if strings.EqualFold(e.peek(), "WITH") {
+		e.pos++
+		if exception := e.peek(); exception == "" || exception == "(" || exception == ")" {
+			return "", fmt.Errorf("missing exception after WITH")
+		}
+		e.pos++
+	}
+	return p.check(tok), nil
+}
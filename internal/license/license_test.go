@@ -0,0 +1,113 @@
+package license
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"x.c", "/* SPDX-License-Identifier: MIT */\nint x;", "MIT"},
+		{"x.c", "// SPDX-License-Identifier: GPL-2.0-only OR BSD-2-Clause\n", "GPL-2.0-only OR BSD-2-Clause"},
+		{"x.h", "#define X 1\n", ""},
+		{"LICENSE", "Permission is hereby granted, free of charge, to any person obtaining\na copy of this software", "MIT"},
+		{"COPYING", "GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n", "GPL-3.0"},
+		{"COPYING.LIB", "GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999\n", "LGPL-2.1"},
+		{"LICENSE", "Mozilla Public License Version 2.0\n... GNU General Public License ...", "MPL-2.0"},
+		{"LICENSE", "Apache License\nVersion 2.0, January 2004\n", "Apache-2.0"},
+		{"x.c", " * This program is free software; you can redistribute it and/or modify\n * it under the terms of the GNU General Public License as published by\n * the Free Software Foundation; either version 2", "GPL-2.0"},
+		{"LICENSE", "Redistribution and use in source and binary forms, with or without\nmodification, are permitted ... Neither the name of the copyright holder nor the names of its\ncontributors may be used to endorse or promote products derived", "BSD-3-Clause"},
+		{"LICENSE", "Redistribution and use in source and\nbinary forms, with or without modification, are permitted", "BSD-2-Clause"},
+		{"LICENSE", "Permission to use, copy, modify, and/or distribute this software for any\npurpose with or without fee is hereby granted", "ISC"},
+		{"LICENSE.txt", "All rights reserved by the authors.", Unknown},
+		{"README", "All rights reserved by the authors.", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.name, []byte(tt.head)); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", tt.name, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestIsLicenseFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"LICENSE", true},
+		{"csrc/licence.md", true},
+		{"COPYING.LIB", true},
+		{"LICENSE-MIT", true},
+		{"UNLICENSE", true},
+		{"csrc/license.c", true},
+		{"x.c", false},
+		{"LICENSE/x.c", false},
+	}
+	for _, tt := range tests {
+		if got := IsLicenseFile(tt.name); got != tt.want {
+			t.Errorf("IsLicenseFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		exprs []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"MIT"}, "MIT"},
+		{[]string{"MIT OR Apache-2.0"}, "MIT OR Apache-2.0"},
+		{[]string{"MIT", "Apache-2.0"}, "MIT AND Apache-2.0"},
+		{[]string{"MIT", "mit", ""}, "MIT"},
+		{[]string{"MIT OR Apache-2.0", "Zlib"}, "(MIT OR Apache-2.0) AND Zlib"},
+	}
+	for _, tt := range tests {
+		if got := Combine(tt.exprs); got != tt.want {
+			t.Errorf("Combine(%q) = %q, want %q", tt.exprs, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	allow := &Policy{Allow: []string{"MIT", "BSD-3-Clause", "Apache-2.0", "GPL-2.0"}, Deny: []string{"GPL-3.0"}}
+	deny := &Policy{Deny: []string{"GPL-3.0", "AGPL-3.0"}}
+	tests := []struct {
+		policy *Policy
+		expr   string
+		want   string // in the error, "" for none
+	}{
+		{allow, "MIT", ""},
+		{allow, "mit", ""},
+		{allow, "ISC", "ISC is not allowed"},
+		{allow, "GPL-3.0-or-later", "GPL-3.0-or-later is denied"},
+		{allow, "GPL-2.0-only", ""},
+		{allow, "GPL-2.0+", ""},
+		{allow, "MIT OR GPL-3.0", ""},
+		{allow, "ISC OR GPL-3.0", "ISC is not allowed"},
+		{allow, "MIT AND ISC", "ISC is not allowed"},
+		{allow, "(ISC OR MIT) AND Apache-2.0", ""},
+		{allow, "Apache-2.0 WITH LLVM-exception", ""},
+		{allow, "MIT AND", "invalid license expression"},
+		{allow, "(MIT", "invalid license expression"},
+		{allow, "MIT Apache-2.0", "invalid license expression"},
+		{allow, Unknown, "unknown is not allowed"},
+		{deny, "ISC", ""},
+		{deny, Unknown, ""},
+		{deny, "AGPL-3.0-only", "AGPL-3.0-only is denied"},
+		{&Policy{}, "anything", ""},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Check(tt.expr)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("Check(%q) = %v, want nil", tt.expr, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("Check(%q) = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package plan
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Modules are read through io/fs: the module cache with DirFS, a module zip
+// with fs.Sub of its zip.Reader, test fixtures with fstest.MapFS. Names are
+// slash separated and relative to the module root.
+
+// SymlinkFS is implemented by filesystems with symlinks, whose targets must
+// be checked to stay within the module before being followed.
+type SymlinkFS interface {
+	fs.FS
+
+	// Resolve returns name with all symlinks resolved, and whether it
+	// stays within the root of the filesystem.
+	Resolve(name string) (string, bool, error)
+}
+
+// dirFS is os.DirFS, with the symlinks of the directory resolved.
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+// DirFS returns the filesystem of the module extracted to dir.
+func DirFS(dir string) SymlinkFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+func (f dirFS) Resolve(name string) (string, bool, error) {
+	if !fs.ValidPath(name) {
+		return "", false, &fs.PathError{Op: "resolve", Path: name, Err: fs.ErrInvalid}
+	}
+	realRoot, err := filepath.EvalSymlinks(f.dir)
+	if err != nil {
+		return "", false, err
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(f.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return "", false, err
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", false, nil
+	}
+	return filepath.ToSlash(rel), true, nil
+}
+
+// Resolve resolves the symlinks of name within fsys, see SymlinkFS. Names
+// within filesystems without symlinks resolve to themselves.
+func Resolve(fsys fs.FS, name string) (string, bool, error) {
+	if s, ok := fsys.(SymlinkFS); ok {
+		return s.Resolve(name)
+	}
+	return name, true, nil
+}
+
+// Display returns name as messages show it, the path of the file for
+// directory filesystems.
+func Display(fsys fs.FS, name string) string {
+	if d, ok := fsys.(dirFS); ok {
+		return filepath.Join(d.dir, filepath.FromSlash(name))
+	}
+	return name
+}
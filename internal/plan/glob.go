@@ -0,0 +1,205 @@
+// Package plan matches the copy patterns of modvendor against the files of a
+// module, and attributes files to the packages of the module.
+package plan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mattn/go-zglob"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// slots bounds the goroutines walking top-level directories of modules
+// beyond those globbing the modules themselves, see Glob.
+var slots = semaphore.NewWeighted(1)
+
+// SetJobs sets how many top-level directories Glob walks in parallel, across
+// every module being globbed.
+func SetJobs(n int) {
+	if n < 1 {
+		n = 1
+	}
+	slots = semaphore.NewWeighted(int64(n))
+}
+
+// Matcher is a compiled zglob pattern.
+type Matcher interface {
+	Match(name string) bool
+}
+
+// rootedMatcher matches names relative to the module root as if they were
+// absolute, so that no name is taken for ~ or an environment variable.
+type rootedMatcher struct {
+	z Matcher
+}
+
+func (m rootedMatcher) Match(name string) bool {
+	return m.z.Match("/" + name)
+}
+
+// Compile compiles the copy patterns, relative to the module root. Empty
+// patterns have a nil matcher.
+func Compile(patterns []string) ([]Matcher, error) {
+	globs := make([]Matcher, len(patterns))
+	for i, pat := range patterns {
+		if pat == "" {
+			continue
+		}
+		z, err := zglob.New("/" + cleanPattern(pat))
+		if err != nil {
+			return nil, err
+		}
+		globs[i] = rootedMatcher{z}
+	}
+	return globs, nil
+}
+
+// cleanPattern returns pat as a slash separated name relative to the module
+// root, as if joined to the module's directory.
+func cleanPattern(pat string) string {
+	name := path.Clean("/" + filepath.ToSlash(pat))[1:]
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// File is a file or directory found walking a module.
+type File struct {
+	Name  string      // slash separated, relative to the module root
+	Entry fs.DirEntry // nil if not known
+}
+
+// Glob returns the matches of each copy pattern within fsys, as zglob.Glob
+// does, walking fsys once for all of them. An empty pattern matches every
+// file, following symlinks. Files which keep returns false for are left out
+// as they are found, directories are always returned. The walk stops with
+// the error of ctx once it is done.
+func Glob(ctx context.Context, fsys fs.FS, patterns []string, keep func(name string) bool) ([][]File, error) {
+	globs, err := Compile(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([][]File, len(patterns))
+	walk := false
+	for i, pat := range patterns {
+		switch {
+		case pat == "":
+			if matches[i], err = WalkFiles(ctx, fsys, ".", keep); err != nil {
+				return nil, err
+			}
+		case !strings.ContainsAny(pat, "*{"):
+			// Without wildcards zglob matches the path itself, if it exists.
+			name := cleanPattern(pat)
+			if _, err := fs.Stat(fsys, name); err != nil {
+				return nil, os.ErrNotExist
+			}
+			matches[i] = []File{{Name: name}}
+			globs[i] = nil
+		default:
+			walk = true
+		}
+	}
+	if !walk {
+		return matches, nil
+	}
+
+	match := func(shard [][]File) fs.WalkDirFunc {
+		return func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !d.IsDir() && d.Type()&fs.ModeSymlink == 0 && !keep(name) {
+				return nil
+			}
+			for i, z := range globs {
+				if z != nil && z.Match(name) {
+					shard[i] = append(shard[i], File{Name: name, Entry: d})
+				}
+			}
+			return nil
+		}
+	}
+
+	// The top-level directories of huge modules are walked in parallel
+	// while there are idle slots, and their matches concatenated in the
+	// order of a single walk.
+	dirEntries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return matches, err
+	}
+	shards := make([][][]File, len(dirEntries))
+	errs := make([]error, len(dirEntries))
+	var g errgroup.Group
+	for i, d := range dirEntries {
+		i, name := i, d.Name()
+		shards[i] = make([][]File, len(globs))
+		// WalkDir would follow a symlink it starts from.
+		if !d.IsDir() {
+			errs[i] = match(shards[i])(name, d, nil)
+			continue
+		}
+		if !slots.TryAcquire(1) {
+			errs[i] = fs.WalkDir(fsys, name, match(shards[i]))
+			continue
+		}
+		g.Go(func() error {
+			defer slots.Release(1)
+			errs[i] = fs.WalkDir(fsys, name, match(shards[i]))
+			return nil
+		})
+	}
+	_ = g.Wait()
+	for i, shard := range shards {
+		if errs[i] != nil {
+			return matches, errs[i]
+		}
+		for j := range shard {
+			matches[j] = append(matches[j], shard[j]...)
+		}
+	}
+	return matches, nil
+}
+
+// Suffixes returns the name suffixes, such as ".c", one of which every file
+// or directory matched by patterns ends with. It returns nil when some
+// pattern matches names of any kind.
+func Suffixes(patterns []string) []string {
+	var suffixes []string
+	for _, pat := range patterns {
+		i := strings.LastIndex(pat, "*")
+		if i < 0 {
+			return nil
+		}
+		suffix := pat[i+1:]
+		if suffix == "" || strings.ContainsAny(suffix, "?[]{}/\\") {
+			return nil
+		}
+		suffixes = append(suffixes, suffix)
+	}
+	return suffixes
+}
+
+// foldCase tells whether patterns match regardless of case, as zglob does
+// on darwin and windows.
+var foldCase = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// HasSuffix reports whether name ends with suffix, one of Suffixes, the way
+// the patterns match it.
+func HasSuffix(name, suffix string) bool {
+	if !foldCase {
+		return strings.HasSuffix(name, suffix)
+	}
+	return len(name) >= len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix)
+}
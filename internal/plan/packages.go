@@ -0,0 +1,54 @@
+package plan
+
+import (
+	"path"
+	"strings"
+)
+
+// RelPackage returns the path of pkgPath relative to modPath, and whether
+// pkgPath is modPath or within it at all.
+func RelPackage(modPath, pkgPath string) (string, bool) {
+	if pkgPath == modPath {
+		return "", true
+	}
+	if !strings.HasPrefix(pkgPath, modPath+"/") {
+		return "", false
+	}
+	return pkgPath[len(modPath)+1:], true
+}
+
+// Packages indexes the directories of the packages of a module.
+type Packages struct {
+	dirs map[string]string // package of each directory, "." for the root
+}
+
+// NewPackages indexes the packages, or -include directories, of the module
+// modPath. Packages outside of the module are left out.
+func NewPackages(modPath string, pkgs []string) *Packages {
+	p := &Packages{dirs: map[string]string{}}
+	for _, pkg := range pkgs {
+		rel, ok := RelPackage(modPath, pkg)
+		if !ok {
+			continue
+		}
+		if rel == "" {
+			rel = "."
+		}
+		p.dirs[rel] = pkg
+	}
+	return p
+}
+
+// Of attributes the file name, relative to the module root, to the most
+// specific package containing it, the closest of its parent directories with
+// a package. It returns "" if none does.
+func (p *Packages) Of(name string) string {
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		if pkg, ok := p.dirs[dir]; ok {
+			return pkg
+		}
+		if dir == "." {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package plan
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// tree returns an in-memory module of the files, slash separated.
+func tree(files ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, f := range files {
+		fsys[f] = &fstest.MapFile{Data: []byte(f), Mode: 0644}
+	}
+	return fsys
+}
+
+// names returns the names of files, sorted.
+func names(files []File) []string {
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func keepAll(string) bool { return true }
+
+func TestGlob(t *testing.T) {
+	fsys := tree("a.c", "a.go", "csrc/b.c", "csrc/b.h", "csrc/deep/c.c", "docs/x.md")
+	patterns := []string{"**/*.c", "csrc/*.h", "./docs", ""}
+	matches, err := Glob(context.Background(), fsys, patterns, keepAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"a.c", "csrc/b.c", "csrc/deep/c.c"},
+		{"csrc/b.h"},
+		{"docs"},
+		{"a.c", "a.go", "csrc/b.c", "csrc/b.h", "csrc/deep/c.c", "docs/x.md"},
+	}
+	for i := range patterns {
+		if got := names(matches[i]); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Glob %q = %v, want %v", patterns[i], got, want[i])
+		}
+	}
+}
+
+func TestGlobKeep(t *testing.T) {
+	fsys := tree("a.c", "csrc/b.c", "other/c.c")
+	keep := func(name string) bool {
+		return path.Dir(name) != "other"
+	}
+	matches, err := Glob(context.Background(), fsys, []string{"**/*.c", ""}, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.c", "csrc/b.c"}
+	for i, m := range matches {
+		if got := names(m); !reflect.DeepEqual(got, want) {
+			t.Errorf("Glob pattern %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestGlobParallel(t *testing.T) {
+	SetJobs(4)
+	defer SetJobs(1)
+	matches, err := Glob(context.Background(), tree("a/1.c", "b/2.c", "c/3.c", "d/4.c", "e/5.c"), []string{"**/*.c"}, keepAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shards are concatenated in the order of a single walk.
+	var got []string
+	for _, f := range matches[0] {
+		got = append(got, f.Name)
+	}
+	want := []string{"a/1.c", "b/2.c", "c/3.c", "d/4.c", "e/5.c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob = %v, want %v", got, want)
+	}
+}
+
+func TestGlobZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []string{"a.c", "csrc/b.c", "csrc/b.h"} {
+		w, err := zw.Create("example.com/foo@v1.0.0/" + f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(f))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := fs.Sub(zr, "example.com/foo@v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories of the zip are matched as those of the extracted module.
+	matches, err := Glob(context.Background(), fsys, []string{"**/*.c", "csrc"}, keepAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(matches[0]), []string{"a.c", "csrc/b.c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob **/*.c = %v, want %v", got, want)
+	}
+	files, err := WalkFiles(context.Background(), fsys, matches[1][0].Name, keepAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(files), []string{"csrc/b.c", "csrc/b.h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkFiles csrc = %v, want %v", got, want)
+	}
+}
+
+func TestGlobMissingLiteral(t *testing.T) {
+	if _, err := Glob(context.Background(), tree("a.c"), []string{"missing.h"}, keepAll); !os.IsNotExist(err) {
+		t.Errorf("Glob of a missing file returned %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestGlobCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fsys := tree("a/1.c", "b/2.c")
+	if _, err := Glob(ctx, fsys, []string{"**/*.c"}, keepAll); err != context.Canceled {
+		t.Errorf("Glob with a canceled context returned %v, want context.Canceled", err)
+	}
+	if _, err := WalkFiles(ctx, fsys, ".", keepAll); err != context.Canceled {
+		t.Errorf("WalkFiles with a canceled context returned %v, want context.Canceled", err)
+	}
+}
+
+func TestSuffixes(t *testing.T) {
+	for _, tt := range []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"**/*.c", "csrc/*.h"}, []string{".c", ".h"}},
+		{[]string{"**/*.c", "**/*"}, nil},
+		{[]string{"csrc"}, nil},
+		{[]string{"**/*.{c,h}"}, nil},
+		{nil, nil},
+	} {
+		if got := Suffixes(tt.patterns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suffixes(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestHasSuffix(t *testing.T) {
+	defer func(fold bool) {
+		foldCase = fold
+	}(foldCase)
+	for _, tt := range []struct {
+		name, suffix string
+		fold, want   bool
+	}{
+		{"a.c", ".c", false, true},
+		{"FOO.C", ".c", false, false},
+		{"FOO.C", ".c", true, true},
+		{"a.h", ".c", true, false},
+		{"c", ".c", true, false},
+	} {
+		foldCase = tt.fold
+		if got := HasSuffix(tt.name, tt.suffix); got != tt.want {
+			t.Errorf("HasSuffix(%q, %q) folding case %v = %v, want %v", tt.name, tt.suffix, tt.fold, got, tt.want)
+		}
+	}
+}
+
+func TestRelPackage(t *testing.T) {
+	for _, tt := range []struct {
+		mod, pkg string
+		rel      string
+		ok       bool
+	}{
+		{"example.com/foo", "example.com/foo", "", true},
+		{"example.com/foo", "example.com/foo/bar/baz", "bar/baz", true},
+		{"example.com/foo", "example.com/foobar", "", false},
+		{"example.com/foo", "example.com/other", "", false},
+	} {
+		rel, ok := RelPackage(tt.mod, tt.pkg)
+		if rel != tt.rel || ok != tt.ok {
+			t.Errorf("RelPackage(%q, %q) = %q, %v, want %q, %v", tt.mod, tt.pkg, rel, ok, tt.rel, tt.ok)
+		}
+	}
+}
+
+func TestPackagesOf(t *testing.T) {
+	p := NewPackages("example.com/foo", []string{"example.com/foo", "example.com/foo/pkg/a", "example.com/foobar/x"})
+	for _, tt := range []struct {
+		file, want string
+	}{
+		{"x.c", "example.com/foo"},
+		{"pkg/a/y.c", "example.com/foo/pkg/a"},
+		{"pkg/a/deep/z.c", "example.com/foo/pkg/a"},
+		{"pkg/ab/y.c", "example.com/foo"},
+	} {
+		if got := p.Of(tt.file); got != tt.want {
+			t.Errorf("Of(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	p = NewPackages("example.com/foo", []string{"example.com/foo/pkg/a"})
+	if got := p.Of("pkg/ab/y.c"); got != "" {
+		t.Errorf("Of(pkg/ab/y.c) = %q, want none", got)
+	}
+}
+
+func TestWalkFilesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"mod/a.c", "mod/sub/b.c", "outside/secret.c"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.Join(dir, "mod")
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "link")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	var skipped []string
+	ctx := WithSkip(context.Background(), func(name, msg string) {
+		skipped = append(skipped, name)
+	})
+	fsys := DirFS(root)
+	files, err := WalkFiles(ctx, fsys, ".", keepAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The symlinked sub is walked once, by whichever name comes first.
+	want := []string{"a.c", "link/b.c"}
+	if got := names(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkFiles = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"escape"}) {
+		t.Errorf("WalkFiles skipped %q, want escape", skipped)
+	}
+
+	// Glob reports the symlinks it finds, for the caller to resolve.
+	matches, err := Glob(context.Background(), fsys, []string{"*"}, keepAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(matches[0]), []string{"a.c", "escape", "link", "sub"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Glob = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		name string
+		real string
+		ok   bool
+	}{
+		{"sub", "sub", true},
+		{"link/b.c", "sub/b.c", true},
+		{"escape", "", false},
+	} {
+		real, ok, err := Resolve(fsys, tt.name)
+		if err != nil || real != tt.real || ok != tt.ok {
+			t.Errorf("Resolve(%s) = %q, %v, %v, want %q, %v", tt.name, real, ok, err, tt.real, tt.ok)
+		}
+		ok, err = WithinRoot(root, filepath.Join(root, filepath.FromSlash(tt.name)))
+		if err != nil || ok != tt.ok {
+			t.Errorf("WithinRoot(%s) = %v, %v, want %v", tt.name, ok, err, tt.ok)
+		}
+	}
+
+	// In-memory filesystems have no symlinks to resolve.
+	if real, ok, err := Resolve(tree("a.c"), "a.c"); real != "a.c" || !ok || err != nil {
+		t.Errorf("Resolve of a MapFS = %q, %v, %v", real, ok, err)
+	}
+}
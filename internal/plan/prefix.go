@@ -0,0 +1,36 @@
+package plan
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPrefixPatterns reports whether any path prefix of target matches one
+// of the comma-separated glob patterns (GOPRIVATE, GONOPROXY, ...).
+func MatchPrefixPatterns(globs, target string) bool {
+	for _, glob := range strings.Split(globs, ",") {
+		glob = strings.TrimSuffix(strings.TrimSpace(glob), "/")
+		if glob == "" {
+			continue
+		}
+		n := strings.Count(glob, "/")
+		prefix := target
+		// Walk to the n'th slash, the glob can only match that many elements.
+		for i := 0; i < len(target); i++ {
+			if target[i] == '/' {
+				if n == 0 {
+					prefix = target[:i]
+					break
+				}
+				n--
+			}
+		}
+		if n > 0 {
+			continue
+		}
+		if ok, _ := path.Match(glob, prefix); ok {
+			return true
+		}
+	}
+	return false
+}
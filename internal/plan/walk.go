@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type skipKey struct{}
+
+// WithSkip returns ctx reporting the symlinks WalkFiles skips within it to
+// fn, with the warning. fn may be called concurrently.
+func WithSkip(ctx context.Context, fn func(name, msg string)) context.Context {
+	return context.WithValue(ctx, skipKey{}, fn)
+}
+
+// skipped warns about name skipped walking fsys.
+func skipped(ctx context.Context, fsys fs.FS, name string) {
+	msg := fmt.Sprintf("skipping symlink %s, it resolves outside of module root %s", Display(fsys, name), Display(fsys, "."))
+	if fn, ok := ctx.Value(skipKey{}).(func(name, msg string)); ok {
+		fn(name, msg)
+	}
+}
+
+// WithinRoot reports whether path, with all symlinks resolved, stays inside
+// root. Both root and path must exist.
+func WithinRoot(root, path string) (bool, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false, err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)), nil
+}
+
+// WalkFiles returns the files within dir of fsys which keep returns true
+// for, following symlinks within the root, with the entries the walk found
+// them with, until ctx is done.
+func WalkFiles(ctx context.Context, fsys fs.FS, dir string, keep func(name string) bool) ([]File, error) {
+	var files []File
+	err := walkFollowSymlink(ctx, fsys, dir, map[string]bool{}, func(name string, d fs.DirEntry, isDir bool) {
+		if !isDir && keep(name) {
+			files = append(files, File{Name: name, Entry: d})
+		}
+	})
+	return files, err
+}
+
+// walkFollowSymlink calls fn for dir and everything within it, in lexical
+// order. Symlinks within the root are followed, every directory is walked
+// once. isDir tells whether name is a directory, d may be a symlink to it.
+func walkFollowSymlink(ctx context.Context, fsys fs.FS, dir string, visited map[string]bool, fn func(name string, d fs.DirEntry, isDir bool)) error {
+	// WalkDir stats dir, a symlinked dir itself is walked.
+	return fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			if !d.IsDir() {
+				fn(name, d, false)
+				return nil
+			}
+			// Guard against symlink loops within the root.
+			real, _, err := Resolve(fsys, name)
+			if err != nil {
+				return err
+			}
+			if visited[real] {
+				return fs.SkipDir
+			}
+			visited[real] = true
+			fn(name, d, true)
+			return nil
+		}
+
+		_, ok, err := Resolve(fsys, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			skipped(ctx, fsys, name)
+			return nil
+		}
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return walkFollowSymlink(ctx, fsys, name, visited, fn)
+		}
+		fn(name, d, false)
+		return nil
+	})
+}
@@ -0,0 +1,58 @@
+// Package secret screens text for credentials, such as cloud access keys and
+// private keys, which upstream repositories occasionally leak in fixtures.
+package secret
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// Finding is a secret found, by its kind and line, never by its value.
+type Finding struct {
+	Kind string
+	Line int
+}
+
+// binarySniff is how much of the start of a file tells whether it is text,
+// as git tells it: by the NUL bytes there.
+const binarySniff = 8000
+
+// patterns recognize the secrets by kind, the first matching a line wins.
+var patterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`)},
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+]{40}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+}
+
+// Scan returns the secrets of the text read from r, line by line, none for
+// binary files.
+func Scan(r io.Reader) ([]Finding, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(binarySniff); bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	var findings []Finding
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		for _, p := range patterns {
+			if p.re.Match(line) {
+				findings = append(findings, Finding{Kind: p.kind, Line: n})
+				break
+			}
+		}
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return findings, err
+		}
+	}
+}
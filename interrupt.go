@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+// runCtx is done once a command handling interrupts gets SIGINT or SIGTERM.
+// Other commands are killed by them, as usual. The library warns within it
+// as modvendor does, of symlinks skipped walking modules say.
+var runCtx = vendoring.ContextWithLogger(context.Background(), cliLogger{})
+
+// handleInterrupts makes SIGINT and SIGTERM cancel runCtx, for commands
+// stopping at a point leaving their output consistent. A second signal kills
+// modvendor right away. Only the first call, of commands run by others in
+// turn, installs the handler.
+func handleInterrupts() {
+	interruptsOnce.Do(func() {
+		ctx, cancel := context.WithCancel(runCtx)
+		runCtx = ctx
+		sigs := make(chan os.Signal, 2)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			fmt.Fprintln(os.Stderr, "interrupting, press Ctrl-C again to quit right away")
+			cancel()
+			<-sigs
+			os.Exit(130)
+		}()
+	})
+}
+
+var interruptsOnce sync.Once
+
+// interrupted reports whether runCtx is done.
+func interrupted() bool {
+	return runCtx.Err() != nil
+}
+
+// errInterrupted returns the error reporting what an interrupted run left,
+// exiting with the status of a shell for commands killed by SIGINT.
+func errInterrupted(format string, args ...interface{}) error {
+	return &exitError{code: 130, msg: "interrupted, " + fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ansoda/modvendor/internal/license"
+)
+
+// licensePolicy is the licenses: of ./.modvendor.yml, SPDX identifiers of
+// the licenses vendored files may carry.
+type licensePolicy struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// checkLicenses detects the licenses of the files of entries, from license
+// files and the headers of sources, and reports those the policy doesn't
+// allow, unless q excuses them. Files telling no license are fine, down to
+// the license of their module.
+func checkLicenses(p *licensePolicy, entries []*vendorEntry, verbose bool, q *quarantine) error {
+	policy := &license.Policy{Allow: p.Allow, Deny: p.Deny}
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	violations := 0
+	for _, e := range entries {
+		if interrupted() {
+			return errInterrupted("nothing written")
+		}
+		head, err := readHead(sources, e, license.HeadSize)
+		if err != nil {
+			return fmt.Errorf("%w - unable to read %s for its license", err, e.Src)
+		}
+		expr := license.Detect(e.name(), head)
+		if expr == "" {
+			continue
+		}
+		if err := policy.Check(expr); err != nil {
+			if q.excuse(e, "license", err.Error()) {
+				continue
+			}
+			problem(outName(e.Dest), "license: %s of %s: %s", outName(e.Dest), e.Mod, err)
+			violations++
+		} else if verbose {
+			fmt.Printf("license %s: %s\n", expr, outName(e.Dest))
+		}
+	}
+	if violations > 0 {
+		return failed("%d files violate the license policy of %s, nothing written", violations, configName)
+	}
+	return nil
+}
+
+// checkModuleLicenses detects the license of each module, from the license
+// files at its root, and reports those allowed_licenses doesn't allow, with
+// the files it was read from. Modules without license files are unknown.
+func checkModuleLicenses(allowed []string, modules []*Mod, verbose bool) error {
+	policy := &license.Policy{Allow: allowed}
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	violations := 0
+	for _, mod := range modules {
+		if interrupted() {
+			return errInterrupted("nothing written")
+		}
+		expr, evidence, err := moduleLicense(sources, mod)
+		if err != nil {
+			return fmt.Errorf("%w - unable to read the license files of %s", err, mod)
+		}
+		if err := policy.Check(expr); err != nil {
+			problem(filepath.Join("vendor", "modules.txt"), "license: module %s: %s, from %s", mod, err, evidence)
+			violations++
+		} else if verbose {
+			fmt.Printf("license %s: module %s, from %s\n", expr, mod, evidence)
+		}
+	}
+	if violations > 0 {
+		return failed("%d modules have licenses outside allowed_licenses of %s, nothing written", violations, configName)
+	}
+	return nil
+}
+
+// moduleLicense returns the license expression of mod, that of all the
+// license files at its root, and the evidence: the files and what they read.
+func moduleLicense(sources *sourceFiles, mod *Mod) (string, string, error) {
+	fsys, err := sources.modFS(mod)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", "", err
+	}
+	var exprs, evidence []string
+	for _, d := range dir {
+		if d.IsDir() || !license.IsLicenseFile(d.Name()) {
+			continue
+		}
+		head, err := readModHead(fsys, d.Name(), license.HeadSize)
+		if err != nil {
+			return "", "", err
+		}
+		expr := license.Detect(d.Name(), head)
+		exprs = append(exprs, expr)
+		evidence = append(evidence, d.Name()+" ("+expr+")")
+	}
+	if len(exprs) == 0 {
+		return license.Unknown, "no license file at the module root", nil
+	}
+	sort.Strings(evidence)
+	return license.Combine(exprs), strings.Join(evidence, ", "), nil
+}
+
+// readHead returns the first n bytes of the source of e, all of it if
+// shorter.
+func readHead(sources *sourceFiles, e *vendorEntry, n int) ([]byte, error) {
+	fsys, err := sources.modFS(e.Mod)
+	if err != nil {
+		return nil, err
+	}
+	return readModHead(fsys, e.name(), n)
+}
+
+// readModHead returns the first n bytes of the file of a module filesystem.
+func readModHead(fsys fs.FS, name string, n int) ([]byte, error) {
+	r, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return io.ReadAll(io.LimitReader(r, int64(n)))
+}
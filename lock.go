@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+// lockName is the file, in the project root, pinning what modvendor vendors.
+const lockName = "modvendor.lock"
+
+// Lock pins the resolved modules, patterns and vendored content, later runs
+// report any drift from it.
+type Lock struct {
+	Patterns []string      `json:"patterns"`
+	Modules  []*LockModule `json:"modules"`
+}
+
+type LockModule struct {
+	Path           string `json:"path"`
+	Version        string `json:"version"`
+	Replace        string `json:"replace,omitempty"`
+	ReplaceVersion string `json:"replaceVersion,omitempty"`
+	Files          int    `json:"files"`
+	Hash           string `json:"hash"` // go.sum style h1 hash of the vendored files
+}
+
+func lockPath(cwd string) string {
+	return filepath.Join(cwd, lockName)
+}
+
+// newLock hashes the sources of entries per module, modules without entries
+// aren't locked. The hashes are go.sum's h1, of sha256 sums whatever the
+// checksum configured.
+func newLock(patterns []string, modules []*Mod, entries []*vendorEntry) (*Lock, error) {
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	byMod := map[*Mod]map[string]*vendorEntry{}
+	for _, e := range entries {
+		if byMod[e.Mod] == nil {
+			byMod[e.Mod] = map[string]*vendorEntry{}
+		}
+		byMod[e.Mod][strings.TrimPrefix(e.Dest, e.Mod.ImportPath+"/")] = e
+	}
+
+	l := &Lock{Patterns: patterns}
+	for _, mod := range modules {
+		files := byMod[mod]
+		if len(files) == 0 {
+			continue
+		}
+		sums := make(map[string]string, len(files))
+		for name, e := range files {
+			sum, err := sourceSumOf(sources, e, checksum.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			sums[name] = sum
+		}
+		hash, err := hash1Sums(sums)
+		if err != nil {
+			return nil, err
+		}
+		l.Modules = append(l.Modules, &LockModule{
+			Path:           mod.ImportPath,
+			Version:        mod.Version,
+			Replace:        mod.SourcePath,
+			ReplaceVersion: mod.SourceVersion,
+			Files:          len(files),
+			Hash:           hash,
+		})
+	}
+	saveHashCache()
+	return l, nil
+}
+
+func readLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var l Lock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func writeLock(path string, l *Lock) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// drift describes how current differs from the locked state l. Only modules
+// for which selected returns true are compared.
+func (l *Lock) drift(current *Lock, selected func(string) bool) []string {
+	var drift []string
+	if strings.Join(l.Patterns, " ") != strings.Join(current.Patterns, " ") {
+		drift = append(drift, fmt.Sprintf("patterns: %q locked, %q now", strings.Join(l.Patterns, " "), strings.Join(current.Patterns, " ")))
+	}
+
+	now := map[string]*LockModule{}
+	for _, m := range current.Modules {
+		now[m.Path] = m
+	}
+	for _, m := range l.Modules {
+		if !selected(m.Path) {
+			continue
+		}
+		c, ok := now[m.Path]
+		delete(now, m.Path)
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s: locked, but nothing is vendored from it now", m.Path))
+		case c.Version != m.Version:
+			drift = append(drift, fmt.Sprintf("%s: %s locked, %s now", m.Path, m.Version, c.Version))
+		case c.Replace != m.Replace || c.ReplaceVersion != m.ReplaceVersion:
+			drift = append(drift, fmt.Sprintf("%s: replaced by %q locked, by %q now", m.Path,
+				strings.TrimSpace(m.Replace+" "+m.ReplaceVersion), strings.TrimSpace(c.Replace+" "+c.ReplaceVersion)))
+		case c.Hash != m.Hash:
+			drift = append(drift, fmt.Sprintf("%s: vendored content changed, %d files %s locked, %d files %s now", m.Path, m.Files, m.Hash, c.Files, c.Hash))
+		}
+	}
+	for _, c := range current.Modules {
+		if _, ok := now[c.Path]; ok {
+			drift = append(drift, fmt.Sprintf("%s: vendored from now, but not locked", c.Path))
+		}
+	}
+	return drift
+}
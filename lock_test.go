@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/vendoring/vendortest"
+)
+
+func TestNewLockSHA256(t *testing.T) {
+	dir := t.TempDir()
+	fixture := &vendortest.Fixture{Modules: []vendortest.Module{{
+		Path:    "example.com/foo",
+		Version: "v1.0.0",
+		Files:   map[string]string{"foo.go": "package foo\n", "csrc/a.c": "int a;\n"},
+	}}}
+	if err := fixture.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOMODCACHE", filepath.Join(dir, vendortest.ModCacheDir))
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cwd := filepath.Join(dir, vendortest.ProjectDir)
+	outDir = "vendor"
+	defer func(a checksum.Algorithm) {
+		sumAlgorithm = a
+	}(sumAlgorithm)
+
+	modules, err := loadModules(cwd, &vendorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := modules[0]
+	e := &vendorEntry{Mod: mod, Src: filepath.Join(mod.Dir, "csrc", "a.c"), Dest: "example.com/foo/csrc/a.c"}
+	sum := sha256.Sum256([]byte("int a;\n"))
+	want, err := hash1Sums(map[string]string{"csrc/a.c": hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := newSourceFiles()
+	defer sources.Close()
+	// The hash cache holds the sums of the algorithm configured in between.
+	for _, a := range []checksum.Algorithm{checksum.BLAKE3, checksum.SHA256, checksum.SHA512, checksum.BLAKE3} {
+		sumAlgorithm = a
+		if sum, err := sourceSum(sources, e); err != nil || checksum.Of(sum) != a {
+			t.Fatalf("sourceSum with %s = %q, %v", a, sum, err)
+		}
+		l, err := newLock([]string{"**/*.c"}, modules, []*vendorEntry{e})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l.Modules) != 1 || l.Modules[0].Hash != want {
+			t.Errorf("lock with %s checksums = %+v, want hash %s", a, l.Modules, want)
+		}
+	}
+}
@@ -1,333 +1,267 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"go/build"
-	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
-	"unicode"
 
-	"github.com/mattn/go-zglob"
-	"github.com/otiai10/copy"
+	"github.com/ansoda/modvendor/internal/plan"
+	"github.com/ansoda/modvendor/vendoring"
 )
 
-var (
-	flags        = flag.NewFlagSet("modvendor", flag.ExitOnError)
-	copyPatFlag  = flags.String("copy", "", "copy files matching glob pattern to ./vendor/ (ie. modvendor -copy=\"**/*.c **/*.h **/*.proto\")")
-	fullCopyFlag = flags.Bool("fullcopy", true, "copy all project files to ./vendor/ (ie. modvendor -fullcopy=true")
-	verboseFlag  = flags.Bool("v", false, "verbose output")
-	includeFlag  = flags.String(
-		"include",
-		"",
-		`specifies additional directories to copy into ./vendor/ which are not specified in ./vendor/modules.txt. Multiple directories can be included by comma separation e.g. -include:github.com/a/b/dir1,github.com/a/b/dir1/dir2`)
-)
-
-type Mod struct {
-	ImportPath    string
-	SourcePath    string
-	Version       string
-	SourceVersion string
-	Dir           string          // full path, $GOPATH/pkg/mod/
-	Pkgs          []string        // sub-pkg import paths
-	VendorList    map[string]bool // files to vendor
+type command struct {
+	Name  string
+	Short string
+	Run   func(args []string) error
 }
 
-func main() {
-	err := flags.Parse(os.Args[1:])
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-
-	// Ensure go.mod file exists and we're running from the project root,
-	// and that ./vendor/modules.txt file exists.
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-	if _, err := os.Stat(filepath.Join(cwd, "go.mod")); os.IsNotExist(err) {
-		fmt.Println("Whoops, cannot find `go.mod` file")
-		os.Exit(1)
-	}
-	modtxtPath := filepath.Join(cwd, "vendor", "modules.txt")
-	if _, err := os.Stat(modtxtPath); os.IsNotExist(err) {
-		fmt.Println("Whoops, cannot find vendor/modules.txt, first run `go mod vendor` and try again")
-		os.Exit(1)
-	}
-
-	// Prepare vendor copy patterns
-	copyPat := strings.Split(strings.TrimSpace(*copyPatFlag), " ")
-	if len(copyPat) == 0 {
-		fmt.Println("Whoops, -copy argument is empty, nothing to copy.")
-		os.Exit(1)
-	}
-	additionalDirsToInclude := strings.Split(*includeFlag, ",")
-
-	// Parse/process modules.txt file of pkgs
-	f, _ := os.Open(modtxtPath)
-	defer func() {
-		_ = f.Close()
-	}()
-
-	scanner := bufio.NewScanner(f)
-	scanner.Split(bufio.ScanLines)
-
-	var mod *Mod
-	var modules []*Mod
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if line[0] == '#' {
-			s := strings.Split(line, " ")
-
-			// ignore patterns except for
-			// - ordinary module
-			//   # <mod> version
-			// - replace
-			//   # <mod> version => <mod1> version1
-			// - replace with local version
-			//   # <mod> version => <local path to mod1>
-			if (len(s) != 6 && len(s) != 5 && len(s) != 3) ||
-				s[1] == "explicit" {
-				continue
-			}
-
-			// issue https://github.com/golang/go/issues/33848 added these,
-			// see comments. I think we can get away with ignoring them.
-			if s[2] == "=>" {
-				continue
-			}
-
-			mod = &Mod{
-				ImportPath: s[1],
-				Version:    s[2],
-			}
-
-			// Handle "replace" in module file if any
-			if len(s) > 3 && s[3] == "=>" {
-				mod.SourcePath = s[4]
-
-				// Handle replaces with a relative target. For example:
-				// "replace github.com/status-im/status-go/protocol => ./protocol"
-				if strings.HasPrefix(s[4], ".") || strings.HasPrefix(s[4], "/") {
-					mod.Dir, err = filepath.Abs(s[4])
-					if err != nil {
-						fmt.Printf("invalid relative path: %v", err)
-						os.Exit(1)
-					}
-				} else {
-					mod.SourceVersion = s[5]
-
-					dir, err := pkgModPath(mod.SourcePath, mod.SourceVersion)
-					if err != nil {
-						fmt.Printf("Error! couldn't resolve module path for %q: %v\n", mod.SourcePath, err)
-						os.Exit(1)
-					}
-					mod.Dir = dir
-				}
-			} else {
-				dir, err := pkgModPath(mod.ImportPath, mod.Version)
-				if err != nil {
-					fmt.Printf("Error! couldn't resolve module path for %q: %v\n", mod.ImportPath, err)
-					os.Exit(1)
-				}
-				mod.Dir = dir
-			}
+var commands = []*command{
+	copyCmd,
+	applyCmd,
+	multiCmd,
+	initCmd,
+	lockCmd,
+	hashCmd,
+	verifyCmd,
+	cleanCmd,
+	listCmd,
+	explainCmd,
+	whichCmd,
+	provenanceCmd,
+	diffCmd,
+	statusCmd,
+	statsCmd,
+	manifestCmd,
+	benchCmd,
+	watchCmd,
+	serveCmd,
+	packCmd,
+	versionCmd,
+	completionCmd,
+}
 
-			if _, err := os.Stat(mod.Dir); os.IsNotExist(err) {
-				fmt.Printf("Error! %q module path does not exist (importParth=%s), check $GOPATH/pkg/mod\n", mod.Dir, mod.ImportPath)
-				os.Exit(1)
-			}
+func usage() {
+	fmt.Fprintf(os.Stderr, `modvendor copies additional module files, such as C sources, into ./vendor/.
+It should be run after `+"`go mod vendor`"+`.
 
-			// Build list of files to module path source to project vendor folder
-			mod.VendorList = buildModVendorList(copyPat, mod)
-			// Append directories we need to also include which may not be in vendor/modules.txt.
-			for _, dir := range additionalDirsToInclude {
-				if strings.HasPrefix(dir, mod.ImportPath) {
-					mod.Pkgs = append(mod.Pkgs, dir)
-				}
-			}
+Usage:
 
-			modules = append(modules, mod)
+	modvendor <command> [flags]
 
-			if *fullCopyFlag {
-				mod.Pkgs = append(mod.Pkgs, mod.ImportPath)
-			}
-			continue
-		}
+Commands:
 
-		if !(*fullCopyFlag) {
-			mod.Pkgs = append(mod.Pkgs, line)
-		}
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-10s %s\n", cmd.Name, cmd.Short)
 	}
+	fmt.Fprintf(os.Stderr, `
+Run "modvendor <command> -h" for the flags of a command. Without a command
+modvendor runs copy, e.g. modvendor -copy="**/*.c **/*.h".
+`)
+}
 
-	// Filter out files not part of the mod.Pkgs
-	for _, mod := range modules {
-		if len(mod.VendorList) == 0 {
-			continue
+func main() {
+	args := os.Args[1:]
+
+	// modvendor used to be a single flat command, keep running it as copy.
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			usage()
+			return
 		}
-		for vendorFile := range mod.VendorList {
-			for _, subpkg := range mod.Pkgs {
-				path := filepath.Join(mod.Dir, importPathIntersect(mod.ImportPath, subpkg))
+		args = append([]string{"copy"}, args...)
+	}
+	if len(args) == 0 {
+		args = []string{"copy"}
+	}
 
-				x := strings.Index(vendorFile, path)
-				if x == 0 {
-					mod.VendorList[vendorFile] = true
-				}
-			}
-		}
-		for vendorFile, toggle := range mod.VendorList {
-			if !toggle {
-				delete(mod.VendorList, vendorFile)
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if cmd := lookupCommand(args[1]); cmd != nil {
+				_ = cmd.Run([]string{"-h"})
+				return
 			}
 		}
+		usage()
+		return
 	}
 
-	// Copy mod vendor list files to ./vendor/
-	for _, mod := range modules {
-		for vendorFile := range mod.VendorList {
-			x := strings.Index(vendorFile, mod.Dir)
-			if x < 0 {
-				fmt.Println("Error! vendor file doesn't belong to mod, strange.")
-				os.Exit(1)
-			}
-
-			localPath := fmt.Sprintf("%s%s", mod.ImportPath, vendorFile[len(mod.Dir):])
-			localFile := fmt.Sprintf("./vendor/%s", localPath)
-
-			if *verboseFlag {
-				fmt.Printf("vendoring %s\n", localPath)
-			}
-
-			if err := os.MkdirAll(filepath.Dir(localFile), os.ModePerm); err != nil {
-				fmt.Printf("Error! %s - unable to create directory %s\n", err.Error(), filepath.Dir(localFile))
-				os.Exit(1)
-			}
-
-			var opt copy.Options
-			opt.PermissionControl = copy.AddPermission(0644)
-			if err := copy.Copy(vendorFile, localFile, opt); err != nil {
-				fmt.Printf("Error! %s - unable to copy file %s\n", err.Error(), vendorFile)
-				os.Exit(1)
-			}
-		}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "modvendor: unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	err := cmd.Run(args[1:])
+	writeProfiles()
+	if err != nil {
+		os.Exit(exitStatus(err))
 	}
 }
 
-func buildModVendorList(copyPat []string, mod *Mod) map[string]bool {
-	vendorList := map[string]bool{}
-
-	for _, pat := range copyPat {
-		var matches []string
-		var err error
-		if len(pat) > 0 {
-			matches, err = zglob.Glob(filepath.Join(mod.Dir, pat))
-		} else {
-			matches, err = getDirAllEntryPathsFollowSymlink(mod.Dir, true)
-		}
-		if err != nil {
-			fmt.Println("Error! glob match failure:", err)
-			os.Exit(1)
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
 		}
+	}
+	return nil
+}
 
-		for _, m := range matches {
-			vendorList[m] = false
-		}
+// newFlagSet returns the flag set of a command, its usage message lists the
+// flags after the given synopsis and description.
+func newFlagSet(name, synopsis, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet("modvendor "+name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: modvendor %s %s\n\n%s\n\nFlags:\n", name, synopsis, description)
+		flags.PrintDefaults()
 	}
+	registerProfiles(flags)
+	return flags
+}
 
-	return vendorList
+// vendorOptions are the flags of every command that needs to work out which
+// files to vendor.
+type vendorOptions struct {
+	copyPat    string
+	fullCopy   bool
+	include    string
+	fetch      bool
+	verbose    bool
+	only       string
+	skip       string
+	jobs       int
+	maxSize    byteSize
+	sources    []moduleSource
+	plugins    []string
+	licenses   *licensePolicy
+	allowed    []string
+	quarantine string // set by copy -quarantine, which checks sizes itself
+	safe       bool
+	denyTypes  []string
 }
 
-func importPathIntersect(basePath, pkgPath string) string {
-	if strings.Index(pkgPath, basePath) != 0 {
-		return ""
+// register adds the flags to flags, defaulting to the values of
+// ./.modvendor.yml.
+func (o *vendorOptions) register(flags *flag.FlagSet) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registerOutDir(flags, cfg)
+	registerOutput(flags)
+	fullCopy := true
+	if cfg.FullCopy != nil {
+		fullCopy = *cfg.FullCopy
 	}
-	return pkgPath[len(basePath):]
+	flags.StringVar(&o.copyPat, "copy", strings.Join(cfg.Copy, " "), "copy files matching glob pattern to ./vendor/ (ie. modvendor -copy=\"**/*.c **/*.h **/*.proto\")")
+	flags.BoolVar(&o.fullCopy, "fullcopy", fullCopy, "copy all project files to ./vendor/ (ie. modvendor -fullcopy=true")
+	flags.StringVar(&o.include, "include", strings.Join(cfg.Include, ","),
+		`specifies additional directories to copy into ./vendor/ which are not specified in ./vendor/modules.txt. Multiple directories can be included by comma separation e.g. -include:github.com/a/b/dir1,github.com/a/b/dir1/dir2`)
+	flags.BoolVar(&o.fetch, "fetch", false, "download modules missing from the module cache from GOPROXY")
+	flags.BoolVar(&offline, "offline", cfg.Offline, "never use the network: don't fetch modules, even for sources from proxy, and run the go command, hooks and plugins with GOPROXY=off GOTOOLCHAIN=local")
+	flags.BoolVar(&o.verbose, "v", false, "verbose output")
+	flags.StringVar(&o.only, "only", strings.Join(cfg.Only, ","), "only vendor files of modules matching the comma separated module path patterns, as in GOPRIVATE")
+	flags.StringVar(&o.skip, "skip", strings.Join(cfg.Skip, ","), "don't vendor files of modules matching the comma separated module path patterns, as in GOPRIVATE")
+	flags.IntVar(&o.jobs, "j", runtime.NumCPU(), "number of modules to process in parallel")
+	flags.Var(&o.maxSize, "max-size", "leave out matched files larger than the given size, e.g. 1M")
+	o.sources = cfg.Sources
+	o.plugins = append([]string(nil), cfg.Plugins...)
+	o.licenses = cfg.Licenses
+	o.allowed = cfg.AllowedLicenses
+	o.denyTypes = cfg.DenyTypes
+	flags.BoolVar(&o.safe, "safe", cfg.Safe, "refuse to vendor prebuilt libraries and executables: "+strings.Join(denyTypesOf(o.denyTypes), " ")+" files")
+	flags.Func("plugin", "command deciding which files to vendor, and where, after the plugins of the configuration; may be repeated", func(command string) error {
+		o.plugins = append(o.plugins, command)
+		return nil
+	})
+	return nil
 }
 
-func normString(str string) (normStr string) {
-	for _, char := range str {
-		if unicode.IsUpper(char) {
-			normStr += "!" + string(unicode.ToLower(char))
-		} else {
-			normStr += string(char)
-		}
+// parallelism returns the number of modules to process at once.
+func (o *vendorOptions) parallelism() int {
+	if o.jobs < 1 {
+		return 1
 	}
-	return
+	return o.jobs
 }
 
-func pkgModPath(importPath, version string) (string, error) {
-	normPath := normString(importPath)
-	normVersion := normString(version)
-	return filepath.Join(build.Default.GOPATH, "pkg", "mod", fmt.Sprintf("%s@%s", normPath, normVersion)), nil
+// patterns returns the copy patterns, an empty pattern matches everything.
+func (o *vendorOptions) patterns() []string {
+	return strings.Split(strings.TrimSpace(o.copyPat), " ")
 }
 
-func copyFile(src, dst string) (int64, error) {
-	srcStat, err := os.Stat(src)
-	if err != nil {
-		return 0, err
-	}
+// outDir is the directory assets are copied to and the manifest is kept in,
+// relative to the project root unless absolute.
+var outDir = "vendor"
 
-	if !srcStat.Mode().IsRegular() {
-		return 0, fmt.Errorf("%s is not a regular file", src)
+// registerOutDir adds -o and -out setting outDir, defaulting to the out
+// setting of the configuration.
+func registerOutDir(flags *flag.FlagSet, cfg *config) {
+	def := outDir
+	if cfg.Out != "" {
+		def = cfg.Out
 	}
+	flags.StringVar(&outDir, "o", def, "directory, relative to the project root, to copy files to instead of ./vendor/")
+	flags.StringVar(&outDir, "out", def, "same as -o")
+}
 
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return 0, err
+// outPath returns the path of the asset at dest, a slash separated path
+// relative to the output directory.
+func outPath(cwd, dest string) string {
+	dir := outDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(cwd, dir)
 	}
-	defer func() {
-		_ = srcFile.Close()
-	}()
+	return filepath.Join(dir, filepath.FromSlash(dest))
+}
 
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return 0, err
-	}
-	defer func() {
-		_ = dstFile.Close()
-	}()
+// outName returns dest prefixed with the output directory, for messages.
+func outName(dest string) string {
+	return path.Join(filepath.ToSlash(filepath.Clean(outDir)), dest)
+}
 
-	return io.Copy(dstFile, srcFile)
+// filters returns the filters files matching the copy patterns must pass.
+func (o *vendorOptions) filters() []vendoring.Filter {
+	var filters []vendoring.Filter
+	if o.maxSize > 0 && o.quarantine == "" {
+		filters = append(filters, vendoring.MaxSize(int64(o.maxSize)))
+	}
+	return filters
 }
 
-// getDirAllEntryPathsFollowSymlink gets all the file or dir paths in the specified directory recursively.
-func getDirAllEntryPathsFollowSymlink(dirname string, incl bool) ([]string, error) {
-	// Remove the trailing path separator if dirname has.
-	dirname = strings.TrimSuffix(dirname, string(os.PathSeparator))
+func (o *vendorOptions) includes() []string {
+	return strings.Split(o.include, ",")
+}
 
-	infos, err := os.ReadDir(dirname)
-	if err != nil {
-		return nil, err
+// selected reports whether files of the module are vendored given -only and
+// -skip.
+func (o *vendorOptions) selected(importPath string) bool {
+	if o.only != "" && !plan.MatchPrefixPatterns(o.only, importPath) {
+		return false
 	}
+	return !plan.MatchPrefixPatterns(o.skip, importPath)
+}
 
-	paths := make([]string, 0, len(infos))
-	// Include current dir.
-	if incl {
-		paths = append(paths, dirname)
+// splitManifest removes the modules left out by -only and -skip from m and
+// returns them, their files are none of this run's business.
+func (o *vendorOptions) splitManifest(m *Manifest) []*ManifestModule {
+	if m == nil {
+		return nil
 	}
-
-	for _, info := range infos {
-		path := dirname + string(os.PathSeparator) + info.Name()
-		realInfo, err := os.Stat(path)
-		if err != nil {
-			return nil, err
-		}
-		if realInfo.IsDir() {
-			tmp, err := getDirAllEntryPathsFollowSymlink(path, incl)
-			if err != nil {
-				return nil, err
-			}
-			paths = append(paths, tmp...)
-			continue
+	var selected, others []*ManifestModule
+	for _, mm := range m.Modules {
+		if o.selected(mm.Path) {
+			selected = append(selected, mm)
+		} else {
+			others = append(others, mm)
 		}
-		paths = append(paths, path)
 	}
-	return paths, nil
+	m.Modules = selected
+	return others
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+// manifestName is the file, relative to the output directory (./vendor/ by
+// default), recording what modvendor copied on its last run.
+const manifestName = vendoring.ManifestName
+
+// sumAlgorithm checksums the copied files, as the checksum of ./.modvendor.yml
+// configures. Sums of other algorithms than sha256 are prefixed with theirs,
+// see checksum.Algorithm.Sum, readers go by the prefix.
+var sumAlgorithm = checksum.SHA256
+
+// sumsName returns the file, next to the manifest, listing the checksums of
+// the copied files for `sha256sum -c`, `sha512sum -c` or `b3sum -c`.
+func sumsName(a checksum.Algorithm) string {
+	return "modvendor." + string(a)
+}
+
+type Manifest struct {
+	Generated time.Time         `json:"generated"`
+	Tool      buildVersion      `json:"tool"`
+	Patterns  []string          `json:"patterns"`
+	State     string            `json:"state,omitempty"` // see copyState
+	Modules   []*ManifestModule `json:"modules"`
+}
+
+type ManifestModule struct {
+	Path           string         `json:"path"`
+	Version        string         `json:"version"`
+	Replace        string         `json:"replace,omitempty"`
+	ReplaceVersion string         `json:"replaceVersion,omitempty"`
+	VCS            *VCSStatus     `json:"vcs,omitempty"`
+	Dir            string         `json:"dir"`             // module cache or replace directory
+	Zip            string         `json:"zip,omitempty"`   // set when copied from the module zip
+	GoSum          string         `json:"goSum,omitempty"` // go.sum hash of the module or its replacement, none for local replaces
+	Files          []ManifestFile `json:"files"`
+	Generated      []string       `json:"generated,omitempty"` // by -embed or -bazel, relative to the output directory
+}
+
+// String describes the module like Mod.String does.
+func (mm *ManifestModule) String() string {
+	s := mm.Path + " " + mm.Version
+	if mm.Replace != "" {
+		s += " => " + strings.TrimSpace(mm.Replace+" "+mm.ReplaceVersion)
+	}
+	return s
+}
+
+// Name returns the name of the file f of the module was copied from, slash
+// separated and relative to the module root.
+func (mm *ManifestModule) Name(f *ManifestFile) string {
+	if f.Src != "" {
+		return f.Src
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(f.Path, mm.Path), "/")
+}
+
+// Source returns where the vendored file f was copied from. Files read from
+// a module zip are described as "<zip>!<name>".
+func (mm *ManifestModule) Source(f *ManifestFile) string {
+	rel := mm.Name(f)
+	if mm.Zip != "" {
+		if mm.ReplaceVersion != "" {
+			return mm.Zip + "!" + mm.Replace + "@" + mm.ReplaceVersion + "/" + rel
+		}
+		return mm.Zip + "!" + mm.Path + "@" + mm.Version + "/" + rel
+	}
+	return filepath.Join(mm.Dir, filepath.FromSlash(rel))
+}
+
+type ManifestFile struct {
+	Path   string     `json:"path"`             // relative to ./vendor/
+	Src    string     `json:"src,omitempty"`    // within the module, set when a plugin rewrote Path
+	Sum    string     `json:"sha256"`           // prefixed with the algorithm unless sha256, see checksum.Algorithm.Sum
+	Copied *time.Time `json:"copied,omitempty"` // by the run that copied this content, unset by older versions
+}
+
+// copiedTime returns when f was copied in RFC 3339 format, "" if unknown.
+func (f *ManifestFile) copiedTime() string {
+	if f.Copied == nil {
+		return ""
+	}
+	return f.Copied.UTC().Format(time.RFC3339)
+}
+
+func manifestPath(cwd string) string {
+	return outPath(cwd, manifestName)
+}
+
+// newManifest describes the copied entries, grouped by module. Modules get
+// their hash in sums, go.sum of the project, if any.
+func newManifest(patterns []string, modules []*Mod, entries []*vendorEntry, sums map[string]string) *Manifest {
+	m := &Manifest{
+		Generated: time.Now().UTC().Truncate(time.Second),
+		Tool:      currentVersion(),
+		Patterns:  patterns,
+	}
+	byMod := map[*Mod]*ManifestModule{}
+	for _, e := range entries {
+		mm, ok := byMod[e.Mod]
+		if !ok {
+			mm = &ManifestModule{
+				Path:           e.Mod.ImportPath,
+				Version:        e.Mod.Version,
+				Replace:        e.Mod.SourcePath,
+				ReplaceVersion: e.Mod.SourceVersion,
+				VCS:            e.Mod.VCS,
+				Dir:            e.Mod.Dir,
+				Zip:            e.Mod.Zip,
+				GoSum:          sums[goSumKey(e.Mod)],
+			}
+			byMod[e.Mod] = mm
+		}
+		copied := e.Copied
+		if copied.IsZero() {
+			copied = m.Generated
+		}
+		f := ManifestFile{Path: e.Dest, Sum: e.Sum, Copied: &copied}
+		if name := e.name(); e.Dest != e.Mod.ImportPath+"/"+name {
+			f.Src = name
+		}
+		mm.Files = append(mm.Files, f)
+	}
+	// Keep modules.txt order.
+	for _, mod := range modules {
+		if mm, ok := byMod[mod]; ok {
+			m.Modules = append(m.Modules, mm)
+		}
+	}
+	return m
+}
+
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func writeManifest(path string, m *Manifest) error {
+	for _, mm := range m.Modules {
+		sort.Slice(mm.Files, func(i, j int) bool {
+			return mm.Files[i].Path < mm.Files[j].Path
+		})
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// writeSums writes the checksums recorded in m to path in the format of
+// sha256sum, file names are relative to the directory of path.
+func writeSums(path string, m *Manifest) error {
+	var files []ManifestFile
+	for _, mm := range m.Modules {
+		files = append(files, mm.Files...)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	var b strings.Builder
+	for _, f := range files {
+		_, digest := checksum.Split(f.Sum)
+		b.WriteString(digest + "  " + f.Path + "\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/vendoring/vendortest"
+)
+
+func TestVerifyDeepRewritten(t *testing.T) {
+	dir := t.TempDir()
+	fixture := &vendortest.Fixture{Modules: []vendortest.Module{{
+		Path:    "example.com/foo",
+		Version: "v1.0.0",
+		Files:   map[string]string{"foo.go": "package foo\n", "csrc/a.c": "int a;\n", "csrc/b.c": "int b;\n"},
+	}}}
+	if err := fixture.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOMODCACHE", filepath.Join(dir, vendortest.ModCacheDir))
+	cwd := filepath.Join(dir, vendortest.ProjectDir)
+	outDir, sumAlgorithm = "vendor", checksum.SHA256
+
+	modules, err := loadModules(cwd, &vendorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := modules[0]
+	// A plugin rewrote csrc/a.c to renamed.c, csrc/b.c is where it belongs.
+	var entries []*vendorEntry
+	for name, dest := range map[string]string{"csrc/a.c": "example.com/foo/renamed.c", "csrc/b.c": "example.com/foo/csrc/b.c"} {
+		e := &vendorEntry{Mod: mod, Src: filepath.Join(mod.Dir, filepath.FromSlash(name)), Dest: dest}
+		data, err := os.ReadFile(e.Src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := outPath(cwd, dest)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if e.Sum, err = fileSum(path, sumAlgorithm); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	manifest := newManifest([]string{"**/*.c"}, modules, entries, nil)
+	for i := range manifest.Modules[0].Files {
+		f := &manifest.Modules[0].Files[i]
+		want := ""
+		if f.Path == "example.com/foo/renamed.c" {
+			want = "csrc/a.c"
+		}
+		if f.Src != want {
+			t.Errorf("Src of %s = %q, want %q", f.Path, f.Src, want)
+		}
+		if src, want := manifest.Modules[0].Source(f), filepath.Join(mod.Dir, filepath.FromSlash(manifest.Modules[0].Name(f))); src != want {
+			t.Errorf("Source of %s = %q, want %q", f.Path, src, want)
+		}
+	}
+	ok, err := verifyDeep(cwd, manifest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("verifyDeep = false, want the rewritten file to match its source")
+	}
+}
@@ -0,0 +1,133 @@
+// Package modtxt parses vendor/modules.txt, as written by go mod vendor, for
+// tools other than modvendor to reuse. It has no dependencies beyond the
+// standard library.
+//
+//	f, err := os.Open("vendor/modules.txt")
+//	...
+//	modules, err := modtxt.Parse(f)
+package modtxt
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Module is a module of modules.txt.
+type Module struct {
+	Path    string
+	Version string
+
+	// Replace is the replacement recorded for the module, if any.
+	// ReplaceVersion is empty when it is a local directory.
+	Replace        string
+	ReplaceVersion string
+
+	// Explicit is set for modules required by go.mod itself rather than
+	// by other modules, and GoVersion to the go directive of the module's
+	// go.mod, as annotated by "## explicit; go 1.18".
+	Explicit  bool
+	GoVersion string
+
+	Packages []string // vendored packages of the module
+}
+
+// Local reports whether the module is replaced by a local directory.
+func (m *Module) Local() bool {
+	return m.Replace != "" && m.ReplaceVersion == ""
+}
+
+// Parse parses the content of a modules.txt. Lines it doesn't know about are
+// skipped.
+func Parse(r io.Reader) ([]*Module, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	var mod *Module
+	var modules []*Module
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '#' {
+			// "## explicit; go 1.18" and friends annotate the module above.
+			if strings.HasPrefix(line, "##") {
+				if mod != nil {
+					annotate(mod, line[2:])
+				}
+				continue
+			}
+
+			s := strings.Split(line, " ")
+
+			// Newer toolchains may record "# go 1.22.1" / "# toolchain go1.22.1",
+			// these look like "# <mod> version" but aren't modules.
+			if IsDirective(s[1:]) {
+				continue
+			}
+
+			// ignore patterns except for
+			// - ordinary module
+			//   # <mod> version
+			// - replace
+			//   # <mod> version => <mod1> version1
+			// - replace with local version
+			//   # <mod> version => <local path to mod1>
+			if (len(s) != 6 && len(s) != 5 && len(s) != 3) ||
+				s[1] == "explicit" {
+				continue
+			}
+
+			// issue https://github.com/golang/go/issues/33848 added these,
+			// see comments. I think we can get away with ignoring them.
+			if s[2] == "=>" {
+				continue
+			}
+
+			mod = &Module{
+				Path:    s[1],
+				Version: s[2],
+			}
+
+			// Handle "replace" in module file if any
+			if len(s) > 3 && s[3] == "=>" {
+				mod.Replace = s[4]
+				if len(s) == 6 {
+					mod.ReplaceVersion = s[5]
+				}
+			}
+
+			modules = append(modules, mod)
+			continue
+		}
+
+		if mod == nil || IsDirective(strings.Fields(line)) {
+			continue
+		}
+		mod.Packages = append(mod.Packages, line)
+	}
+
+	return modules, scanner.Err()
+}
+
+// annotate sets what the "##" annotation line records about mod.
+func annotate(mod *Module, line string) {
+	for _, a := range strings.Split(line, ";") {
+		f := strings.Fields(a)
+		switch {
+		case len(f) == 1 && f[0] == "explicit":
+			mod.Explicit = true
+		case len(f) == 2 && f[0] == "go":
+			mod.GoVersion = f[1]
+		}
+	}
+}
+
+// IsDirective reports whether the fields of a modules.txt or go.mod line
+// form a go or toolchain directive, e.g. "go 1.22.1" or "toolchain go1.22.1".
+func IsDirective(s []string) bool {
+	return len(s) == 2 && (s[0] == "go" || s[0] == "toolchain")
+}
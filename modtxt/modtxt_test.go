@@ -0,0 +1,82 @@
+package modtxt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const modulesTxt = `# github.com/foo/bar v1.0.0
+## explicit; go 1.18
+github.com/foo/bar
+github.com/foo/bar/pkg/a
+# github.com/baz/qux v0.2.0 => github.com/fork/qux v0.2.1
+## explicit
+github.com/baz/qux
+# example.com/local v1.1.0 => ../local
+example.com/local/sub
+# example.com/indirect v1.0.0
+## go 1.21
+# example.com/replaced => example.com/other v1.0.0
+# go 1.22.1
+# toolchain go1.22.1
+# explicit
+`
+
+func TestParse(t *testing.T) {
+	modules, err := Parse(strings.NewReader(modulesTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Module{
+		{Path: "github.com/foo/bar", Version: "v1.0.0", Explicit: true, GoVersion: "1.18", Packages: []string{"github.com/foo/bar", "github.com/foo/bar/pkg/a"}},
+		{Path: "github.com/baz/qux", Version: "v0.2.0", Replace: "github.com/fork/qux", ReplaceVersion: "v0.2.1", Explicit: true, Packages: []string{"github.com/baz/qux"}},
+		{Path: "example.com/local", Version: "v1.1.0", Replace: "../local", Packages: []string{"example.com/local/sub"}},
+		{Path: "example.com/indirect", Version: "v1.0.0", GoVersion: "1.21"},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		for _, m := range modules {
+			t.Logf("got %+v", *m)
+		}
+		t.Fatalf("Parse returned %d modules, want %d", len(modules), len(want))
+	}
+}
+
+func TestLocal(t *testing.T) {
+	modules, err := Parse(strings.NewReader(modulesTxt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{false, false, true, false} {
+		if got := modules[i].Local(); got != want {
+			t.Errorf("%s Local() = %v, want %v", modules[i].Path, got, want)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	modules, err := Parse(strings.NewReader("\n# go 1.21\ngithub.com/orphan/pkg\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 0 {
+		t.Fatalf("Parse returned %d modules, want none", len(modules))
+	}
+}
+
+func TestIsDirective(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want bool
+	}{
+		{"go 1.22.1", true},
+		{"toolchain go1.22.1", true},
+		{"github.com/foo/bar v1.0.0", false},
+		{"go", false},
+		{"go 1.22 extra", false},
+	} {
+		if got := IsDirective(strings.Fields(tt.line)); got != tt.want {
+			t.Errorf("IsDirective(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/ansoda/modvendor/internal/plan"
+	"github.com/ansoda/modvendor/modtxt"
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+type Mod struct {
+	ImportPath    string
+	SourcePath    string
+	Version       string
+	SourceVersion string
+	Dir           string                  // full path, $GOPATH/pkg/mod/
+	Zip           string                  // module zip, set when Dir isn't extracted
+	Pkgs          []string                // sub-pkg import paths
+	VendoredPkgs  []string                // packages listed in modules.txt
+	VendorList    map[string]*vendorMatch // files to vendor
+	VCS           *VCSStatus              // local replace checkout status
+	Timing        modTiming               // of the current run
+}
+
+// String describes the module along with its replacement, if any.
+func (mod *Mod) String() string {
+	s := mod.ImportPath + " " + mod.Version
+	if mod.SourcePath != "" {
+		s += " => " + strings.TrimSpace(mod.SourcePath+" "+mod.SourceVersion)
+	}
+	return s
+}
+
+// module returns mod as the vendoring package describes it, its files read
+// from fsys.
+func (mod *Mod) module(fsys fs.FS) *vendoring.Module {
+	return &vendoring.Module{
+		Path:           mod.ImportPath,
+		Version:        mod.Version,
+		Replace:        mod.SourcePath,
+		ReplaceVersion: mod.SourceVersion,
+		Packages:       mod.Pkgs,
+		FS:             fsys,
+	}
+}
+
+// projectRoot ensures go.mod file exists and we're running from the project
+// root, or the root of a workspace vendored by go work vendor, and that
+// ./vendor/modules.txt file exists.
+func projectRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "go.mod")); os.IsNotExist(err) && !workspaceRoot(cwd) {
+		return "", &usageError{vendoring.ErrMissingGoMod}
+	}
+	modtxtPath := filepath.Join(cwd, "vendor", "modules.txt")
+	if _, err := os.Stat(modtxtPath); os.IsNotExist(err) {
+		return "", &usageError{vendoring.ErrMissingModulesTxt}
+	}
+	return cwd, nil
+}
+
+// loadModules parses ./vendor/modules.txt of the project in cwd and works out
+// where the files of each module come from: local replaces, or the resolvers
+// of the module, see resolversOf.
+func loadModules(cwd string, opts *vendorOptions) ([]*Mod, error) {
+	if offline && opts.fetch {
+		return nil, whoops("-fetch can't be combined with -offline")
+	}
+	replaceDirs, err := loadReplaces(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("%w - unable to read replace directives", err)
+	}
+
+	listed, err := readModulesTxt(cwd)
+	if err != nil {
+		return nil, err
+	}
+	var modules []*Mod
+	for _, mod := range listed {
+		if opts.selected(mod.ImportPath) {
+			modules = append(modules, mod)
+		}
+	}
+	var misses []*vendoring.ModuleCacheMissError
+	for _, mod := range modules {
+		if opts.fullCopy {
+			mod.Pkgs = append(mod.Pkgs, mod.ImportPath)
+		} else {
+			mod.Pkgs = append(mod.Pkgs, mod.VendoredPkgs...)
+		}
+		// Append directories we need to also include which may not be in vendor/modules.txt.
+		for _, dir := range opts.includes() {
+			if _, ok := plan.RelPackage(mod.ImportPath, dir); ok {
+				mod.Pkgs = append(mod.Pkgs, dir)
+			}
+		}
+
+		// modules.txt only has the first replacement, chained replaces in
+		// go.mod or go.work are resolved on top of it.
+		target := replacement{Path: mod.ImportPath, Version: mod.Version}
+		if mod.SourcePath != "" {
+			target = replacement{Path: mod.SourcePath, Version: mod.SourceVersion}
+		}
+		target, err = replaceDirs.resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		mod.SourcePath, mod.SourceVersion = "", ""
+
+		if target.Path != mod.ImportPath || target.Version != mod.Version {
+			mod.SourcePath = target.Path
+
+			// Handle replaces with a relative target. For example:
+			// "replace github.com/status-im/status-go/protocol => ./protocol"
+			if target.local() {
+				dir := target.Dir
+				if dir == "" {
+					dir = target.Path
+				}
+				mod.Dir, err = filepath.Abs(dir)
+				if err != nil {
+					return nil, fmt.Errorf("invalid relative path: %w", err)
+				}
+				if _, err := os.Stat(mod.Dir); err != nil {
+					miss := &vendoring.ModuleCacheMissError{Module: *mod.module(nil), Dir: mod.Dir}
+					if !offline {
+						return nil, miss
+					}
+					misses = append(misses, miss)
+					continue
+				}
+				mod.VCS = localVCSStatus(mod.Dir)
+				continue
+			}
+			mod.SourceVersion = target.Version
+		}
+
+		// The first resolver having the files of the module, or of its
+		// replacement, is where they come from.
+		path, version := mod.ImportPath, mod.Version
+		if mod.SourcePath != "" {
+			path, version = mod.SourcePath, mod.SourceVersion
+		}
+		resolvers, err := opts.resolversOf(cwd, mod.ImportPath)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, r := range resolvers {
+			if found, err = r.resolve(mod, path, version); err != nil {
+				return nil, err
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			dir, err := pkgModPath(path, version)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't resolve module path for %q: %w", path, err)
+			}
+			miss := &vendoring.ModuleCacheMissError{Module: *mod.module(nil), Dir: dir}
+			if !offline {
+				return nil, miss
+			}
+			misses = append(misses, miss)
+		}
+	}
+
+	// Offline, what's missing can't be fetched, every module is told at once.
+	if len(misses) > 0 {
+		return nil, missingModules(misses)
+	}
+	return modules, nil
+}
+
+// readModulesTxt parses ./vendor/modules.txt of the project in cwd. Only the
+// module paths, versions, replacements as recorded and package lists are set.
+func readModulesTxt(cwd string) ([]*Mod, error) {
+	f, err := os.Open(filepath.Join(cwd, "vendor", "modules.txt"))
+	if err == nil {
+		defer func() {
+			_ = f.Close()
+		}()
+	}
+	var parsed []*modtxt.Module
+	if err == nil {
+		parsed, err = modtxt.Parse(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w - unable to read vendor/modules.txt", err)
+	}
+
+	modules := make([]*Mod, 0, len(parsed))
+	for _, m := range parsed {
+		modules = append(modules, &Mod{
+			ImportPath:    m.Path,
+			Version:       m.Version,
+			SourcePath:    m.Replace,
+			SourceVersion: m.ReplaceVersion,
+			VendoredPkgs:  m.Packages,
+		})
+	}
+	return modules, nil
+}
+
+func normString(str string) (normStr string) {
+	for _, char := range str {
+		if unicode.IsUpper(char) {
+			normStr += "!" + string(unicode.ToLower(char))
+		} else {
+			normStr += string(char)
+		}
+	}
+	return
+}
+
+// modCacheDir returns the module cache root, $GOMODCACHE or $GOPATH/pkg/mod.
+func modCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		return filepath.Join(build.Default.GOPATH, "pkg", "mod")
+	}
+	return filepath.Join(gopath[0], "pkg", "mod")
+}
+
+func pkgModPath(importPath, version string) (string, error) {
+	normPath := normString(importPath)
+	normVersion := normString(version)
+	return filepath.Join(modCacheDir(), fmt.Sprintf("%s@%s", normPath, normVersion)), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+// With -offline, modvendor doesn't use the network: modules are only read
+// from the module and download caches, never fetched from GOPROXY, and the go
+// command, hooks and plugins run with offlineEnv, which keeps the go command
+// from downloading modules or toolchains.
+var offline bool
+
+// offlineEnv are the variables added to the environment of commands run
+// offline.
+var offlineEnv = []string{"GOPROXY=off", "GOTOOLCHAIN=local", "MODVENDOR_OFFLINE=1"}
+
+// commandEnv returns the environment of the commands modvendor runs, with
+// vars added, nil for its own unless offline.
+func commandEnv(vars ...string) []string {
+	if !offline && len(vars) == 0 {
+		return nil
+	}
+	env := os.Environ()
+	if offline {
+		env = append(env, offlineEnv...)
+	}
+	return append(env, vars...)
+}
+
+// missingModules returns the error listing the modules of misses, as cmd/go
+// names them, which aren't in the caches although -offline requires them.
+func missingModules(misses []*vendoring.ModuleCacheMissError) error {
+	names := make([]string, len(misses))
+	for i, e := range misses {
+		switch m := e.Module; {
+		case m.Replace != "" && m.ReplaceVersion == "":
+			names[i] = fmt.Sprintf("%s@%s => %s", m.Path, m.Version, filepath.FromSlash(e.Dir))
+		case m.ReplaceVersion != "":
+			names[i] = m.Replace + "@" + m.ReplaceVersion
+		default:
+			names[i] = m.Path + "@" + m.Version
+		}
+	}
+	return failed("%d modules are missing from the module cache, nothing written; -offline doesn't download them, run `go mod download` where the network is available:\n\t%s", len(misses), strings.Join(names, "\n\t"))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/plan"
+	"github.com/ansoda/modvendor/vendoring"
+	"golang.org/x/sync/errgroup"
+)
+
+// buildVendorLists sets VendorList of every module to the files matching the
+// copy patterns within the module's packages. Modules are independent and
+// processed in parallel, those failing don't stop the others and are
+// reported together.
+func buildVendorLists(modules []*Mod, opts *vendorOptions) error {
+	copyPat := opts.patterns()
+	filters := opts.filters()
+	plan.SetJobs(opts.parallelism())
+
+	errs := make([]error, len(modules))
+	var g errgroup.Group
+	g.SetLimit(opts.parallelism())
+	for i, mod := range modules {
+		i, mod := i, mod
+		g.Go(func() error {
+			start := time.Now()
+			defer traceRegion("glob", mod.ImportPath).End()
+			key := walkKey(copyPat, opts, mod)
+			if list, ok := walks.get(key); ok {
+				mod.VendorList = list
+				return nil
+			}
+			if err := buildVendorList(copyPat, filters, mod); err != nil {
+				errs[i] = fmt.Errorf("%w - unable to match files of %s", err, mod)
+				return nil
+			}
+			if !interrupted() {
+				walks.put(key, mod.VendorList)
+			}
+			mod.Timing.Glob = time.Since(start)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if interrupted() {
+		return errInterrupted("nothing written")
+	}
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		if len(opts.plugins) == 0 {
+			return nil
+		}
+		err := runPlugins(opts.plugins, modules, opts.verbose)
+		if interrupted() {
+			return errInterrupted("nothing written")
+		}
+		return err
+	case 1:
+		return failed[0]
+	}
+	return failures(failed, "%d modules failed, nothing written", len(failed))
+}
+
+// buildVendorList builds the list of files to copy from mod. Files outside
+// of mod.Pkgs are filtered out as they are matched, the list never holds
+// more than what is vendored.
+func buildVendorList(copyPat []string, filters []vendoring.Filter, mod *Mod) error {
+	// Most modules have no file the patterns could match, the listing of
+	// their zip in the download cache tells without walking them.
+	if mod.Zip == "" && !zipMayMatch(mod, plan.Suffixes(copyPat)) {
+		mod.VendorList = map[string]*vendorMatch{}
+		return nil
+	}
+
+	// Modules only available as a zip in the download cache are matched
+	// as if the zip was extracted to mod.Dir.
+	fsys, closer, err := openModFS(mod)
+	if err != nil {
+		return fmt.Errorf("unable to read module zip: %w", err)
+	}
+	mod.VendorList, err = buildModVendorList(copyPat, filters, mod, fsys)
+	if closer != nil {
+		_ = closer.Close()
+	}
+	return err
+}
+
+// vendorMatch records why a file is vendored.
+type vendorMatch struct {
+	Patterns []string // copy patterns matching the file, "" matches everything
+	Pkg      string   // package, or -include directory, the file belongs to
+
+	// Entry is the file as found walking the module, nil if unknown. It
+	// spares statting the file again, unless it is a symlink.
+	Entry fs.DirEntry
+
+	// Dest is where a plugin rewrote the file to, see runPlugins.
+	Dest string
+}
+
+// vendorEntry is a single file to copy into ./vendor/.
+type vendorEntry struct {
+	Mod   *Mod
+	Src   string // file within mod.Dir
+	Dest  string // slash separated path relative to ./vendor/
+	Match *vendorMatch
+	Sum   string // hex sha256 of the content, set once copied
+
+	info fs.FileInfo // of the source, see stat
+
+	// Unchanged is set when the vendored file has the size and modification
+	// time of the source already, and isn't copied again.
+	Unchanged bool
+
+	// Copied is when the vendored file was copied, by an earlier run if
+	// Unchanged, zero if copied by this one.
+	Copied time.Time
+
+	// Delta is set when the vendored file is updated in place, see
+	// markDelta, rather than copied again.
+	Delta bool
+
+	// Err is why the file failed to copy, with -keep-going.
+	Err error
+}
+
+// stat returns the FileInfo of the source of e, following symlinks. It is
+// statted once at most, and not at all when the walk found it.
+func (e *vendorEntry) stat() (fs.FileInfo, error) {
+	if e.info != nil {
+		return e.info, nil
+	}
+	var err error
+	if d := e.walked(); d != nil {
+		e.info, err = d.Info()
+	} else {
+		e.info, err = os.Stat(e.Src)
+	}
+	return e.info, err
+}
+
+// resolved returns the path of the source of e with symlinks resolved.
+// Symlinks were checked to stay within the module while planning.
+func (e *vendorEntry) resolved() (string, error) {
+	if e.walked() != nil {
+		return e.Src, nil
+	}
+	return filepath.EvalSymlinks(e.Src)
+}
+
+// name returns the slash separated name of the source of e within the
+// filesystem of its module.
+func (e *vendorEntry) name() string {
+	return filepath.ToSlash(e.Src[len(e.Mod.Dir)+1:])
+}
+
+// walked returns the entry the walk found the source of e with, unless it is
+// a symlink or unknown.
+func (e *vendorEntry) walked() fs.DirEntry {
+	if e.Match == nil || e.Match.Entry == nil || e.Match.Entry.Type()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	return e.Match.Entry
+}
+
+// planCopy builds the vendor lists of modules and lists the files to copy,
+// see planVendorEntries.
+func planCopy(modules []*Mod, opts *vendorOptions) ([]*vendorEntry, error) {
+	if err := buildVendorLists(modules, opts); err != nil {
+		return nil, err
+	}
+	return planVendorEntries(modules)
+}
+
+// planVendorEntries lists the files to copy for all modules sorted by
+// destination. Modules are free to overlap (a fork replace next to the
+// original, nested modules), but two of them writing the same destination
+// is an error, all such collisions are reported at once.
+func planVendorEntries(modules []*Mod) ([]*vendorEntry, error) {
+	defer traceRegion("plan", "").End()
+	var entries []*vendorEntry
+	byDest := map[string]*vendorEntry{}
+	var collisions []string
+
+	for _, mod := range modules {
+		for vendorFile, m := range mod.VendorList {
+			if !strings.HasPrefix(vendorFile, mod.Dir) {
+				return nil, fmt.Errorf("vendor file %s doesn't belong to %s, strange", vendorFile, mod.ImportPath)
+			}
+			e := &vendorEntry{
+				Mod:   mod,
+				Src:   vendorFile,
+				Dest:  m.dest(mod, vendorFile),
+				Match: m,
+			}
+			if prev, ok := byDest[e.Dest]; ok {
+				collisions = append(collisions, fmt.Sprintf("%s would be written by more than one module:\n\t%s from %s\n\t%s from %s",
+					outName(e.Dest), prev.Mod, prev.Src, e.Mod, e.Src))
+				continue
+			}
+			byDest[e.Dest] = e
+			entries = append(entries, e)
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return nil, fmt.Errorf("%s", strings.Join(collisions, "\n"))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Dest < entries[j].Dest
+	})
+	return entries, nil
+}
+
+func buildModVendorList(copyPat []string, filters []vendoring.Filter, mod *Mod, fsys fs.FS) (map[string]*vendorMatch, error) {
+	files, err := vendoring.Match(runCtx, mod.module(fsys), copyPat, filters...)
+	if err != nil && interrupted() {
+		// Reported once every module stopped, see buildVendorLists.
+		return map[string]*vendorMatch{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("glob match failure: %w", err)
+	}
+	vendorList := make(map[string]*vendorMatch, len(files))
+	for _, f := range files {
+		vendorList[filepath.Join(mod.Dir, filepath.FromSlash(f.Name))] = &vendorMatch{Patterns: f.Patterns, Pkg: f.Package, Entry: f.Entry}
+	}
+	return vendorList, nil
+}
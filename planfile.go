@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+// PlanFile is what copy would vendor, written by copy -write-plan and copied
+// as is by modvendor apply, possibly on another machine with the same
+// modules in its module cache.
+type PlanFile struct {
+	Patterns []string      `json:"patterns"`
+	Modules  []*PlanModule `json:"modules"`
+}
+
+type PlanModule struct {
+	Path           string      `json:"path"`
+	Version        string      `json:"version"`
+	Replace        string      `json:"replace,omitempty"`
+	ReplaceVersion string      `json:"replaceVersion,omitempty"`
+	Files          []*PlanItem `json:"files"`
+}
+
+type PlanItem struct {
+	Name     string   `json:"name"`   // slash separated, relative to the module root
+	Dest     string   `json:"dest"`   // relative to the output directory
+	Sum      string   `json:"sha256"` // prefixed with the algorithm unless sha256
+	Size     int64    `json:"size"`
+	Patterns []string `json:"patterns,omitempty"`
+	Package  string   `json:"package,omitempty"`
+}
+
+// newPlanFile describes the entries grouped by module, their sources hashed.
+func newPlanFile(patterns []string, modules []*Mod, entries []*vendorEntry) (*PlanFile, error) {
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	byMod := map[*Mod]*PlanModule{}
+	for _, e := range entries {
+		pm, ok := byMod[e.Mod]
+		if !ok {
+			pm = &PlanModule{Path: e.Mod.ImportPath, Version: e.Mod.Version, Replace: e.Mod.SourcePath, ReplaceVersion: e.Mod.SourceVersion}
+			byMod[e.Mod] = pm
+		}
+		info, err := sources.stat(e)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := sourceSum(sources, e)
+		if err != nil {
+			return nil, err
+		}
+		item := &PlanItem{Name: e.name(), Dest: e.Dest, Sum: sum, Size: info.Size()}
+		if e.Match != nil {
+			item.Patterns, item.Package = e.Match.Patterns, e.Match.Pkg
+		}
+		pm.Files = append(pm.Files, item)
+	}
+	saveHashCache()
+
+	p := &PlanFile{Patterns: patterns, Modules: []*PlanModule{}}
+	for _, mod := range modules {
+		if pm, ok := byMod[mod]; ok {
+			p.Modules = append(p.Modules, pm)
+		}
+	}
+	return p, nil
+}
+
+func writePlanFile(path string, p *PlanFile) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func readPlanFile(path string) (*PlanFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p PlanFile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// apply sets the vendor lists of modules to the files of the plan. Every
+// planned module must be vendored at the version it was planned for, the
+// others vendor nothing.
+func (p *PlanFile) apply(modules []*Mod) error {
+	byPath := make(map[string]*Mod, len(modules))
+	for _, mod := range modules {
+		byPath[mod.ImportPath] = mod
+		mod.VendorList = map[string]*vendorMatch{}
+	}
+	for _, pm := range p.Modules {
+		mod, ok := byPath[pm.Path]
+		if !ok {
+			return fmt.Errorf("%s is planned but isn't in vendor/modules.txt", pm.Path)
+		}
+		if pm.Version != mod.Version || pm.Replace != mod.SourcePath || pm.ReplaceVersion != mod.SourceVersion {
+			return fmt.Errorf("the plan is for %s, vendor/modules.txt has %s", planModuleString(pm), mod)
+		}
+		for _, f := range pm.Files {
+			if !validPlanName(f.Name) {
+				return fmt.Errorf("invalid file %q of %s in the plan", f.Name, pm.Path)
+			}
+			if a := checksum.Of(f.Sum); a != sumAlgorithm {
+				return fmt.Errorf("the plan has %s checksums, %s are configured", a, sumAlgorithm)
+			}
+			// Plugins may have rewritten the destination, within the module.
+			m := &vendorMatch{Patterns: f.Patterns, Pkg: f.Package}
+			if f.Dest != pm.Path+"/"+f.Name {
+				if !withinModule(mod, f.Dest) {
+					return fmt.Errorf("invalid destination %q of %s in the plan", f.Dest, pm.Path)
+				}
+				m.Dest = f.Dest
+			}
+			mod.VendorList[filepath.Join(mod.Dir, filepath.FromSlash(f.Name))] = m
+		}
+	}
+	return nil
+}
+
+// check fails unless the entries copied have the sums and destinations of
+// the plan.
+func (p *PlanFile) check(entries []*vendorEntry) error {
+	planned := map[string]*PlanItem{}
+	for _, pm := range p.Modules {
+		for _, f := range pm.Files {
+			planned[pm.Path+"/"+f.Name] = f
+		}
+	}
+	for _, e := range entries {
+		f := planned[e.Mod.ImportPath+"/"+e.name()]
+		switch {
+		case f == nil:
+			return fmt.Errorf("%s isn't planned", e.Src)
+		case f.Dest != e.Dest:
+			return fmt.Errorf("%s is planned to %s, it would be written to %s", e.Src, f.Dest, e.Dest)
+		case f.Sum != e.Sum:
+			return fmt.Errorf("%s changed since the plan was made", e.Src)
+		}
+	}
+	return nil
+}
+
+func planModuleString(pm *PlanModule) string {
+	mm := ManifestModule{Path: pm.Path, Version: pm.Version, Replace: pm.Replace, ReplaceVersion: pm.ReplaceVersion}
+	return mm.String()
+}
+
+// validPlanName reports whether name stays within its module, plans may
+// come from elsewhere.
+func validPlanName(name string) bool {
+	return name != "." && fs.ValidPath(name)
+}
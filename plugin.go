@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Plugins are commands deciding, file by file, what modvendor vendors. Each
+// is started once per run, in the project root, and gets a pluginRecord per
+// line of its stdin; it answers every one with a pluginDecision per line of
+// its stdout, in order, before getting the next. Files a plugin drops aren't
+// passed to the plugins after it.
+
+// pluginRecord is a file matched by the copy patterns.
+type pluginRecord struct {
+	Module   string   `json:"module"`
+	Version  string   `json:"version"`
+	Name     string   `json:"name"`          // slash separated, relative to the module root
+	Dest     string   `json:"dest"`          // slash separated, relative to the output directory
+	Src      string   `json:"src,omitempty"` // unless read from a module zip
+	Size     int64    `json:"size"`
+	Patterns []string `json:"patterns,omitempty"`
+	Package  string   `json:"package,omitempty"`
+}
+
+// pluginDecision is what a plugin decides about a file.
+type pluginDecision struct {
+	Action string `json:"action"`           // keep, drop or rewrite
+	Dest   string `json:"dest,omitempty"`   // of rewrite, within the directory of the module
+	Reason string `json:"reason,omitempty"` // of drop, printed with -v
+}
+
+// plugin is a running plugin.
+type plugin struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	enc     *json.Encoder
+	dec     *json.Decoder
+}
+
+func startPlugin(command string) (*plugin, error) {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	cmd.Stderr = os.Stderr
+	cmd.Env = commandEnv()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &plugin{command: command, cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin), dec: json.NewDecoder(bufio.NewReader(stdout))}, nil
+}
+
+// decide sends r to the plugin and returns its decision.
+func (p *plugin) decide(r *pluginRecord) (*pluginDecision, error) {
+	if err := p.enc.Encode(r); err != nil {
+		return nil, err
+	}
+	var d pluginDecision
+	if err := p.dec.Decode(&d); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("%w - no decision about %s", err, r.Dest)
+	}
+	return &d, nil
+}
+
+// close ends the input of the plugin and waits for it to exit.
+func (p *plugin) close() error {
+	_ = p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// runPlugins passes the vendor lists of modules through the plugins, in
+// order, dropping the files they drop and setting the destination of those
+// they rewrite.
+func runPlugins(commands []string, modules []*Mod, verbose bool) error {
+	for _, command := range commands {
+		p, err := startPlugin(command)
+		if err != nil {
+			return fmt.Errorf("%w - unable to start plugin %q", err, command)
+		}
+		err = p.run(modules, verbose)
+		if cerr := p.close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("%w - plugin %q failed", err, command)
+		}
+	}
+	return nil
+}
+
+func (p *plugin) run(modules []*Mod, verbose bool) error {
+	sources := newSourceFiles()
+	defer sources.Close()
+	for _, mod := range modules {
+		files := make([]string, 0, len(mod.VendorList))
+		for file := range mod.VendorList {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if interrupted() {
+				return runCtx.Err()
+			}
+			m := mod.VendorList[file]
+			e := &vendorEntry{Mod: mod, Src: file, Dest: m.dest(mod, file), Match: m}
+			info, err := sources.stat(e)
+			if err != nil {
+				return err
+			}
+			r := &pluginRecord{
+				Module:   mod.ImportPath,
+				Version:  mod.Version,
+				Name:     e.name(),
+				Dest:     e.Dest,
+				Size:     info.Size(),
+				Patterns: m.Patterns,
+				Package:  m.Pkg,
+			}
+			if mod.Zip == "" {
+				r.Src = file
+			}
+			d, err := p.decide(r)
+			if err != nil {
+				return err
+			}
+			switch d.Action {
+			case "keep":
+			case "drop":
+				if verbose {
+					fmt.Printf("plugin %s drops %s%s\n", p.command, outName(e.Dest), reasonSuffix(d.Reason))
+				}
+				delete(mod.VendorList, file)
+			case "rewrite":
+				if !withinModule(mod, d.Dest) {
+					return fmt.Errorf("%s rewritten to %s, outside of %s/", e.Dest, d.Dest, mod.ImportPath)
+				}
+				m.Dest = d.Dest
+			default:
+				return fmt.Errorf("unknown action %q for %s, use keep, drop or rewrite", d.Action, e.Dest)
+			}
+		}
+	}
+	return nil
+}
+
+func reasonSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return ": " + reason
+}
+
+// dest returns the destination of the file of mod, that of its module path
+// unless rewritten.
+func (m *vendorMatch) dest(mod *Mod, file string) string {
+	if m != nil && m.Dest != "" {
+		return m.Dest
+	}
+	return filepath.ToSlash(mod.ImportPath + file[len(mod.Dir):])
+}
+
+// withinModule reports whether dest is a file within the directory of mod in
+// the output directory.
+func withinModule(mod *Mod, dest string) bool {
+	return fs.ValidPath(dest) && strings.HasPrefix(dest, mod.ImportPath+"/")
+}
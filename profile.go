@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+var (
+	cpuProfile *os.File
+	memProfile string
+	traceFile  *os.File
+)
+
+// registerProfiles adds -cpuprofile and -trace, starting the CPU profile or
+// trace as soon as they are parsed, and -memprofile.
+func registerProfiles(flags *flag.FlagSet) {
+	flags.Func("cpuprofile", "write a CPU profile of the run to the given file", func(path string) error {
+		if cpuProfile != nil {
+			return fmt.Errorf("given more than once")
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		cpuProfile = f
+		return nil
+	})
+	flags.Func("trace", "write a runtime trace of the run to the given file, for go tool trace", func(path string) error {
+		if traceFile != nil {
+			return fmt.Errorf("given more than once")
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		traceFile = f
+		return nil
+	})
+	flags.StringVar(&memProfile, "memprofile", "", "write a heap profile of the run to the given file")
+}
+
+// writeProfiles stops the CPU profile and trace and writes the heap
+// profile, once the command returned.
+func writeProfiles() {
+	if traceFile != nil {
+		trace.Stop()
+		if err := traceFile.Close(); err != nil {
+			fmt.Printf("Error! %s - unable to write trace\n", err.Error())
+		}
+	}
+	if cpuProfile != nil {
+		pprof.StopCPUProfile()
+		if err := cpuProfile.Close(); err != nil {
+			fmt.Printf("Error! %s - unable to write CPU profile\n", err.Error())
+		}
+	}
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err == nil {
+			runtime.GC()
+			err = pprof.WriteHeapProfile(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error! %s - unable to write heap profile\n", err.Error())
+		}
+	}
+}
+
+// traceRegion starts a region of the trace of the given type, logging the
+// module path, if any. Its End method must be called once done.
+func traceRegion(regionType, path string) *trace.Region {
+	ctx := context.Background()
+	r := trace.StartRegion(ctx, regionType)
+	if path != "" {
+		trace.Log(ctx, "module", path)
+	}
+	return r
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/copyer"
+)
+
+// progressSize is the size from which copies report their progress and
+// resume where an interrupted run left off, set by -progress-size.
+var progressSize byteSize = 64 << 20
+
+// partialName is the directory, within the staging directory, partial copies
+// of large files survive failed runs in.
+const partialName = ".partial"
+
+// progressInterval is how often the progress of a large copy is reported.
+const progressInterval = 2 * time.Second
+
+// progress reports the bytes written to it for the file name.
+type progress struct {
+	Name  string
+	Total int64
+	Done  int64
+	last  time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.Done += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		fmt.Printf("copying %s: %s of %s (%d%%)\n", p.Name, formatSize(p.Done), formatSize(p.Total), p.Done*100/p.Total)
+	}
+	return len(b), nil
+}
+
+// partialPath returns where the partial copy of the source identified by
+// key is kept.
+func partialPath(staging, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(staging, partialName, hex.EncodeToString(sum[:12]))
+}
+
+// clearStaging empties the staging directory, but for partial copies.
+func clearStaging(staging string) error {
+	entries, err := os.ReadDir(staging)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, d := range entries {
+		if d.Name() == partialName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(staging, d.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyResumable is copyer.Regular for large files, reporting progress as name.
+// The copy is made at partial first and continued from there by later
+// runs should this one not get to finish it.
+func copyResumable(src, dst, partial, name string, info fs.FileInfo, tee io.Writer) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	if err := os.MkdirAll(filepath.Dir(partial), os.ModePerm); err != nil {
+		return err
+	}
+	perm := info.Mode().Perm() | 0644
+	copyer.Ops.Wait(1)
+	w, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = w.Close()
+	}()
+
+	done, err := w.Seek(0, io.SeekEnd)
+	if err == nil && done > info.Size() {
+		done, err = 0, w.Truncate(0)
+	}
+	if err == nil && done > 0 {
+		// What the earlier run copied is part of the checksum as well.
+		fmt.Printf("resuming %s at %s of %s\n", name, formatSize(done), formatSize(info.Size()))
+		if _, err = w.Seek(0, io.SeekStart); err == nil {
+			_, err = copyer.Buffered(tee, io.LimitReader(w, done))
+		}
+		if err == nil {
+			_, err = r.Seek(done, io.SeekStart)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	p := &progress{Name: name, Total: info.Size(), Done: done, last: time.Now()}
+	if _, err := copyer.Buffered(io.MultiWriter(w, p), io.TeeReader(copyer.Interruptible(runCtx, copyer.Throttled(r)), tee)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(partial, perm); err != nil {
+		return err
+	}
+	if err := os.Chtimes(partial, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(partial, dst)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// goEnv returns the value of a go environment variable, consulting
+// `go env` once for values that are only set in the go env config file.
+func goEnv(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	goEnvMu.Lock()
+	defer goEnvMu.Unlock()
+	if v, ok := goEnvValues[key]; ok {
+		return v
+	}
+	cmd := exec.Command("go", "env", key)
+	cmd.Env = commandEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	goEnvValues[key] = strings.TrimSpace(string(out))
+	return goEnvValues[key]
+}
+
+var (
+	goEnvMu     sync.Mutex
+	goEnvValues = map[string]string{}
+)
+
+func noProxy(importPath string) bool {
+	nop := goEnv("GONOPROXY")
+	if nop == "" {
+		nop = goEnv("GOPRIVATE")
+	}
+	return plan.MatchPrefixPatterns(nop, importPath)
+}
+
+func noSumDB(importPath string) bool {
+	return goEnv("GOSUMDB") == "off" || privateModule(importPath)
+}
+
+// privateModule reports whether the module is private to the go command:
+// matching GONOSUMDB, which defaults to GOPRIVATE. The checksum database
+// doesn't know it, go.sum is all there is to verify it against.
+func privateModule(importPath string) bool {
+	nos := goEnv("GONOSUMDB")
+	if nos == "" {
+		nos = goEnv("GOPRIVATE")
+	}
+	return plan.MatchPrefixPatterns(nos, importPath)
+}
+
+// privateNote returns what reports add to private modules, see
+// privateModule.
+func privateNote(importPath string) string {
+	if privateModule(importPath) {
+		return " (private)"
+	}
+	return ""
+}
+
+var errProxyNotFound = errors.New("not found")
+
+// fetchModZip downloads the module zip from GOPROXY into the download cache
+// and returns its path. The zip is verified against go.sum before it is moved
+// into place, modules without a go.sum entry are only accepted when checksum
+// database verification is disabled for them.
+func fetchModZip(importPath, version string, sums map[string]string) (string, error) {
+	key := importPath + "@" + version
+	if offline {
+		return "", fmt.Errorf("%s is missing from the module cache, -offline doesn't fetch it", key)
+	}
+	if noProxy(importPath) {
+		return "", fmt.Errorf("%s matches GONOPROXY/GOPRIVATE, refusing to fetch it from GOPROXY", key)
+	}
+	want, ok := sums[key]
+	if !ok && !noSumDB(importPath) {
+		return "", fmt.Errorf("missing go.sum entry for %s, cannot verify it; for private modules set GOPRIVATE or GONOSUMDB", key)
+	}
+
+	proxies := goEnv("GOPROXY")
+	if proxies == "" {
+		proxies = "https://proxy.golang.org,direct"
+	}
+
+	zipPath := modZipPath(importPath, version)
+	if err := os.MkdirAll(filepath.Dir(zipPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(zipPath), filepath.Base(zipPath)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	// Proxies separated by "," are tried on "not found" only, by "|" on any
+	// error, the same way the go command does.
+	var lastErr error
+	for proxies != "" {
+		var proxy string
+		fallThrough := false
+		if i := strings.IndexAny(proxies, ",|"); i >= 0 {
+			proxy, fallThrough, proxies = proxies[:i], proxies[i] == '|', proxies[i+1:]
+		} else {
+			proxy, proxies = proxies, ""
+		}
+
+		switch proxy {
+		case "off":
+			return "", fmt.Errorf("module lookup disabled by GOPROXY=off, cannot fetch %s", key)
+		case "direct":
+			lastErr = fmt.Errorf("direct fetching from version control is not supported, cannot fetch %s", key)
+			continue
+		}
+
+		if err := tmp.Truncate(0); err != nil {
+			return "", err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		lastErr = downloadProxyZip(strings.TrimSuffix(proxy, "/"), importPath, version, tmp)
+		if lastErr == nil || (!fallThrough && !errors.Is(lastErr, errProxyNotFound)) {
+			break
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("unable to fetch %s: %v", key, lastErr)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	got, err := hashZip(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		warn("go.sum", "%s has no go.sum entry, its content is not verified", key)
+	} else if got != want {
+		return "", fmt.Errorf("checksum mismatch for %s\n\tdownloaded: %s\n\tgo.sum:     %s", key, got, want)
+	}
+
+	if err := os.WriteFile(strings.TrimSuffix(zipPath, ".zip")+".ziphash", []byte(got), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), zipPath); err != nil {
+		return "", err
+	}
+	return zipPath, nil
+}
+
+// proxyClient downloads module zips, a proxy stalling fails the fetch in time
+// rather than hanging the run.
+var proxyClient = &http.Client{Timeout: 5 * time.Minute}
+
+func downloadProxyZip(proxy, importPath, version string, w io.Writer) error {
+	url := fmt.Sprintf("%s/%s/@v/%s.zip", proxy, normString(importPath), normString(version))
+	req, err := http.NewRequestWithContext(runCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%s: %w", url, errProxyNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
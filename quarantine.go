@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// quarantineReport is the file, within the quarantine directory, listing the
+// files quarantined and why.
+const quarantineReport = "report.json"
+
+// quarantine excuses the files failing the policy checks of copy, -safe,
+// licenses:, -secrets fail, -max-size and -scan, from failing the run: those
+// allow-files: lists are vendored nonetheless, with -quarantine the others
+// are left out of the output directory and written to the quarantine
+// directory instead, for review.
+type quarantine struct {
+	dir   string // the quarantine directory, "" without -quarantine
+	allow []plan.Matcher
+	files []*quarantined
+	held  map[*vendorEntry]bool
+}
+
+// quarantined is a file of the report, as it would have been vendored.
+type quarantined struct {
+	File   string `json:"file"` // slash separated, relative to the output directory
+	Module string `json:"module"`
+	Source string `json:"source"`
+	Check  string `json:"check"` // denied, license, secret, size or scan
+	Reason string `json:"reason"`
+
+	entry *vendorEntry
+}
+
+type quarantineFile struct {
+	Generated time.Time      `json:"generated"`
+	Files     []*quarantined `json:"files"`
+}
+
+// newQuarantine returns the quarantine of dir, relative to the project in
+// cwd unless absolute, and the allow-files: patterns.
+func newQuarantine(cwd, dir string, allow []string) (*quarantine, error) {
+	matchers, err := plan.Compile(allow)
+	if err != nil {
+		return nil, fmt.Errorf("%w - invalid allow-files: pattern in %s", err, configName)
+	}
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(cwd, dir)
+	}
+	// Each run replaces the quarantine of the previous one, nothing else.
+	if entries, err := os.ReadDir(dir); dir != "" && err == nil && len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, quarantineReport)); err != nil {
+			return nil, whoops("-quarantine %s holds files and no %s, give another directory", dir, quarantineReport)
+		}
+	}
+	return &quarantine{dir: dir, allow: matchers, held: map[*vendorEntry]bool{}}, nil
+}
+
+// excuse reports whether the check failing on e needn't fail the run:
+// allow-files: lists it, or it is quarantined.
+func (q *quarantine) excuse(e *vendorEntry, check, reason string) bool {
+	if q == nil {
+		return false
+	}
+	for _, m := range q.allow {
+		if m != nil && m.Match(e.Dest) {
+			return true
+		}
+	}
+	if q.dir == "" {
+		return false
+	}
+	if !q.held[e] {
+		q.held[e] = true
+		q.files = append(q.files, &quarantined{File: e.Dest, Module: e.Mod.String(), Source: e.Src, Check: check, Reason: reason, entry: e})
+	}
+	warn(outName(e.Dest), "quarantined %s of %s: %s", outName(e.Dest), e.Mod, reason)
+	return true
+}
+
+// active reports whether q may excuse files at all.
+func (q *quarantine) active() bool {
+	return q.dir != "" || len(q.allow) > 0
+}
+
+// filter returns the entries not quarantined.
+func (q *quarantine) filter(entries []*vendorEntry) []*vendorEntry {
+	if q == nil || len(q.held) == 0 {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, e := range entries {
+		if !q.held[e] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// write replaces what the quarantine directory held with the files
+// quarantined by this run, read from their modules, and their report.
+func (q *quarantine) write() error {
+	if q == nil || q.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(q.dir); err != nil {
+		return err
+	}
+	if len(q.files) == 0 {
+		return nil
+	}
+
+	sources := newSourceFiles()
+	defer sources.Close()
+	for _, f := range q.files {
+		if err := quarantineEntry(sources, filepath.Join(q.dir, filepath.FromSlash(f.File)), f.entry); err != nil {
+			return err
+		}
+	}
+	sort.Slice(q.files, func(i, j int) bool {
+		return q.files[i].File < q.files[j].File
+	})
+	data, err := json.MarshalIndent(&quarantineFile{Generated: time.Now().UTC().Truncate(time.Second), Files: q.files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(q.dir, quarantineReport), append(data, '\n'), 0644)
+}
+
+// quarantineEntry copies the source of e to dst.
+func quarantineEntry(sources *sourceFiles, dst string, e *vendorEntry) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	r, err := sources.open(e)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	info, err := sources.stat(e)
+	if err != nil {
+		return err
+	}
+	return extractFile(r, info, dst, io.Discard)
+}
+
+// relDir returns the quarantine directory relative to the project in cwd,
+// for messages.
+func (q *quarantine) relDir(cwd string) string {
+	if rel, err := filepath.Rel(cwd, q.dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return q.dir
+}
+
+// checkSizes quarantines the files of entries larger than max, which
+// -max-size leaves out on its own without -quarantine.
+func checkSizes(max byteSize, entries []*vendorEntry, q *quarantine) error {
+	sources := newSourceFiles()
+	defer sources.Close()
+	for _, e := range entries {
+		info, err := sources.stat(e)
+		if err != nil {
+			return fmt.Errorf("%w - unable to read %s", err, e.Src)
+		}
+		if info.Size() > int64(max) {
+			q.excuse(e, "size", fmt.Sprintf("it is larger than -max-size %s (%d bytes)", formatSize(int64(max)), info.Size()))
+		}
+	}
+	return nil
+}
+
+// scanEach runs the scanner over each of the staged files of entries on its
+// own, in a directory of the output directory linking it, and returns those
+// it fails on, after it failed over all of them.
+func scanEach(cwd, staging, command string, patterns []string, entries []*vendorEntry) ([]*vendorEntry, error) {
+	dir := outPath(cwd, scanName)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	var failing []*vendorEntry
+	for _, e := range entries {
+		if e.Unchanged || e.Delta {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		staged := filepath.Join(staging, filepath.FromSlash(e.Dest))
+		dst := filepath.Join(dir, filepath.FromSlash(e.Dest))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Link(staged, dst); err != nil {
+			if _, err := copyFile(staged, dst); err != nil {
+				return nil, err
+			}
+		}
+		if err := runScanner(cwd, dir, command, patterns, []*vendorEntry{e}); err != nil {
+			failing = append(failing, e)
+		}
+	}
+	return failing, nil
+}
+
+// scanName is the directory, within the output directory, scanEach scans
+// files in.
+const scanName = ".modvendor-scan"
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// outputFormat is how warnings and problems are printed, set by -output.
+var outputFormat = outputFlag("text")
+
+type outputFlag string
+
+func (o *outputFlag) String() string { return string(*o) }
+
+func (o *outputFlag) Set(v string) error {
+	switch v {
+	case "text", "github":
+		*o = outputFlag(v)
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, use text or github", v)
+}
+
+func registerOutput(flags *flag.FlagSet) {
+	flags.Var(&outputFormat, "output", "print warnings and problems as text, or as github annotations for GitHub Actions")
+}
+
+// warn prints a warning, file is the project file it is about, if any.
+func warn(file, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if outputFormat == "github" {
+		fmt.Printf("::warning%s::%s\n", annotationFile(file), annotationEscape(msg))
+		return
+	}
+	fmt.Printf("Warning! %s\n", msg)
+}
+
+// cliLogger prints the warnings of the library as those of modvendor, its
+// progress is left to -v.
+type cliLogger struct{}
+
+func (cliLogger) Debug(string, ...interface{}) {}
+
+func (cliLogger) Warn(msg string, args ...interface{}) {
+	warn("", "%s", msg)
+}
+
+// problem prints a failed check about file, which makes the command exit
+// non-zero. Text output is msg as is.
+func problem(file, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if outputFormat == "github" {
+		fmt.Printf("::error%s::%s\n", annotationFile(file), annotationEscape(msg))
+		return
+	}
+	fmt.Println(msg)
+}
+
+func annotationFile(file string) string {
+	if file == "" {
+		return ""
+	}
+	return " file=" + strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(file)
+}
+
+func annotationEscape(msg string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(msg)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With -reproducible, copy writes output directories that are byte for byte
+// the same on every machine given the same modules: the files get normalized
+// modes and modification times, and the manifest records no time of the run
+// nor paths of the machine.
+
+// modCacheVar starts the directories of the manifest within the module cache
+// once made portable, see portable.
+const modCacheVar = "$GOMODCACHE"
+
+// reproducibleTime returns the modification time of the files -reproducible
+// normalizes, $SOURCE_DATE_EPOCH if set, or else that of packed files.
+func reproducibleTime() (time.Time, error) {
+	s := os.Getenv("SOURCE_DATE_EPOCH")
+	if s == "" {
+		return packTime, nil
+	}
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, whoops("invalid SOURCE_DATE_EPOCH %q, want seconds since the Unix epoch", s)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// normalizeFiles gives the files, relative to the output directory, and the
+// directories they are in, up to the output directory, the modes of
+// normalizeModes and modTime.
+func normalizeFiles(cwd string, files []string, modTime time.Time) error {
+	if err := normalizeModes(cwd, files); err != nil {
+		return err
+	}
+	for _, name := range append(files, outputDirs(files)...) {
+		if err := os.Chtimes(outPath(cwd, name), modTime, modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// portable returns the manifest directory dir as recorded by -reproducible:
+// slash separated, within the module cache prefixed with modCacheVar, and
+// otherwise relative to the project root in cwd when possible.
+func portable(cwd, dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if rel, err := filepath.Rel(modCacheDir(), dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return modCacheVar + "/" + filepath.ToSlash(rel)
+	}
+	if rel, err := filepath.Rel(cwd, dir); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return dir
+}
+
+// unportable returns the directory of the machine that portable recorded as
+// dir.
+func unportable(cwd, dir string) string {
+	switch {
+	case dir == "", filepath.IsAbs(dir):
+		return dir
+	case dir == modCacheVar || strings.HasPrefix(dir, modCacheVar+"/"):
+		return filepath.Join(modCacheDir(), filepath.FromSlash(strings.TrimPrefix(dir, modCacheVar)))
+	}
+	return filepath.Join(cwd, filepath.FromSlash(dir))
+}
+
+// makeReproducible records m as -reproducible does: generated at modTime,
+// without the times files were copied, and with portable directories.
+func (m *Manifest) makeReproducible(cwd string, modTime time.Time) {
+	m.Generated = modTime
+	for _, mm := range m.Modules {
+		mm.Dir, mm.Zip = portable(cwd, mm.Dir), portable(cwd, mm.Zip)
+		for i := range mm.Files {
+			mm.Files[i].Copied = nil
+		}
+	}
+}
+
+// resolveDirs turns the directories of m recorded by -reproducible into those
+// of the machine, the project being in cwd.
+func (m *Manifest) resolveDirs(cwd string) {
+	for _, mm := range m.Modules {
+		mm.Dir, mm.Zip = unportable(cwd, mm.Dir), unportable(cwd, mm.Zip)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// resolver is one place the files of modules come from. resolve sets
+// mod.Dir, and mod.Zip when the files are read from a module zip, to the
+// files of path@version, the module or its replacement. It reports false,
+// leaving mod as it was, when it doesn't have them.
+type resolver interface {
+	resolve(mod *Mod, path, version string) (bool, error)
+}
+
+// cacheResolver finds modules extracted to the module cache.
+type cacheResolver struct{}
+
+func (cacheResolver) resolve(mod *Mod, path, version string) (bool, error) {
+	dir, err := pkgModPath(path, version)
+	if err != nil {
+		return false, fmt.Errorf("couldn't resolve module path for %q: %w", path, err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return false, nil
+	}
+	mod.Dir = dir
+	return true, nil
+}
+
+// zipResolver finds modules only downloaded to the module cache, their files
+// are read from the zip as if it was extracted.
+type zipResolver struct{}
+
+func (zipResolver) resolve(mod *Mod, path, version string) (bool, error) {
+	zipPath := modZipPath(path, version)
+	if _, err := os.Stat(zipPath); err != nil {
+		return false, nil
+	}
+	return true, setZip(mod, path, version, zipPath)
+}
+
+func setZip(mod *Mod, path, version, zipPath string) error {
+	dir, err := pkgModPath(path, version)
+	if err != nil {
+		return fmt.Errorf("couldn't resolve module path for %q: %w", path, err)
+	}
+	mod.Dir, mod.Zip = dir, zipPath
+	return nil
+}
+
+// proxyResolver downloads the zip of modules from GOPROXY to the module
+// cache, checked against go.sum of the project in cwd.
+type proxyResolver struct {
+	cwd     string
+	verbose bool
+}
+
+func (r proxyResolver) resolve(mod *Mod, path, version string) (bool, error) {
+	if r.verbose {
+		fmt.Printf("fetching %s@%s\n", path, version)
+	}
+	sums, err := loadGoSum(r.cwd)
+	if err != nil {
+		return false, err
+	}
+	zipPath, err := fetchModZip(path, version, sums)
+	if err != nil {
+		return false, err
+	}
+	return true, setZip(mod, path, version, zipPath)
+}
+
+// localResolver reads a module from a checkout, as a local replace does.
+type localResolver struct {
+	dir string // relative to the project root unless absolute
+}
+
+func (r localResolver) resolve(mod *Mod, path, version string) (bool, error) {
+	dir, err := filepath.Abs(r.dir)
+	if err != nil {
+		return false, fmt.Errorf("invalid relative path: %w", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return false, nil
+	}
+	mod.SourcePath, mod.SourceVersion = r.dir, ""
+	mod.Dir = dir
+	mod.VCS = localVCSStatus(dir)
+	return true, nil
+}
+
+// moduleSource tells where the files of the modules matching Modules come
+// from, the resolvers of From tried in order.
+type moduleSource struct {
+	Modules string   `yaml:"modules"` // comma separated module path patterns, as in GOPRIVATE
+	From    []string `yaml:"from"`    // cache, zip, proxy or local
+	Dir     string   `yaml:"dir"`     // of local, the checkout of the module
+}
+
+// resolvers returns the resolvers of src.
+func (src *moduleSource) resolvers(cwd string, verbose bool) ([]resolver, error) {
+	if src.Modules == "" || len(src.From) == 0 {
+		return nil, fmt.Errorf("sources need modules and from in %s", configName)
+	}
+	var rs []resolver
+	for _, name := range src.From {
+		switch name {
+		case "cache":
+			rs = append(rs, cacheResolver{})
+		case "zip":
+			rs = append(rs, zipResolver{})
+		case "proxy":
+			if !offline {
+				rs = append(rs, proxyResolver{cwd: cwd, verbose: verbose})
+			}
+		case "local":
+			if src.Dir == "" {
+				return nil, fmt.Errorf("the local source of %s needs a dir in %s", src.Modules, configName)
+			}
+			rs = append(rs, localResolver{dir: src.Dir})
+		default:
+			return nil, fmt.Errorf("unknown source %q of %s in %s, use cache, zip, proxy or local", name, src.Modules, configName)
+		}
+	}
+	return rs, nil
+}
+
+// resolversOf returns the resolvers to find the files of the module with the
+// import path: those of the first source matching it, or the module cache
+// then its zips, and with -fetch GOPROXY.
+func (o *vendorOptions) resolversOf(cwd, importPath string) ([]resolver, error) {
+	for i := range o.sources {
+		if plan.MatchPrefixPatterns(o.sources[i].Modules, importPath) {
+			return o.sources[i].resolvers(cwd, o.verbose)
+		}
+	}
+	rs := []resolver{cacheResolver{}, zipResolver{}}
+	if o.fetch && !offline {
+		rs = append(rs, proxyResolver{cwd: cwd, verbose: o.verbose})
+	}
+	return rs, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// retractedVersions asks the go command which of the given module versions
+// are retracted by their authors. The result maps "<path>@<version>" to the
+// retraction rationale. Requires network access unless the latest go.mod
+// files are already in the module cache. Versions the go command can't look
+// up, say of private modules, are warned about and left out. go.mod and
+// go.sum are left as they are.
+func retractedVersions(mods []string) (map[string][]string, error) {
+	if len(mods) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"list", "-mod=readonly", "-m", "-e", "-retracted", "-json"}, mods...)
+	cmd := exec.Command("go", args...)
+	cmd.Env = commandEnv()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	retracted := map[string][]string{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var m struct {
+			Path      string
+			Version   string
+			Retracted []string
+			Error     *struct {
+				Err string
+			}
+		}
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if m.Error != nil {
+			warn("go.mod", "unable to check whether %s@%s is retracted: %s", m.Path, m.Version, m.Error.Err)
+			continue
+		}
+		if len(m.Retracted) > 0 {
+			retracted[m.Path+"@"+m.Version] = m.Retracted
+		}
+	}
+	return retracted, nil
+}
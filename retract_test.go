@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Versions the go command can't look up are warned about, not failing the
+// check of the others, and go.mod is left as it is.
+func TestRetractedVersionsUnknown(t *testing.T) {
+	cwd := copyProject(t)
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOFLAGS", "")
+	before, err := os.ReadFile(filepath.Join(cwd, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retracted, err := retractedVersions([]string{"example.com/foo@v1.0.0", "example.com/private@v1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retracted) != 0 {
+		t.Errorf("retractedVersions = %q, want none", retracted)
+	}
+	after, err := os.ReadFile(filepath.Join(cwd, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("go.mod changed from %q to %q", before, after)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// runLockName is the file, within the output directory, runs writing to it
+// take an advisory lock on.
+const runLockName = ".modvendor.lock"
+
+// lockOutDir takes the run lock of the output directory, waiting up to wait
+// for another run to release it. The returned func releases it again.
+func lockOutDir(cwd string, wait time.Duration) (func(), error) {
+	if err := os.MkdirAll(outPath(cwd, ""), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("%w - unable to create %s/", err, outName(""))
+	}
+	path := outPath(cwd, runLockName)
+	deadline := time.Now().Add(wait)
+	for {
+		f, ok, err := tryLockFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w - unable to lock %s", err, outName(runLockName))
+		}
+		if ok {
+			return func() {
+				// Removed while still locked, a run waiting on the removed
+				// file notices and retries on a fresh one.
+				_ = os.Remove(path)
+				_ = f.Close()
+			}, nil
+		}
+		if !time.Now().Before(deadline) {
+			if wait > 0 {
+				return nil, whoops("another modvendor run still holds %s after %s", outName(runLockName), wait)
+			}
+			return nil, whoops("another modvendor run holds %s, pass -wait to wait for it", outName(runLockName))
+		}
+		if interrupted() {
+			return nil, errInterrupted("nothing written")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// tryLockFile opens and locks path without blocking. A file that was
+// removed by the run holding it while locking is reported as not locked.
+func tryLockFile(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := tryLock(f)
+	if err == nil && ok {
+		var info, current os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			current, err = os.Stat(path)
+			ok = err == nil && os.SameFile(info, current)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+	}
+	if err != nil || !ok {
+		_ = f.Close()
+		return nil, false, err
+	}
+	return f, true, nil
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+// tryLock always succeeds, there is no advisory locking on this platform and
+// runs aren't serialized.
+func tryLock(f *os.File) (bool, error) {
+	warn(outName(runLockName), "runs aren't serialized on %s, make sure no other modvendor run writes %s", runtime.GOOS, outName(""))
+	return true, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ansoda/modvendor/internal/secret"
+)
+
+// secretPolicies are the values of -secrets: warn prints the secrets found
+// in the files to vendor, fail reports them and writes nothing.
+var secretPolicies = []string{"warn", "fail"}
+
+// checkSecrets screens the text files of entries for secrets, warning of
+// them or failing per policy, unless q excuses them. Only the kind and line
+// of a secret are printed, leaking it no further.
+func checkSecrets(policy string, entries []*vendorEntry, q *quarantine) error {
+	sources := newSourceFiles()
+	defer sources.Close()
+
+	found := 0
+	for _, e := range entries {
+		if interrupted() {
+			return errInterrupted("nothing written")
+		}
+		r, err := sources.open(e)
+		if err != nil {
+			return fmt.Errorf("%w - unable to read %s for secrets", err, e.Src)
+		}
+		findings, err := secret.Scan(r)
+		_ = r.Close()
+		if err != nil {
+			return fmt.Errorf("%w - unable to read %s for secrets", err, e.Src)
+		}
+		if policy == "fail" && len(findings) > 0 {
+			kinds := make([]string, len(findings))
+			for i, f := range findings {
+				kinds[i] = fmt.Sprintf("%s on line %d", f.Kind, f.Line)
+			}
+			if q.excuse(e, "secret", "what looks like a secret: "+strings.Join(kinds, ", ")) {
+				continue
+			}
+		}
+		for _, f := range findings {
+			if policy == "fail" {
+				problem(outName(e.Dest), "secret: %s of %s: %s on line %d", outName(e.Dest), e.Mod, f.Kind, f.Line)
+			} else {
+				warn(outName(e.Dest), "%s of %s may leak a secret: %s on line %d", outName(e.Dest), e.Mod, f.Kind, f.Line)
+			}
+		}
+		if len(findings) > 0 {
+			found++
+		}
+	}
+	if found > 0 && policy == "fail" {
+		return failed("%d files hold what looks like secrets, nothing written; leave them out of the copy patterns, or use -secrets warn for test fixtures", found)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// openModFS opens the filesystem the files of mod are read from, the module
+// extracted to mod.Dir or its zip. The closer, if not nil, releases it.
+func openModFS(mod *Mod) (fs.FS, io.Closer, error) {
+	if mod.Zip == "" {
+		return plan.DirFS(mod.Dir), nil, nil
+	}
+	zr, fsys, err := openModZip(mod)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fsys, zr, nil
+}
+
+// sourceFiles reads the sources of vendor entries through the filesystems of
+// their modules, module zips are opened and indexed once.
+type sourceFiles struct {
+	fsys    map[*Mod]fs.FS
+	closers []io.Closer
+}
+
+func newSourceFiles() *sourceFiles {
+	return &sourceFiles{fsys: map[*Mod]fs.FS{}}
+}
+
+// modFS returns the filesystem of mod, see openModFS.
+func (s *sourceFiles) modFS(mod *Mod) (fs.FS, error) {
+	if fsys, ok := s.fsys[mod]; ok {
+		return fsys, nil
+	}
+	fsys, closer, err := openModFS(mod)
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		s.closers = append(s.closers, closer)
+	}
+	s.fsys[mod] = fsys
+	return fsys, nil
+}
+
+// open opens the source of e for reading. Symlinks were checked to stay
+// within the module while planning, whatever they point to is read.
+func (s *sourceFiles) open(e *vendorEntry) (fs.File, error) {
+	fsys, err := s.modFS(e.Mod)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(e.name())
+}
+
+// stat returns the FileInfo of the source of e, following symlinks. It is
+// statted once at most, and not at all when the walk found it.
+func (s *sourceFiles) stat(e *vendorEntry) (fs.FileInfo, error) {
+	if e.info != nil || e.walked() != nil {
+		return e.stat()
+	}
+	fsys, err := s.modFS(e.Mod)
+	if err != nil {
+		return nil, err
+	}
+	e.info, err = fs.Stat(fsys, e.name())
+	return e.info, err
+}
+
+// zipHeader returns the zip header of the source of e, if its module is read
+// from a zip.
+func (s *sourceFiles) zipHeader(e *vendorEntry) (*zip.FileHeader, bool) {
+	if e.Mod.Zip == "" {
+		return nil, false
+	}
+	info, err := s.stat(e)
+	if err != nil {
+		return nil, false
+	}
+	h, ok := info.Sys().(*zip.FileHeader)
+	return h, ok
+}
+
+func (s *sourceFiles) Close() {
+	for _, c := range s.closers {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFlags are the copy flags which don't change what is vendored, and
+// are left out of copyState.
+var stateFlags = map[string]bool{
+	"v": true, "j": true, "wait": true, "timings": true, "json": true,
+	"bwlimit": true, "iops-limit": true, "progress-size": true,
+	"cpuprofile": true, "memprofile": true, "trace": true,
+	"check": true, "interactive": true, "force": true,
+	"pre-hook": true, "post-hook": true, "depfile": true, "attest": true,
+}
+
+// copyState fingerprints what a copy run with flags vendors, besides the
+// module cache whose content doesn't change for a given version: the flags
+// given, the module and configuration files, modvendor itself and the time
+// -reproducible normalizes to, zero without. It is empty with local replaces,
+// whose content changes without any of these changing.
+func copyState(cwd string, flags *flag.FlagSet, modTime time.Time) string {
+	replaceDirs, err := loadReplaces(cwd)
+	if err != nil {
+		return ""
+	}
+	for _, r := range replaceDirs {
+		if r.local() {
+			return ""
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "tool %+v\n", currentVersion())
+	if !modTime.IsZero() {
+		fmt.Fprintf(h, "time %d\n", modTime.Unix())
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if !stateFlags[f.Name] {
+			fmt.Fprintf(h, "flag %s=%q\n", f.Name, f.Value.String())
+		}
+	})
+	files, err := moduleFiles(cwd)
+	if err != nil {
+		return ""
+	}
+	files = append(files,
+		filepath.Join(cwd, "vendor", "modules.txt"),
+		filepath.Join(cwd, configName),
+		filepath.Join(cwd, lockName),
+	)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return ""
+		}
+		// By name within the project, for the state not to depend on where
+		// it is checked out.
+		name, err := filepath.Rel(cwd, path)
+		if err != nil {
+			name = path
+		}
+		fmt.Fprintf(h, "file %s %d %x\n", filepath.ToSlash(name), len(data), sha256.Sum256(data))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// skippable reports whether the copy run with flags may be skipped when
+// upToDate: runs checking, forced, or writing elsewhere than the output
+// directory always plan.
+func skippable(flags *flag.FlagSet) bool {
+	for _, name := range []string{"check", "force", "archive", "write-plan"} {
+		if f := flags.Lookup(name); f != nil && f.Value.String() != "" && f.Value.String() != "false" {
+			return false
+		}
+	}
+	return true
+}
+
+// vendoredSum returns the checksum of the vendored file at path, through the
+// hash cache.
+func vendoredSum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s\t%d\t%d", path, info.Size(), info.ModTime().UnixNano())
+	if sum, ok := loadHashCache().get(key, sumAlgorithm); ok {
+		return sum, nil
+	}
+	sum, err := fileSum(path, sumAlgorithm)
+	if err != nil {
+		return "", err
+	}
+	loadHashCache().put(key, sum)
+	return sum, nil
+}
+
+// upToDate reports whether the last run recorded in previous (may be nil)
+// had state, and the files it vendored and generated are still there, the
+// vendored ones with the content it recorded. Their checksums are looked up
+// in the hash cache by size and modification time, any change to a file
+// has it read again.
+func upToDate(cwd string, previous *Manifest, state string) bool {
+	if previous == nil || state == "" || previous.State != state {
+		return false
+	}
+	defer saveHashCache()
+	for _, mm := range previous.Modules {
+		for _, f := range mm.Files {
+			if sum, err := vendoredSum(outPath(cwd, f.Path)); err != nil || sum != f.Sum {
+				return false
+			}
+		}
+		for _, file := range mm.Generated {
+			if _, err := os.Lstat(outPath(cwd, file)); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
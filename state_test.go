@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+)
+
+// stateProject writes a project with a vendored file recorded by the
+// manifest of a run with the state of flags, and returns its directory.
+func stateProject(t *testing.T, flags *flag.FlagSet) (string, *Manifest) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cwd := t.TempDir()
+	outDir, sumAlgorithm = "vendor", checksum.SHA256
+	files := map[string]string{
+		"go.mod":                     "module example.com/p\n\ngo 1.18\n\nrequire example.com/foo v1.0.0\n",
+		"vendor/modules.txt":         "# example.com/foo v1.0.0\n## explicit\nexample.com/foo\n",
+		"vendor/example.com/foo/a.c": "int a;\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(cwd, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum, err := fileSum(outPath(cwd, "example.com/foo/a.c"), sumAlgorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{
+		State:   copyState(cwd, flags, time.Time{}),
+		Modules: []*ManifestModule{{Path: "example.com/foo", Version: "v1.0.0", Files: []ManifestFile{{Path: "example.com/foo/a.c", Sum: sum}}}},
+	}
+	if m.State == "" {
+		t.Fatal("copyState is empty without local replaces")
+	}
+	return cwd, m
+}
+
+func stateFlagSet(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	flags := flag.NewFlagSet("copy", flag.ContinueOnError)
+	flags.Bool("check", false, "")
+	flags.Bool("force", false, "")
+	flags.Bool("v", false, "")
+	flags.String("copy", "", "")
+	flags.String("archive", "", "")
+	flags.String("write-plan", "", "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestUpToDate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, cwd string)
+		want   bool
+	}{
+		{"unchanged", func(*testing.T, string) {}, true},
+		{"tampered", func(t *testing.T, cwd string) {
+			if err := os.WriteFile(outPath(cwd, "example.com/foo/a.c"), []byte("int b;\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"tampered keeping the time", func(t *testing.T, cwd string) {
+			path := outPath(cwd, "example.com/foo/a.c")
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("int bb;\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"deleted", func(t *testing.T, cwd string) {
+			if err := os.Remove(outPath(cwd, "example.com/foo/a.c")); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"go.mod changed", func(t *testing.T, cwd string) {
+			if err := os.WriteFile(filepath.Join(cwd, "go.mod"), []byte("module example.com/p\n\ngo 1.20\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := stateFlagSet(t, "-copy", "**/*.c")
+			cwd, m := stateProject(t, flags)
+			// Looked up once, the checksum is now cached.
+			if !upToDate(cwd, m, m.State) {
+				t.Fatal("upToDate = false right after the run")
+			}
+			tt.change(t, cwd)
+			if got := upToDate(cwd, m, copyState(cwd, flags, time.Time{})); got != tt.want {
+				t.Errorf("upToDate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyStateFlags(t *testing.T) {
+	cwd, m := stateProject(t, stateFlagSet(t, "-copy", "**/*.c"))
+	tests := []struct {
+		args []string
+		same bool
+	}{
+		{[]string{"-copy", "**/*.c"}, true},
+		{[]string{"-copy", "**/*.c", "-v", "-check"}, true},
+		{[]string{"-copy", "**/*.h"}, false},
+	}
+	for _, tt := range tests {
+		if got := copyState(cwd, stateFlagSet(t, tt.args...), time.Time{}) == m.State; got != tt.same {
+			t.Errorf("copyState(%q) same = %v, want %v", tt.args, got, tt.same)
+		}
+	}
+}
+
+func TestSkippable(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"-v"}, true},
+		{[]string{"-check"}, false},
+		{[]string{"-force"}, false},
+		{[]string{"-archive", "v.tgz"}, false},
+		{[]string{"-write-plan", "p.json"}, false},
+	}
+	for _, tt := range tests {
+		if got := skippable(stateFlagSet(t, tt.args...)); got != tt.want {
+			t.Errorf("skippable(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// readGoSum parses go.sum file and returns module hashes keyed by
+// "<path>@<version>". go.mod only hashes ("<version>/go.mod") are skipped.
+func readGoSum(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s := strings.Fields(scanner.Text())
+		if len(s) != 3 || strings.HasSuffix(s[1], "/go.mod") {
+			continue
+		}
+		sums[s[0]+"@"+s[1]] = s[2]
+	}
+	return sums, scanner.Err()
+}
+
+var goSum map[string]string
+
+// loadGoSum reads go.sum of the project in cwd once. At a workspace root,
+// it reads those of the modules of the workspace and go.work.sum instead,
+// whichever exist.
+func loadGoSum(cwd string) (map[string]string, error) {
+	if goSum != nil {
+		return goSum, nil
+	}
+	if !workspaceRoot(cwd) {
+		sums, err := readGoSum(filepath.Join(cwd, "go.sum"))
+		if err != nil {
+			return nil, fmt.Errorf("%w - unable to read go.sum", err)
+		}
+		goSum = sums
+		return goSum, nil
+	}
+	files, err := goSumFiles(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("%w - unable to read go.work", err)
+	}
+	sums := map[string]string{}
+	for _, file := range files {
+		s, err := readGoSum(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w - unable to read %s", err, file)
+		}
+		for key, sum := range s {
+			sums[key] = sum
+		}
+	}
+	goSum = sums
+	return goSum, nil
+}
+
+// goSumFiles returns the go.sum files next to goModFiles, then go.work.sum
+// when in workspace mode.
+func goSumFiles(cwd string) ([]string, error) {
+	goMods, err := goModFiles(cwd)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, goMod := range goMods {
+		files = append(files, filepath.Join(filepath.Dir(goMod), "go.sum"))
+	}
+	if work := findGoWork(cwd); work != "" {
+		files = append(files, work+".sum")
+	}
+	return files, nil
+}
+
+// hashDir computes the "h1:" hash of the module extracted to dir, the same
+// way the go command does for go.sum (see golang.org/x/mod/sumdb/dirhash).
+func hashDir(dir, prefix string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel := file[len(dir)+1:]
+		files = append(files, filepath.ToSlash(filepath.Join(prefix, rel)))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hash1(files, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, strings.TrimPrefix(name, prefix+"/")))
+	})
+}
+
+func hash1(files []string, open func(string) (io.ReadCloser, error)) (string, error) {
+	sums := make(map[string]string, len(files))
+	for _, file := range files {
+		r, err := open(file)
+		if err != nil {
+			return "", err
+		}
+		hf := sha256.New()
+		_, err = io.Copy(hf, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+		sums[file] = hex.EncodeToString(hf.Sum(nil))
+	}
+	return hash1Sums(sums)
+}
+
+// hash1Sums is hash1 of files whose hex sha256 is known already.
+func hash1Sums(sums map[string]string) (string, error) {
+	files := make([]string, 0, len(sums))
+	for file := range sums {
+		if strings.Contains(file, "\n") {
+			return "", errors.New("filenames with newlines are not supported")
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, file := range files {
+		_, _ = fmt.Fprintf(h, "%s  %s\n", sums[file], file)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// goSumKey returns the key of mod in readGoSum, that of its replacement if
+// replaced, "" for local replaces which go.sum doesn't hash.
+func goSumKey(mod *Mod) string {
+	if mod.SourcePath != "" {
+		if mod.SourceVersion == "" {
+			return ""
+		}
+		return mod.SourcePath + "@" + mod.SourceVersion
+	}
+	return mod.ImportPath + "@" + mod.Version
+}
+
+// errPrivateNoSum is verifyMod's error for private modules without a go.sum
+// entry, which the checksum database can't stand in for: callers warn rather
+// than fail.
+var errPrivateNoSum = errors.New("private module without go.sum entry, not verified")
+
+// verifyMod compares the module cache content of mod against go.sum.
+// Modules replaced with a local directory have nothing to verify against.
+func verifyMod(mod *Mod, sums map[string]string) error {
+	key := goSumKey(mod)
+	if key == "" {
+		return nil
+	}
+	want, ok := sums[key]
+	if !ok {
+		if privateModule(strings.SplitN(key, "@", 2)[0]) {
+			return fmt.Errorf("%w: %s", errPrivateNoSum, key)
+		}
+		return fmt.Errorf("missing go.sum entry for %s", key)
+	}
+	var got string
+	var err error
+	if mod.Zip != "" {
+		got, err = hashZip(mod.Zip)
+	} else {
+		got, err = hashDir(mod.Dir, key)
+	}
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s\n\tmodule cache: %s\n\tgo.sum:       %s", key, got, want)
+	}
+	return nil
+}
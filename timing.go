@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// modTiming is how long globbing and copying a module took, and what was
+// copied.
+type modTiming struct {
+	Glob   time.Duration
+	Copy   time.Duration
+	Copied int   // files copied, rather than found unchanged
+	Bytes  int64 // bytes copied
+}
+
+// runSummary is the -json summary of a copy run.
+type runSummary struct {
+	Seconds float64         `json:"seconds"`
+	Modules []moduleSummary `json:"modules"`
+}
+
+type moduleSummary struct {
+	Path        string  `json:"path"`
+	Version     string  `json:"version"`
+	Files       int     `json:"files"`
+	Copied      int     `json:"copied"`
+	Bytes       int64   `json:"bytes"`
+	GlobSeconds float64 `json:"globSeconds"`
+	CopySeconds float64 `json:"copySeconds"`
+}
+
+// newRunSummary summarizes the modules with vendored files, slowest first.
+func newRunSummary(elapsed time.Duration, modules []*Mod) *runSummary {
+	s := &runSummary{Seconds: elapsed.Seconds(), Modules: []moduleSummary{}}
+	for _, mod := range modules {
+		if len(mod.VendorList) == 0 {
+			continue
+		}
+		s.Modules = append(s.Modules, moduleSummary{
+			Path:        mod.ImportPath,
+			Version:     mod.Version,
+			Files:       len(mod.VendorList),
+			Copied:      mod.Timing.Copied,
+			Bytes:       mod.Timing.Bytes,
+			GlobSeconds: mod.Timing.Glob.Seconds(),
+			CopySeconds: mod.Timing.Copy.Seconds(),
+		})
+	}
+	sort.SliceStable(s.Modules, func(i, j int) bool {
+		mi, mj := s.Modules[i], s.Modules[j]
+		return mi.GlobSeconds+mi.CopySeconds > mj.GlobSeconds+mj.CopySeconds
+	})
+	return s
+}
+
+func (s *runSummary) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MODULE\tFILES\tCOPIED\tSIZE\tGLOB\tCOPY")
+	var files, copied int
+	var bytes int64
+	for _, m := range s.Modules {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", m.Path, m.Files, m.Copied, formatSize(m.Bytes), seconds(m.GlobSeconds), seconds(m.CopySeconds))
+		files += m.Files
+		copied += m.Copied
+		bytes += m.Bytes
+	}
+	_, _ = fmt.Fprintf(w, "total\t%d\t%d\t%s\t\t%s\n", files, copied, formatSize(bytes), seconds(s.Seconds))
+	_ = w.Flush()
+}
+
+func (s *runSummary) writeJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond).String()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/modtxt"
+)
+
+// goModVendorMeta are the prefixes of the files go mod vendor copies from the
+// root of modules, and the directories down to their packages, as cmd/go
+// does.
+var goModVendorMeta = []string{"AUTHORS", "CONTRIBUTORS", "COPYLEFT", "COPYING", "COPYRIGHT", "LEGAL", "LICENSE", "NOTICE", "PATENTS"}
+
+// unknownFiles returns the files under the output directory, slash separated
+// and relative to it, which neither the manifest records nor modvendor keeps
+// there, nor, within ./vendor/, go mod vendor wrote for the packages of
+// vendor/modules.txt: the files of the package directories but tests, those
+// they embed, and the license and similar files of their modules.
+func unknownFiles(cwd string, manifest *Manifest) ([]string, error) {
+	known := map[string]bool{manifestName: true, runLockName: true, auditName: true}
+	for _, a := range checksum.Algorithms {
+		known[sumsName(a)] = true
+	}
+	for _, mm := range manifest.Modules {
+		for _, f := range mm.Files {
+			known[f.Path] = true
+		}
+		for _, file := range mm.Generated {
+			known[file] = true
+		}
+	}
+
+	var vendored *goModVendored
+	if outDir == "vendor" {
+		var err error
+		if vendored, err = readGoModVendored(cwd); err != nil {
+			return nil, err
+		}
+	}
+
+	root := outPath(cwd, "")
+	var unknown []string
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(file[len(root):])
+		rel = strings.TrimPrefix(rel, "/")
+		if d.IsDir() {
+			if rel == stagingName || rel == scanName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !known[rel] && (vendored == nil || !vendored.wrote(rel)) {
+			unknown = append(unknown, rel)
+		}
+		return nil
+	})
+	sort.Strings(unknown)
+	return unknown, err
+}
+
+// goModVendored tells the files go mod vendor wrote to ./vendor/.
+type goModVendored struct {
+	dir      string              // ./vendor/
+	pkgs     map[string]bool     // package directories, slash separated
+	metaDirs map[string]bool     // directories from module roots down to packages
+	embeds   map[string][]string // go:embed patterns by package directory
+}
+
+func readGoModVendored(cwd string) (*goModVendored, error) {
+	f, err := os.Open(filepath.Join(cwd, "vendor", "modules.txt"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	modules, err := modtxt.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &goModVendored{dir: filepath.Join(cwd, "vendor"), pkgs: map[string]bool{}, metaDirs: map[string]bool{}, embeds: map[string][]string{}}
+	for _, mod := range modules {
+		for _, pkg := range mod.Packages {
+			v.pkgs[pkg] = true
+			for dir := pkg; ; dir = path.Dir(dir) {
+				v.metaDirs[dir] = true
+				if dir == mod.Path || !strings.HasPrefix(dir, mod.Path+"/") {
+					break
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+// wrote reports whether go mod vendor wrote the file, relative to ./vendor/.
+func (v *goModVendored) wrote(file string) bool {
+	if file == "modules.txt" {
+		return true
+	}
+	dir, name := path.Split(file)
+	dir = strings.TrimSuffix(dir, "/")
+	if v.pkgs[dir] && !strings.HasSuffix(name, "_test.go") {
+		return true
+	}
+	if v.metaDirs[dir] {
+		for _, prefix := range goModVendorMeta {
+			if strings.HasPrefix(strings.ToUpper(name), prefix) {
+				return true
+			}
+		}
+	}
+	// Embedded files are below the directory of their package.
+	for pkg := path.Dir(dir); pkg != "."; pkg = path.Dir(pkg) {
+		if !v.pkgs[pkg] {
+			continue
+		}
+		rel := file[len(pkg)+1:]
+		for _, pattern := range v.embedPatterns(pkg) {
+			if embedMatch(pattern, rel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// embedPatterns returns the go:embed patterns of the package directory pkg,
+// read once.
+func (v *goModVendored) embedPatterns(pkg string) []string {
+	if patterns, ok := v.embeds[pkg]; ok {
+		return patterns
+	}
+	var patterns []string
+	dir := filepath.Join(v.dir, filepath.FromSlash(pkg))
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+		patterns = append(patterns, readEmbedPatterns(filepath.Join(dir, e.Name()))...)
+	}
+	v.embeds[pkg] = patterns
+	return patterns
+}
+
+// readEmbedPatterns returns the patterns of the go:embed directives of the Go
+// file, without their quotes; escapes within quotes, rarely needed, are left
+// as they are.
+func readEmbedPatterns(file string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest := strings.TrimPrefix(line, "//go:embed "); rest != line {
+			for _, p := range strings.Fields(rest) {
+				patterns = append(patterns, strings.Trim(p, "\"`"))
+			}
+		}
+	}
+	return patterns
+}
+
+// embedMatch reports whether the go:embed pattern embeds the file, relative
+// to the package directory: matching it, or a directory it is in.
+func embedMatch(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "all:")
+	for name := file; name != "."; name = path.Dir(name) {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
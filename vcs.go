@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// VCSStatus describes the working copy a module is replaced with.
+type VCSStatus struct {
+	System   string `json:"system,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Dirty    bool   `json:"dirty,omitempty"`
+}
+
+// localVCSStatus inspects the checkout dir belongs to. An empty System means
+// there is no version control information for dir.
+func localVCSStatus(dir string) *VCSStatus {
+	rev, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return &VCSStatus{}
+	}
+	// Restrict status to dir, the replace target may be a subdirectory of
+	// a bigger repository.
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--", ".").Output()
+	if err != nil {
+		return &VCSStatus{}
+	}
+	return &VCSStatus{
+		System:   "git",
+		Revision: strings.TrimSpace(string(rev)),
+		Dirty:    len(strings.TrimSpace(string(status))) > 0,
+	}
+}
@@ -0,0 +1,281 @@
+// Package vendoring is the library behind modvendor, for build tools writing
+// the files of vendored modules without running the command.
+package vendoring
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/ansoda/modvendor/internal/copyer"
+)
+
+// Destination is where vendored files are written: a directory, an archive,
+// or memory. Names are slash separated, the directories of a file are
+// implied by its name. Archives are written one file at a time, Create isn't
+// called again before the previous file is closed.
+type Destination interface {
+	// Create creates the file name, with the size, mode and modification
+	// time of info. The file is complete once the writer is closed.
+	Create(name string, info fs.FileInfo) (io.WriteCloser, error)
+
+	// Close finishes the destination, e.g. writes the end of an archive.
+	Close() error
+}
+
+// PathDestination is implemented by destinations backed by a directory, for
+// copies to clone files or have the kernel copy them.
+type PathDestination interface {
+	Destination
+
+	// Path returns the path of the file name.
+	Path(name string) string
+}
+
+// dirDest writes the files within a directory.
+type dirDest struct {
+	dir  string
+	mu   sync.Mutex
+	made map[string]bool
+}
+
+// Dir returns the destination writing files within dir, which is created as
+// needed. Files get the permissions of their info plus 0644.
+func Dir(dir string) PathDestination {
+	return &dirDest{dir: dir, made: map[string]bool{}}
+}
+
+func (d *dirDest) Path(name string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(name))
+}
+
+func (d *dirDest) Create(name string, info fs.FileInfo) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	dst := d.Path(name)
+	if err := d.mkdirAll(filepath.Dir(dst)); err != nil {
+		return nil, err
+	}
+	perm := info.Mode().Perm() | 0644
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &dirFile{File: f, perm: perm, modTime: info.ModTime()}, nil
+}
+
+func (d *dirDest) mkdirAll(dir string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.made[dir] {
+		return nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	d.made[dir] = true
+	return nil
+}
+
+func (d *dirDest) Close() error {
+	return nil
+}
+
+// dirFile sets the permissions and modification time of the file once
+// written.
+type dirFile struct {
+	*os.File
+	perm    fs.FileMode
+	modTime time.Time
+}
+
+func (f *dirFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	// An existing file keeps its permissions when opened.
+	if err := os.Chmod(f.Name(), f.perm); err != nil {
+		return err
+	}
+	return os.Chtimes(f.Name(), f.modTime, f.modTime)
+}
+
+// tarDest writes a tar archive, with a header for every directory before
+// the first file within it.
+type tarDest struct {
+	tw   *tar.Writer
+	zw   *gzip.Writer // nil for plain tar
+	dirs map[string]bool
+}
+
+// Tar returns the destination writing a tar archive to w.
+func Tar(w io.Writer) Destination {
+	return &tarDest{tw: tar.NewWriter(w), dirs: map[string]bool{}}
+}
+
+// TarGz returns the destination writing a gzip compressed tar archive to w.
+func TarGz(w io.Writer) Destination {
+	// The zero header has neither a name nor a modification time.
+	zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+	return &tarDest{tw: tar.NewWriter(zw), zw: zw, dirs: map[string]bool{}}
+}
+
+func (d *tarDest) Create(name string, info fs.FileInfo) (io.WriteCloser, error) {
+	// Directories are implied by the files, add them for extractors that
+	// don't create them.
+	for i := 0; i < len(name); i++ {
+		if name[i] != '/' || d.dirs[name[:i]] {
+			continue
+		}
+		d.dirs[name[:i]] = true
+		if err := d.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name[:i] + "/", Mode: 0755, ModTime: info.ModTime()}); err != nil {
+			return nil, err
+		}
+	}
+	h := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: int64(info.Mode().Perm()), Size: info.Size(), ModTime: info.ModTime()}
+	if err := d.tw.WriteHeader(h); err != nil {
+		return nil, err
+	}
+	return nopCloser{d.tw}, nil
+}
+
+func (d *tarDest) Close() error {
+	if err := d.tw.Close(); err != nil {
+		return err
+	}
+	if d.zw != nil {
+		return d.zw.Close()
+	}
+	return nil
+}
+
+// Zip returns the destination writing a zip archive to w, with deflated
+// files.
+func Zip(w io.Writer) Destination {
+	return zipDest{zip.NewWriter(w)}
+}
+
+type zipDest struct {
+	zw *zip.Writer
+}
+
+func (d zipDest) Create(name string, info fs.FileInfo) (io.WriteCloser, error) {
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: info.ModTime()}
+	h.SetMode(info.Mode().Perm())
+	w, err := d.zw.CreateHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{w}, nil
+}
+
+func (d zipDest) Close() error {
+	return d.zw.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// Memory is a destination keeping the files in memory, for tests. Files is
+// safe to read once the destination is closed.
+type Memory struct {
+	mu    sync.Mutex
+	Files fstest.MapFS
+}
+
+// NewMemory returns an empty in-memory destination.
+func NewMemory() *Memory {
+	return &Memory{Files: fstest.MapFS{}}
+}
+
+func (m *Memory) Create(name string, info fs.FileInfo) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	return &memFile{m: m, name: name, mode: info.Mode().Perm(), modTime: info.ModTime()}, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Names returns the names of the files written, sorted.
+func (m *Memory) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memFile struct {
+	bytes.Buffer
+	m       *Memory
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (f *memFile) Close() error {
+	f.m.mu.Lock()
+	defer f.m.mu.Unlock()
+	f.m.Files[f.name] = &fstest.MapFile{Data: f.Bytes(), Mode: f.mode, ModTime: f.modTime}
+	return nil
+}
+
+// ArchiveDestination returns the archive destination for the name of the
+// file written, by its extension: .tar.gz, .tgz, .tar or .zip. It returns nil
+// for other names.
+func ArchiveDestination(name string, w io.Writer) Destination {
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz(w)
+	case strings.HasSuffix(name, ".tar"):
+		return Tar(w)
+	case strings.HasSuffix(name, ".zip"):
+		return Zip(w)
+	}
+	return nil
+}
+
+// Write writes the content of r, described by info, to dst as name. Once ctx
+// is done, it stops with its error and the file is left incomplete.
+func Write(ctx context.Context, dst Destination, name string, info fs.FileInfo, r io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w, err := dst.Create(name, info)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(w, copyer.Interruptible(ctx, r))
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	loggerOf(ctx).Debug("wrote "+name, "file", name, "size", n)
+	return nil
+}
@@ -0,0 +1,194 @@
+package vendoring
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testTime = time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+
+// testFiles are written to every destination.
+var testFiles = fstest.MapFS{
+	"vendor/example.com/foo/csrc/a.c":  {Data: []byte("int a;\n"), Mode: 0644, ModTime: testTime},
+	"vendor/example.com/foo/csrc/a.h":  {Data: []byte("extern int a;\n"), Mode: 0644, ModTime: testTime},
+	"vendor/example.com/foo/gen.sh":    {Data: []byte("#!/bin/sh\n"), Mode: 0755, ModTime: testTime},
+	"vendor/example.com/bar/empty.txt": {Mode: 0644, ModTime: testTime},
+}
+
+func writeTestFiles(t *testing.T, dst Destination) {
+	t.Helper()
+	err := fs.WalkDir(testFiles, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return Write(context.Background(), dst, name, info, bytes.NewReader(testFiles[name].Data))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkFiles compares the files of fsys with testFiles.
+func checkFiles(t *testing.T, fsys fs.FS, modes bool) {
+	t.Helper()
+	for name, want := range testFiles {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(data, want.Data) {
+			t.Errorf("%s = %q, want %q", name, data, want.Data)
+		}
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(testTime) {
+			t.Errorf("%s modified %s, want %s", name, info.ModTime(), testTime)
+		}
+		if modes && info.Mode().Perm() != want.Mode {
+			t.Errorf("%s has mode %s, want %s", name, info.Mode().Perm(), want.Mode)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	// Existing files are overwritten.
+	stale := filepath.Join(dir, "vendor", "example.com", "foo", "csrc", "a.c")
+	if err := os.MkdirAll(filepath.Dir(stale), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("stale, and longer than the new content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := Dir(dir)
+	writeTestFiles(t, dst)
+	checkFiles(t, os.DirFS(dir), os.PathSeparator == '/')
+	if got, want := dst.Path("vendor/example.com/foo/gen.sh"), filepath.Join(dir, "vendor", "example.com", "foo", "gen.sh"); got != want {
+		t.Errorf("Path = %s, want %s", got, want)
+	}
+	if _, err := dst.Create("../escape", testInfo(t)); err == nil {
+		t.Error("Create of ../escape succeeded")
+	}
+}
+
+func TestTar(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		dst := Tar(&buf)
+		if compress {
+			dst = TarGz(&buf)
+		}
+		writeTestFiles(t, dst)
+
+		var r io.Reader = &buf
+		if compress {
+			zr, err := gzip.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = zr
+		}
+		got := fstest.MapFS{}
+		var dirs []string
+		tr := tar.NewReader(r)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Typeflag == tar.TypeDir {
+				dirs = append(dirs, h.Name)
+				continue
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[h.Name] = &fstest.MapFile{Data: data, Mode: fs.FileMode(h.Mode), ModTime: h.ModTime}
+		}
+		checkFiles(t, got, true)
+
+		// Every directory is added once, before the files within it.
+		want := []string{"vendor/", "vendor/example.com/", "vendor/example.com/bar/", "vendor/example.com/foo/", "vendor/example.com/foo/csrc/"}
+		if !reflect.DeepEqual(dirs, want) {
+			t.Errorf("tar directories = %v, want %v", dirs, want)
+		}
+	}
+}
+
+func TestZip(t *testing.T) {
+	var buf bytes.Buffer
+	writeTestFiles(t, Zip(&buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, zr, true)
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	writeTestFiles(t, m)
+	checkFiles(t, m.Files, true)
+	if got := strings.Join(m.Names(), " "); !strings.HasPrefix(got, "vendor/example.com/bar/empty.txt vendor/example.com/foo/csrc/a.c") {
+		t.Errorf("Names = %s", got)
+	}
+}
+
+func TestWriteCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := NewMemory()
+	if err := Write(ctx, m, "a.c", testInfo(t), strings.NewReader("int a;\n")); err != context.Canceled {
+		t.Errorf("Write with a canceled context returned %v, want context.Canceled", err)
+	}
+	if len(m.Names()) != 0 {
+		t.Errorf("Write with a canceled context wrote %v", m.Names())
+	}
+}
+
+func TestArchiveDestination(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"a.tar.gz": true, "a.tgz": true, "a.tar": true, "a.zip": true,
+		"a.rar": false, "tar": false,
+	} {
+		if got := ArchiveDestination(name, io.Discard) != nil; got != ok {
+			t.Errorf("ArchiveDestination(%s) = %v, want %v", name, got, ok)
+		}
+	}
+}
+
+func testInfo(t *testing.T) fs.FileInfo {
+	t.Helper()
+	info, err := fs.Stat(testFiles, "vendor/example.com/foo/csrc/a.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
@@ -0,0 +1,55 @@
+package vendoring
+
+// PlanDiff is what executing another plan instead of a plan changes, by
+// destination.
+type PlanDiff struct {
+	Added   []*Op       // of the other plan only
+	Removed []*Op       // of the plan only
+	Changed []OpChanged // written by both, from another source
+}
+
+// OpChanged is a destination both plans write, from other module versions,
+// sources, sizes or modes.
+type OpChanged struct {
+	Old, New *Op
+}
+
+// Empty reports whether both plans write the same.
+func (d *PlanDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares p with other, e.g. planned after bumping a module in go.mod,
+// without reading any file: operations copying the same file of the same
+// module version, with the same size and mode, are taken for unchanged.
+// Operations are in the order of destinations.
+func (p *CopyPlan) Diff(other *CopyPlan) *PlanDiff {
+	d := &PlanDiff{}
+	old := make(map[string]*Op, len(p.Ops))
+	for _, op := range p.Ops {
+		old[op.Dest] = op
+	}
+	seen := make(map[string]bool, len(other.Ops))
+	for _, op := range other.Ops {
+		seen[op.Dest] = true
+		prev, ok := old[op.Dest]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, op)
+		case !sameOp(prev, op):
+			d.Changed = append(d.Changed, OpChanged{Old: prev, New: op})
+		}
+	}
+	for _, op := range p.Ops {
+		if !seen[op.Dest] {
+			d.Removed = append(d.Removed, op)
+		}
+	}
+	return d
+}
+
+func sameOp(a, b *Op) bool {
+	return a.Module.Path == b.Module.Path && a.Module.Version == b.Module.Version &&
+		a.Module.Replace == b.Module.Replace && a.Module.ReplaceVersion == b.Module.ReplaceVersion &&
+		a.Src == b.Src && a.Size == b.Size && a.Mode == b.Mode
+}
@@ -0,0 +1,59 @@
+package vendoring
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func opNames(ops []*Op) []string {
+	names := []string{}
+	for _, op := range ops {
+		names = append(names, op.Dest)
+	}
+	return names
+}
+
+func TestPlanDiff(t *testing.T) {
+	plan := func(mod *Module) *CopyPlan {
+		t.Helper()
+		p, err := Plan(context.Background(), NewOptions(WithModules(mod), WithPatterns("**/*.c", "**/*.h")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	before := plan(testModule())
+	if d := before.Diff(plan(testModule())); !d.Empty() {
+		t.Errorf("Diff of the same plans = %+v, want none", d)
+	}
+
+	// The next version drops a header, adds a file and changes another.
+	mod := testModule()
+	mod.Version = "v1.1.0"
+	fsys := mod.FS.(fstest.MapFS)
+	delete(fsys, "csrc/a.h")
+	fsys["csrc/b.c"] = &fstest.MapFile{Data: []byte("int b;\n")}
+	after := plan(mod)
+
+	d := before.Diff(after)
+	if got, want := opNames(d.Added), []string{"example.com/foo/csrc/b.c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Added = %q, want %q", got, want)
+	}
+	if got, want := opNames(d.Removed), []string{"example.com/foo/csrc/a.h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Removed = %q, want %q", got, want)
+	}
+	// Files of another version are changed, same size or not.
+	if len(d.Changed) != 3 || d.Changed[0].Old.Module.Version != "v1.0.0" || d.Changed[0].New.Module.Version != "v1.1.0" {
+		t.Errorf("Changed = %+v, want the 3 files of both versions", d.Changed)
+	}
+
+	// At the same version only the size or mode tell.
+	mod = testModule()
+	mod.FS.(fstest.MapFS)["lib/lib.c"].Data = []byte("int longer;\n")
+	d = before.Diff(plan(mod))
+	if len(d.Changed) != 1 || d.Changed[0].New.Dest != "example.com/foo/lib/lib.c" || len(d.Added)+len(d.Removed) != 0 {
+		t.Errorf("Diff = %+v, want lib.c changed", d)
+	}
+}
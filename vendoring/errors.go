@@ -0,0 +1,85 @@
+package vendoring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingGoMod is returned for projects without a go.mod file.
+var ErrMissingGoMod = errors.New("cannot find `go.mod` file")
+
+// ErrMissingModulesTxt is returned for projects whose modules weren't
+// vendored by `go mod vendor` yet.
+var ErrMissingModulesTxt = errors.New("cannot find vendor/modules.txt, first run `go mod vendor` and try again")
+
+// ModuleCacheMissError is returned for a module, or its replacement, neither
+// the module cache nor the download cache holds.
+type ModuleCacheMissError struct {
+	Module Module
+	Dir    string // where the module was looked for
+}
+
+func (e *ModuleCacheMissError) Error() string {
+	path, version := e.Module.Path, e.Module.Version
+	if e.Module.ReplaceVersion != "" {
+		path, version = e.Module.Replace, e.Module.ReplaceVersion
+	}
+	return fmt.Sprintf("%s@%s is missing from the module cache, %s does not exist; run `go mod download` and try again", path, version, e.Dir)
+}
+
+// CopyError is returned when copying a file fails, Err tells why.
+type CopyError struct {
+	Src string // the file of the module
+	Dst string // the file being written
+	Err error
+}
+
+func (e *CopyError) Error() string {
+	return fmt.Sprintf("%s - unable to copy %s to %s", e.Err.Error(), e.Src, e.Dst)
+}
+
+func (e *CopyError) Unwrap() error {
+	return e.Err
+}
+
+// Errors are the failures of a run going on past them, say one per file
+// failing to copy. errors.Is and errors.As look into every one of them.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of e is target, for errors.Is: go 1.18 doesn't
+// unwrap into several errors.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first of e that errors.As finds it in.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns e, or nil without failures.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
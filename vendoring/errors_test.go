@@ -0,0 +1,61 @@
+package vendoring
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestModuleCacheMissError(t *testing.T) {
+	tests := []struct {
+		mod  Module
+		want string
+	}{
+		{Module{Path: "example.com/foo", Version: "v1.0.0"}, "example.com/foo@v1.0.0 is missing from the module cache, /cache/dir does not exist; run `go mod download` and try again"},
+		{Module{Path: "example.com/foo", Version: "v1.0.0", Replace: "example.com/fork", ReplaceVersion: "v1.1.0"}, "example.com/fork@v1.1.0 is missing from the module cache, /cache/dir does not exist; run `go mod download` and try again"},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("loading modules: %w", &ModuleCacheMissError{Module: tt.mod, Dir: "/cache/dir"})
+		var miss *ModuleCacheMissError
+		if !errors.As(err, &miss) {
+			t.Fatalf("errors.As(%v) found no ModuleCacheMissError", err)
+		}
+		if got := miss.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCopyError(t *testing.T) {
+	err := fmt.Errorf("vendoring: %w", &CopyError{Src: "a.c", Dst: "vendor/a.c", Err: fs.ErrPermission})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("errors.Is(%v, fs.ErrPermission) = false", err)
+	}
+	var cerr *CopyError
+	if !errors.As(err, &cerr) || cerr.Src != "a.c" || cerr.Dst != "vendor/a.c" {
+		t.Errorf("errors.As(%v) = %+v", err, cerr)
+	}
+	if got, want := cerr.Error(), "permission denied - unable to copy a.c to vendor/a.c"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cerr := &CopyError{Src: "b.c", Dst: "vendor/b.c", Err: fs.ErrNotExist}
+	err := fmt.Errorf("vendoring: %w", Errors{errors.New("a.c failed"), cerr})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("errors.Is(%v, fs.ErrNotExist) = false", err)
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		t.Errorf("errors.Is(%v, fs.ErrPermission) = true", err)
+	}
+	var got *CopyError
+	if !errors.As(err, &got) || got != cerr {
+		t.Errorf("errors.As(%v) = %+v, want %+v", err, got, cerr)
+	}
+	var miss *ModuleCacheMissError
+	if errors.As(err, &miss) {
+		t.Errorf("errors.As(%v) found a ModuleCacheMissError", err)
+	}
+}
@@ -0,0 +1,77 @@
+package vendoring
+
+import (
+	"io/fs"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// Decision is what a Filter decides about a file.
+type Decision int
+
+const (
+	// Default leaves the decision to the next filter. Files no filter
+	// decides about are vendored.
+	Default Decision = iota
+
+	// Include vendors the file, whatever the filters after.
+	Include
+
+	// Exclude leaves the file out, whatever the filters after.
+	Exclude
+
+	// tooLarge is Exclude by MaxSize, for Plan to warn about.
+	tooLarge
+)
+
+// Filter decides whether to vendor the file name, slash separated and
+// relative to the module root, matched by the copy patterns. info describes
+// the file, symlinks followed. Filters run in order, the first one deciding
+// other than Default wins:
+//
+//	filters := vendoring.Filters{
+//		vendoring.Modules("github.com/big/assets", vendoring.Include),
+//		vendoring.MaxSize(1 << 20),
+//	}
+type Filter func(mod Module, name string, info fs.FileInfo) Decision
+
+// Filters runs filters in order.
+type Filters []Filter
+
+// Keep reports whether the file passes the filters.
+func (f Filters) Keep(mod Module, name string, info fs.FileInfo) bool {
+	d := f.decide(mod, name, info)
+	return d != Exclude && d != tooLarge
+}
+
+// decide returns the decision of the first filter deciding other than
+// Default.
+func (f Filters) decide(mod Module, name string, info fs.FileInfo) Decision {
+	for _, filter := range f {
+		if d := filter(mod, name, info); d != Default {
+			return d
+		}
+	}
+	return Default
+}
+
+// MaxSize excludes files larger than size bytes, Plan warns about them.
+func MaxSize(size int64) Filter {
+	return func(mod Module, name string, info fs.FileInfo) Decision {
+		if info.Size() > size {
+			return tooLarge
+		}
+		return Default
+	}
+}
+
+// Modules decides d for every file of the modules matching the comma
+// separated module path patterns, as in GOPRIVATE.
+func Modules(patterns string, d Decision) Filter {
+	return func(mod Module, name string, info fs.FileInfo) Decision {
+		if plan.MatchPrefixPatterns(patterns, mod.Path) {
+			return d
+		}
+		return Default
+	}
+}
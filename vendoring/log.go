@@ -0,0 +1,40 @@
+package vendoring
+
+import "context"
+
+// Logger receives the progress and warnings of the library, a *slog.Logger
+// is one. Args are alternating keys and values, as slog takes them.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+type discard struct{}
+
+func (discard) Debug(string, ...interface{}) {}
+func (discard) Warn(string, ...interface{})  {}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns ctx making the library report to l within it,
+// for Match and Write which take no Options. Options.Logger takes precedence
+// over it.
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerOf returns the logger of ctx, reporting nowhere without one.
+func loggerOf(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	return discard{}
+}
+
+// context returns ctx reporting to the logger of o, if set.
+func (o *Options) context(ctx context.Context) context.Context {
+	if o == nil || o.Logger == nil {
+		return ctx
+	}
+	return ContextWithLogger(ctx, o.Logger)
+}
@@ -0,0 +1,100 @@
+package vendoring
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// recorder records what is logged, a line per message with its args.
+type recorder struct {
+	lines []string
+}
+
+func (r *recorder) Debug(msg string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprint("DEBUG ", msg, " ", args))
+}
+
+func (r *recorder) Warn(msg string, args ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprint("WARN ", msg, " ", args))
+}
+
+func TestLoggerWrite(t *testing.T) {
+	rec := &recorder{}
+	ctx := ContextWithLogger(context.Background(), rec)
+
+	info, err := fs.Stat(testFiles, "vendor/example.com/foo/csrc/a.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(ctx, NewMemory(), "a.c", info, bytes.NewReader([]byte("int a;\n"))); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DEBUG wrote a.c [file a.c size 7]"}
+	if !reflect.DeepEqual(rec.lines, want) {
+		t.Errorf("logged %q, want %q", rec.lines, want)
+	}
+}
+
+func TestLoggerSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "mod")
+	for _, f := range []string{"mod/csrc/a.c", "outside/b.c"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "outside", "b.c"), filepath.Join(root, "csrc", "b.c")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	rec := &recorder{}
+	ctx := ContextWithLogger(context.Background(), rec)
+
+	mod := &Module{Path: "example.com/foo", Packages: []string{"example.com/foo"}, FS: plan.DirFS(root)}
+	files, err := Match(ctx, mod, []string{"csrc/*.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fileNames(files); !reflect.DeepEqual(got, []string{"csrc/a.c"}) {
+		t.Errorf("Match = %q, want csrc/a.c only", got)
+	}
+	want := []string{fmt.Sprintf("WARN skipping %s, it resolves outside of module root %s [module example.com/foo file csrc/b.c]", filepath.Join(root, "csrc", "b.c"), root)}
+	if !reflect.DeepEqual(rec.lines, want) {
+		t.Errorf("logged %q, want %q", rec.lines, want)
+	}
+
+	// Symlinks found walking directories are warned about as well.
+	rec.lines = nil
+	if _, err := Match(ctx, mod, []string{"csrc"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.lines) != 1 {
+		t.Errorf("logged %q, want a warning", rec.lines)
+	}
+
+	// Plans return them as well, and log them to their own logger.
+	rec.lines = nil
+	own := &recorder{}
+	p, err := Plan(ctx, Options{Modules: []*Module{mod}, Patterns: []string{"csrc/*.c", "csrc"}, Logger: own})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(own.lines) != 2 || len(rec.lines) != 0 {
+		t.Errorf("Plan logged %q, and %q to the logger of ctx, want both warnings to its own", own.lines, rec.lines)
+	}
+	if got := warningCodes(p.Warnings); !reflect.DeepEqual(got, []string{"symlink-skipped example.com/foo csrc/b.c"}) {
+		t.Errorf("Warnings = %q, want the symlink skipped once", got)
+	}
+}
@@ -0,0 +1,142 @@
+package vendoring
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// File is a file of a module to vendor.
+type File struct {
+	Name     string   // slash separated, relative to the module root
+	Patterns []string // copy patterns matching the file, "" matches everything
+	Package  string   // import path of the entry of Module.Packages the file belongs to
+
+	// Entry is the file as found walking the module, nil if unknown. It
+	// is a symlink when the file is one.
+	Entry fs.DirEntry
+}
+
+// Match returns the files of mod within its packages matching the copy
+// patterns, and passing the filters, sorted by name. An empty pattern
+// matches every file, patterns matching directories match everything they
+// contain. Symlinks are followed within the module, those leading outside
+// of it are skipped. Matching stops with the error of ctx once it is done.
+func Match(ctx context.Context, mod *Module, patterns []string, filters ...Filter) ([]*File, error) {
+	return match(ctx, mod, patterns, filters, nil, nil)
+}
+
+// match is Match, calling the callbacks of opts unless nil and adding the
+// warnings to ws.
+func match(ctx context.Context, mod *Module, patterns []string, filters []Filter, opts *Options, ws *warnings) ([]*File, error) {
+	log := loggerOf(ctx)
+	ctx = plan.WithSkip(ctx, func(name, msg string) {
+		log.Warn(msg, "module", mod.Path, "file", name)
+		opts.skip(mod, name, "resolves outside of module root")
+		opts.warning(mod, msg)
+		ws.add(Warning{Code: SymlinkSkipped, Module: mod.Path, File: name, Message: msg})
+	})
+	pkgs := plan.NewPackages(mod.Path, mod.Packages)
+	keep := func(name string) bool {
+		return pkgs.Of(name) != ""
+	}
+	matches, err := plan.Glob(ctx, mod.FS, patterns, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*File{}
+	add := func(f plan.File, pat string) {
+		file, ok := byName[f.Name]
+		if !ok {
+			file = &File{Name: f.Name, Package: pkgs.Of(f.Name), Entry: f.Entry}
+			if file.Package == "" {
+				return
+			}
+			byName[f.Name] = file
+		}
+		// A pattern matching a directory and files within it adds them
+		// twice.
+		if n := len(file.Patterns); n == 0 || file.Patterns[n-1] != pat {
+			file.Patterns = append(file.Patterns, pat)
+		}
+		if file.Entry == nil {
+			file.Entry = f.Entry
+		}
+	}
+	for i, pat := range patterns {
+		for _, f := range matches[i] {
+			// The walk doesn't follow symlinks, whatever else it found is
+			// within the module.
+			isDir := f.Entry != nil && f.Entry.IsDir()
+			if f.Entry == nil || f.Entry.Type()&fs.ModeSymlink != 0 {
+				_, ok, err := plan.Resolve(mod.FS, f.Name)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					msg := fmt.Sprintf("skipping %s, it resolves outside of module root %s", plan.Display(mod.FS, f.Name), plan.Display(mod.FS, "."))
+					log.Warn(msg, "module", mod.Path, "file", f.Name)
+					opts.skip(mod, f.Name, "resolves outside of module root")
+					opts.warning(mod, msg)
+					ws.add(Warning{Code: SymlinkSkipped, Module: mod.Path, File: f.Name, Message: msg})
+					continue
+				}
+				info, err := fs.Stat(mod.FS, f.Name)
+				if err != nil {
+					return nil, err
+				}
+				isDir = info.IsDir()
+			}
+
+			// Vendor files only, directories matched by a pattern are
+			// expanded into everything they contain.
+			if !isDir {
+				add(f, pat)
+				continue
+			}
+			files, err := plan.WalkFiles(ctx, mod.FS, f.Name, keep)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				add(f, pat)
+			}
+		}
+	}
+
+	files := make([]*File, 0, len(byName))
+	for _, f := range byName {
+		if len(filters) > 0 {
+			info, err := f.Info(mod.FS)
+			if err != nil {
+				return nil, err
+			}
+			switch Filters(filters).decide(*mod, f.Name, info) {
+			case tooLarge:
+				ws.add(Warning{Code: FileTooLarge, Module: mod.Path, File: f.Name,
+					Message: fmt.Sprintf("skipping %s, it is larger than the size limit (%d bytes)", plan.Display(mod.FS, f.Name), info.Size())})
+				fallthrough
+			case Exclude:
+				opts.skip(mod, f.Name, "excluded by a filter")
+				continue
+			}
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// Info returns the FileInfo of the file within fsys, following symlinks.
+func (f *File) Info(fsys fs.FS) (fs.FileInfo, error) {
+	if f.Entry != nil && f.Entry.Type()&fs.ModeSymlink == 0 {
+		return f.Entry.Info()
+	}
+	return fs.Stat(fsys, f.Name)
+}
@@ -0,0 +1,180 @@
+package vendoring
+
+import (
+	"context"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testModule has C sources in a subdirectory of the root package, and in a
+// package of its own.
+func testModule() *Module {
+	return &Module{
+		Path:     "example.com/foo",
+		Version:  "v1.0.0",
+		Packages: []string{"example.com/foo", "example.com/foo/lib"},
+		FS: fstest.MapFS{
+			"go.mod":     {Data: []byte("module example.com/foo\n")},
+			"foo.go":     {Data: []byte("package foo\n")},
+			"csrc/a.c":   {Data: []byte("int a;\n")},
+			"csrc/a.h":   {Data: []byte("extern int a;\n")},
+			"csrc/big.c": {Data: []byte(strings.Repeat("x", 100))},
+			"lib/lib.go": {Data: []byte("package lib\n")},
+			"lib/lib.c":  {Data: []byte("int lib;\n")},
+		},
+	}
+}
+
+func fileNames(files []*File) []string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestMatch(t *testing.T) {
+	mod := testModule()
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"**/*.c"}, []string{"csrc/a.c", "csrc/big.c", "lib/lib.c"}},
+		{[]string{"csrc"}, []string{"csrc/a.c", "csrc/a.h", "csrc/big.c"}},
+		{[]string{"csrc/*.h", "lib/*.c"}, []string{"csrc/a.h", "lib/lib.c"}},
+		{[]string{"nothing/*"}, []string{}},
+	}
+	for _, tt := range tests {
+		files, err := Match(context.Background(), mod, tt.patterns)
+		if err != nil {
+			t.Errorf("Match(%q): %v", tt.patterns, err)
+			continue
+		}
+		if got := fileNames(files); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPackages(t *testing.T) {
+	mod := testModule()
+	mod.Packages = []string{"example.com/foo/lib"}
+	files, err := Match(context.Background(), mod, []string{"**/*.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "lib/lib.c" || files[0].Package != "example.com/foo/lib" {
+		t.Errorf("Match = %+v, want lib/lib.c of example.com/foo/lib", files)
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	files, err := Match(context.Background(), testModule(), []string{"csrc", "csrc/*.c", "**/*.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"csrc/a.c":   {"csrc", "csrc/*.c", "**/*.c"},
+		"csrc/a.h":   {"csrc"},
+		"csrc/big.c": {"csrc", "csrc/*.c", "**/*.c"},
+		"lib/lib.c":  {"**/*.c"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("Match = %q, want %d files", fileNames(files), len(want))
+	}
+	for _, f := range files {
+		if !reflect.DeepEqual(f.Patterns, want[f.Name]) {
+			t.Errorf("patterns of %s = %q, want %q", f.Name, f.Patterns, want[f.Name])
+		}
+		if f.Entry == nil {
+			t.Errorf("%s has no entry", f.Name)
+		}
+	}
+}
+
+func TestMatchFilters(t *testing.T) {
+	mod := testModule()
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    []string
+	}{
+		{"none", nil, []string{"csrc/a.c", "csrc/big.c", "lib/lib.c"}},
+		{"max size", []Filter{MaxSize(10)}, []string{"csrc/a.c", "lib/lib.c"}},
+		{"module included first", []Filter{Modules("example.com", Include), MaxSize(10)}, []string{"csrc/a.c", "csrc/big.c", "lib/lib.c"}},
+		{"other module", []Filter{Modules("example.com/bar", Include), MaxSize(10)}, []string{"csrc/a.c", "lib/lib.c"}},
+		{"module excluded", []Filter{Modules("example.com/foo", Exclude)}, []string{}},
+		{"by name", []Filter{func(mod Module, name string, info fs.FileInfo) Decision {
+			if strings.HasPrefix(name, "lib/") {
+				return Exclude
+			}
+			return Default
+		}}, []string{"csrc/a.c", "csrc/big.c"}},
+	}
+	for _, tt := range tests {
+		files, err := Match(context.Background(), mod, []string{"**/*.c"}, tt.filters...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := fileNames(files); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Match = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Match(ctx, testModule(), []string{"**/*.c"}); err != context.Canceled {
+		t.Errorf("Match with a canceled context returned %v, want context.Canceled", err)
+	}
+}
+
+func TestFiltersKeep(t *testing.T) {
+	mod := Module{Path: "example.com/foo"}
+	info, err := fs.Stat(fstest.MapFS{"a": {Data: []byte("abc")}}, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decide := func(d Decision) Filter {
+		return func(Module, string, fs.FileInfo) Decision { return d }
+	}
+	tests := []struct {
+		filters Filters
+		want    bool
+	}{
+		{nil, true},
+		{Filters{decide(Default)}, true},
+		{Filters{decide(Exclude)}, false},
+		{Filters{decide(Default), decide(Exclude)}, false},
+		{Filters{decide(Include), decide(Exclude)}, true},
+		{Filters{decide(Exclude), decide(Include)}, false},
+		{Filters{MaxSize(3)}, true},
+		{Filters{MaxSize(2)}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.filters.Keep(mod, "a", info); got != tt.want {
+			t.Errorf("%d: Keep = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestModuleString(t *testing.T) {
+	tests := []struct {
+		mod  Module
+		want string
+	}{
+		{Module{Path: "example.com/foo", Version: "v1.0.0"}, "example.com/foo v1.0.0"},
+		{Module{Path: "example.com/foo", Version: "v1.0.0", Replace: "../foo"}, "example.com/foo v1.0.0 => ../foo"},
+		{Module{Path: "example.com/foo", Version: "v1.0.0", Replace: "example.com/fork", ReplaceVersion: "v1.1.0"}, "example.com/foo v1.0.0 => example.com/fork v1.1.0"},
+	}
+	for _, tt := range tests {
+		if got := tt.mod.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package vendoring
+
+import "io/fs"
+
+// Module is a module whose files are vendored.
+type Module struct {
+	Path    string
+	Version string
+
+	// Replace is the replacement of the module, if any. ReplaceVersion is
+	// empty when it is a local directory.
+	Replace        string
+	ReplaceVersion string
+
+	// Packages are the import paths of the directories files are vendored
+	// from, including their subdirectories without a package of their own.
+	// The module path itself stands for the whole module.
+	Packages []string
+
+	// FS holds the files of the module, or of its replacement: the module
+	// cache, a module zip or, in tests, an fstest.MapFS.
+	FS fs.FS
+}
+
+// String describes the module along with its replacement, if any.
+func (m Module) String() string {
+	s := m.Path + " " + m.Version
+	if m.Replace != "" {
+		s += " => " + m.Replace
+		if m.ReplaceVersion != "" {
+			s += " " + m.ReplaceVersion
+		}
+	}
+	return s
+}
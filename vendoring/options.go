@@ -0,0 +1,234 @@
+package vendoring
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// Options are the modules Plan vendors files from, and which files, or
+// those Verify compares vendored files with. NewOptions sets them from
+// functional options, the With functions, for callers to only mention what
+// they need.
+type Options struct {
+	Modules  []*Module
+	Patterns []string // copy patterns, see Match
+	Excludes []string // patterns, as the copy patterns, of files left out whatever the filters
+	Filters  []Filter
+
+	// ModuleFilter, if set, tells which of Modules to vendor files from,
+	// say as an allowlist service decides.
+	ModuleFilter func(mod Module) bool
+
+	// Destination is where Run writes the files.
+	Destination Destination
+
+	// Concurrency is how many modules Plan matches in parallel, one when
+	// not set.
+	Concurrency int
+
+	// ResolveCollisions lets two modules write the same destination when
+	// their files have the same content: Plan copies that of the first with
+	// a CollisionResolved warning, rather than failing as the CLI does.
+	ResolveCollisions bool
+
+	// Dir is the output directory whose manifest Verify checks.
+	Dir string
+
+	// Logger, if set, gets the warnings and progress of Plan and Execute,
+	// see ContextWithLogger.
+	Logger Logger
+
+	// Callbacks, if set, tell how Plan and Execute progress, for callers
+	// to drive progress UIs and metrics. Those of a run are never called
+	// concurrently.
+	OnModuleStart func(mod *Module)                      // Plan starts matching the files of mod, Execute copying them
+	OnFileCopied  func(op *Op)                           // Execute wrote the file of op
+	OnSkip        func(mod *Module, name, reason string) // a file matched by the patterns isn't vendored
+	OnWarning     func(mod *Module, msg string)          // as logged, see Logger
+
+	// callbackMu serializes the callbacks of a run, whose modules are
+	// walked in parallel. NewOptions sets it, Plan unless set.
+	callbackMu *sync.Mutex
+}
+
+// call calls fn, once the callbacks called before returned.
+func (o *Options) call(fn func()) {
+	if o.callbackMu != nil {
+		o.callbackMu.Lock()
+		defer o.callbackMu.Unlock()
+	}
+	fn()
+}
+
+func (o *Options) moduleStart(mod *Module) {
+	if o != nil && o.OnModuleStart != nil {
+		o.call(func() { o.OnModuleStart(mod) })
+	}
+}
+
+func (o *Options) fileCopied(op *Op) {
+	if o != nil && o.OnFileCopied != nil {
+		o.call(func() { o.OnFileCopied(op) })
+	}
+}
+
+func (o *Options) skip(mod *Module, name, reason string) {
+	if o != nil && o.OnSkip != nil {
+		o.call(func() { o.OnSkip(mod, name, reason) })
+	}
+}
+
+func (o *Options) warning(mod *Module, msg string) {
+	if o != nil && o.OnWarning != nil {
+		o.call(func() { o.OnWarning(mod, msg) })
+	}
+}
+
+// selected returns the modules to vendor from.
+func (o *Options) selected() []*Module {
+	if o.ModuleFilter == nil {
+		return o.Modules
+	}
+	var modules []*Module
+	for _, mod := range o.Modules {
+		if o.ModuleFilter(*mod) {
+			modules = append(modules, mod)
+		}
+	}
+	return modules
+}
+
+func (o *Options) concurrency() int {
+	if o.Concurrency < 1 {
+		return 1
+	}
+	return o.Concurrency
+}
+
+// Option sets one of the Options.
+type Option func(*Options)
+
+// NewOptions returns the Options set by opts, in order.
+func NewOptions(opts ...Option) Options {
+	o := Options{callbackMu: &sync.Mutex{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithModules adds modules to vendor files from.
+func WithModules(modules ...*Module) Option {
+	return func(o *Options) {
+		o.Modules = append(o.Modules, modules...)
+	}
+}
+
+// WithPatterns adds copy patterns.
+func WithPatterns(patterns ...string) Option {
+	return func(o *Options) {
+		o.Patterns = append(o.Patterns, patterns...)
+	}
+}
+
+// WithExcludes adds patterns of files left out, whatever the copy patterns
+// and filters.
+func WithExcludes(patterns ...string) Option {
+	return func(o *Options) {
+		o.Excludes = append(o.Excludes, patterns...)
+	}
+}
+
+// WithFilters adds filters, run after those added before.
+func WithFilters(filters ...Filter) Option {
+	return func(o *Options) {
+		o.Filters = append(o.Filters, filters...)
+	}
+}
+
+// WithModuleFilter leaves out the modules keep returns false for, along
+// with those the module filters added before leave out.
+func WithModuleFilter(keep func(mod Module) bool) Option {
+	return func(o *Options) {
+		prev := o.ModuleFilter
+		o.ModuleFilter = func(mod Module) bool {
+			return (prev == nil || prev(mod)) && keep(mod)
+		}
+	}
+}
+
+// WithDestination sets where Run writes the files.
+func WithDestination(dst Destination) Option {
+	return func(o *Options) {
+		o.Destination = dst
+	}
+}
+
+// WithConcurrency sets how many modules are matched in parallel.
+func WithConcurrency(n int) Option {
+	return func(o *Options) {
+		o.Concurrency = n
+	}
+}
+
+// WithDir sets the output directory Verify checks.
+func WithDir(dir string) Option {
+	return func(o *Options) {
+		o.Dir = dir
+	}
+}
+
+// WithLogger sets where the warnings and progress of the run are reported.
+func WithLogger(l Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// excludeMatching excludes the files matching any of the patterns, or
+// within a directory matching one, as copy patterns match.
+func excludeMatching(patterns []plan.Matcher) Filter {
+	return func(mod Module, name string, info fs.FileInfo) Decision {
+		for dir := name; dir != "."; dir = path.Dir(dir) {
+			for _, m := range patterns {
+				if m == nil || m.Match(dir) {
+					return Exclude
+				}
+			}
+		}
+		return Default
+	}
+}
+
+// ErrNoDestination is returned by Run without WithDestination.
+var ErrNoDestination = errors.New("no destination to write the files to")
+
+// Run plans vendoring the files opts select, see Plan, and writes them to
+// the destination, which is closed once written. It returns the executed
+// plan. Files failing to copy don't stop the others: the destination is
+// closed with those copied, and Run returns the plan along with the Errors.
+func Run(ctx context.Context, opts ...Option) (*CopyPlan, error) {
+	o := NewOptions(opts...)
+	if o.Destination == nil {
+		return nil, ErrNoDestination
+	}
+	p, err := Plan(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	err = Execute(ctx, p, o.Destination)
+	var failures Errors
+	if err != nil && !errors.As(err, &failures) {
+		_ = o.Destination.Close()
+		return nil, err
+	}
+	if err := o.Destination.Close(); err != nil {
+		return nil, err
+	}
+	return p, err
+}
@@ -0,0 +1,101 @@
+package vendoring
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewOptions(t *testing.T) {
+	mod := testModule()
+	dst := NewMemory()
+	got := NewOptions(
+		WithModules(mod),
+		WithPatterns("**/*.c"),
+		WithPatterns("**/*.h"),
+		WithExcludes("lib"),
+		WithDestination(dst),
+		WithConcurrency(4),
+		WithDir("vendor"),
+	)
+	if len(got.Modules) != 1 || got.Modules[0] != mod || got.Destination != dst {
+		t.Errorf("NewOptions set modules %v and destination %v", got.Modules, got.Destination)
+	}
+	if !reflect.DeepEqual(got.Patterns, []string{"**/*.c", "**/*.h"}) || !reflect.DeepEqual(got.Excludes, []string{"lib"}) || got.Concurrency != 4 || got.Dir != "vendor" {
+		t.Errorf("NewOptions = %+v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"patterns", []Option{WithPatterns("**/*.c", "**/*.h")}, []string{
+			"example.com/bar/csrc/a.c", "example.com/bar/x.c",
+			"example.com/foo/csrc/a.c", "example.com/foo/csrc/a.h", "example.com/foo/csrc/big.c", "example.com/foo/lib/lib.c",
+		}},
+		{"excludes", []Option{WithPatterns("**/*.c", "**/*.h"), WithExcludes("lib", "**/a.*")}, []string{
+			"example.com/bar/x.c", "example.com/foo/csrc/big.c",
+		}},
+		{"excludes before filters", []Option{WithPatterns("csrc"), WithExcludes("**/*.h"), WithFilters(Modules("example.com", Include))}, []string{
+			"example.com/bar/csrc/a.c", "example.com/foo/csrc/a.c", "example.com/foo/csrc/big.c",
+		}},
+	}
+	for _, tt := range tests {
+		dst := NewMemory()
+		opts := append([]Option{
+			WithModules(otherModule("example.com/bar"), testModule()),
+			WithDestination(dst),
+			WithConcurrency(2),
+		}, tt.opts...)
+		p, err := Run(context.Background(), opts...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := dst.Names(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Run wrote %q, want %q", tt.name, got, tt.want)
+		}
+		if got := opDests(p); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Run planned %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	if _, err := Run(context.Background(), WithModules(testModule())); err != ErrNoDestination {
+		t.Errorf("Run without a destination returned %v, want ErrNoDestination", err)
+	}
+}
+
+func TestWithModuleFilter(t *testing.T) {
+	allowed := map[string]bool{"example.com/foo": true, "example.com/bar": true}
+	tests := []struct {
+		name    string
+		filters []Option
+		want    []string
+	}{
+		{"none", nil, []string{"example.com/bar/x.c", "example.com/baz/x.c", "example.com/foo/lib/lib.c"}},
+		{"allowlist", []Option{WithModuleFilter(func(mod Module) bool { return allowed[mod.Path] })}, []string{"example.com/bar/x.c", "example.com/foo/lib/lib.c"}},
+		{"both", []Option{
+			WithModuleFilter(func(mod Module) bool { return allowed[mod.Path] }),
+			WithModuleFilter(func(mod Module) bool { return mod.Version != "v0.1.0" }),
+		}, []string{"example.com/foo/lib/lib.c"}},
+	}
+	for _, tt := range tests {
+		opts := append([]Option{
+			WithModules(otherModule("example.com/bar"), otherModule("example.com/baz"), testModule()),
+			WithPatterns("*.c", "lib/*.c"),
+		}, tt.filters...)
+		p, err := Plan(context.Background(), NewOptions(opts...))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := opDests(p); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Plan = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package vendoring
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/internal/plan"
+	"golang.org/x/sync/errgroup"
+)
+
+// Op copies a file of a module to the destination.
+type Op struct {
+	Module   *Module
+	Src      string   // slash separated, relative to the module root
+	Dest     string   // slash separated, relative to the destination
+	Patterns []string // copy patterns matching the file
+	Package  string   // import path of the package the file belongs to
+	Size     int64
+	Mode     fs.FileMode
+}
+
+// CopyPlan is what Execute writes, one operation per file sorted by
+// destination. Callers are free to inspect it, drop operations or keep it
+// around before executing it.
+type CopyPlan struct {
+	Ops []*Op
+
+	// Warnings are about what Plan left out, or the collisions it resolved.
+	Warnings Warnings
+
+	// Options are those the plan was made with, Execute calls their
+	// callbacks.
+	Options Options
+}
+
+// Plan matches the files of every module of opts the module filter keeps,
+// and plans copying them to the directory of their module path, as `go mod
+// vendor` lays out packages. Modules are free to overlap, but two of them
+// writing the same destination is an error, all such collisions are reported
+// at once, unless ResolveCollisions is set and they would write the same
+// content. Modules failing to match don't stop the others, Plan returns every
+// failure as Errors. Nothing is written.
+func Plan(ctx context.Context, opts Options) (*CopyPlan, error) {
+	ctx = opts.context(ctx)
+	if opts.callbackMu == nil {
+		opts.callbackMu = &sync.Mutex{}
+	}
+	excludes, err := plan.Compile(opts.Excludes)
+	if err != nil {
+		return nil, fmt.Errorf("%w - invalid exclude pattern", err)
+	}
+	filters := opts.Filters
+	if len(excludes) > 0 {
+		filters = append([]Filter{excludeMatching(excludes)}, filters...)
+	}
+
+	// Modules are matched in parallel, their files planned in order.
+	modules := opts.selected()
+	matched := make([][]*File, len(modules))
+	errs := make([]error, len(modules))
+	warned := make([]*warnings, len(modules))
+	var g errgroup.Group
+	g.SetLimit(opts.concurrency())
+	for i, mod := range modules {
+		i, mod := i, mod
+		g.Go(func() error {
+			opts.moduleStart(mod)
+			warned[i] = &warnings{}
+			files, err := match(ctx, mod, opts.Patterns, filters, &opts, warned[i])
+			if err != nil {
+				errs[i] = fmt.Errorf("%w - unable to match files of %s", err, mod)
+			}
+			matched[i] = files
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var failures Errors
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	p := &CopyPlan{Options: opts}
+	byDest := map[string]*Op{}
+	var collisions []string
+	matching := map[string]bool{}
+	for i, mod := range modules {
+		p.Warnings = append(p.Warnings, warned[i].sorted()...)
+		for _, f := range matched[i] {
+			for _, pat := range f.Patterns {
+				matching[pat] = true
+			}
+			info, err := f.Info(mod.FS)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("%w - unable to stat %s", err, plan.Display(mod.FS, f.Name)))
+				continue
+			}
+			op := &Op{
+				Module:   mod,
+				Src:      f.Name,
+				Dest:     path.Join(mod.Path, f.Name),
+				Patterns: f.Patterns,
+				Package:  f.Package,
+				Size:     info.Size(),
+				Mode:     info.Mode(),
+			}
+			if prev, ok := byDest[op.Dest]; ok {
+				if opts.ResolveCollisions && sameContent(prev, op) {
+					p.Warnings = append(p.Warnings, Warning{Code: CollisionResolved, Module: mod.Path, File: f.Name,
+						Message: fmt.Sprintf("%s would be written by %s and %s, with the same content, copying that of %s", op.Dest, prev.Module, op.Module, prev.Module)})
+					continue
+				}
+				collisions = append(collisions, fmt.Sprintf("%s would be written by more than one module:\n\t%s from %s\n\t%s from %s",
+					op.Dest, prev.Module, plan.Display(prev.Module.FS, prev.Src), op.Module, plan.Display(op.Module.FS, op.Src)))
+				continue
+			}
+			byDest[op.Dest] = op
+			p.Ops = append(p.Ops, op)
+		}
+	}
+	if len(failures) > 0 {
+		return nil, failures
+	}
+	for _, pat := range opts.Patterns {
+		if !matching[pat] {
+			p.Warnings = append(p.Warnings, Warning{Code: NoMatch, Message: fmt.Sprintf("pattern %q matched no file to vendor", pat)})
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return nil, fmt.Errorf("%s", strings.Join(collisions, "\n"))
+	}
+	sort.Slice(p.Ops, func(i, j int) bool {
+		return p.Ops[i].Dest < p.Ops[j].Dest
+	})
+	return p, nil
+}
+
+// sameContent reports whether the files of a and b have the same content.
+func sameContent(a, b *Op) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	sumA, err := fileSum(a.Module.FS, a.Src, checksum.SHA256)
+	if err != nil {
+		return false
+	}
+	sumB, err := fileSum(b.Module.FS, b.Src, checksum.SHA256)
+	return err == nil && sumA == sumB
+}
+
+// Size returns the total size of the files of p.
+func (p *CopyPlan) Size() int64 {
+	var size int64
+	for _, op := range p.Ops {
+		size += op.Size
+	}
+	return size
+}
+
+// Execute copies the files of p to dst, in order. Files failing to copy
+// don't stop the others, Execute returns them all as Errors of *CopyError.
+// dst is left open, for the caller to close. Once ctx is done, it stops
+// with its error.
+func Execute(ctx context.Context, p *CopyPlan, dst Destination) error {
+	ctx = p.Options.context(ctx)
+	var failures Errors
+	var last *Module
+	for _, op := range p.Ops {
+		if op.Module != last {
+			p.Options.moduleStart(op.Module)
+			last = op.Module
+		}
+		if err := execute(ctx, op, dst); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures = append(failures, &CopyError{Src: plan.Display(op.Module.FS, op.Src), Dst: op.Dest, Err: err})
+			continue
+		}
+		p.Options.fileCopied(op)
+	}
+	return failures.err()
+}
+
+func execute(ctx context.Context, op *Op, dst Destination) error {
+	f, err := op.Module.FS.Open(op.Src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return Write(ctx, dst, op.Dest, info, f)
+}
@@ -0,0 +1,214 @@
+package vendoring
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func opDests(p *CopyPlan) []string {
+	dests := make([]string, 0, len(p.Ops))
+	for _, op := range p.Ops {
+		dests = append(dests, op.Dest)
+	}
+	return dests
+}
+
+// otherModule has a C file of its own, and csrc/a.c as testModule has, for
+// both to collide as the same module path.
+func otherModule(path string) *Module {
+	return &Module{
+		Path:     path,
+		Version:  "v0.1.0",
+		Packages: []string{path},
+		FS: fstest.MapFS{
+			"go.mod":   {Data: []byte("module " + path + "\n")},
+			"x.c":      {Data: []byte("int x;\n")},
+			"csrc/a.c": {Data: []byte("int other;\n")},
+		},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	p, err := Plan(context.Background(), Options{
+		Modules:  []*Module{otherModule("example.com/bar"), testModule()},
+		Patterns: []string{"**/*.c"},
+		Filters:  []Filter{MaxSize(20)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com/bar/csrc/a.c", "example.com/bar/x.c", "example.com/foo/csrc/a.c", "example.com/foo/lib/lib.c"}
+	if got := opDests(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plan = %q, want %q", got, want)
+	}
+	op := p.Ops[3]
+	if op.Module.Path != "example.com/foo" || op.Src != "lib/lib.c" || op.Package != "example.com/foo/lib" || op.Size != 9 || !reflect.DeepEqual(op.Patterns, []string{"**/*.c"}) {
+		t.Errorf("Ops[3] = %+v", op)
+	}
+	if got := p.Size(); got != 7+11+7+9 {
+		t.Errorf("Size() = %d, want %d", got, 7+11+7+9)
+	}
+}
+
+func TestPlanCollisions(t *testing.T) {
+	_, err := Plan(context.Background(), Options{
+		Modules:  []*Module{testModule(), otherModule("example.com/foo")},
+		Patterns: []string{"**/*.c"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "example.com/foo/csrc/a.c would be written by more than one module") {
+		t.Errorf("Plan of overlapping modules returned %v, want a collision", err)
+	}
+
+	// Without ResolveCollisions, the same content collides all the same.
+	fork := otherModule("example.com/foo")
+	fork.FS.(fstest.MapFS)["csrc/a.c"] = &fstest.MapFile{Data: []byte("int a;\n")}
+	_, err = Plan(context.Background(), Options{
+		Modules:  []*Module{testModule(), fork},
+		Patterns: []string{"**/*.c"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "example.com/foo/csrc/a.c would be written by more than one module") {
+		t.Errorf("Plan of modules with the same file returned %v, want a collision", err)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	p, err := Plan(context.Background(), Options{
+		Modules:  []*Module{testModule()},
+		Patterns: []string{"csrc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Callers may leave out what they don't want written.
+	p.Ops = p.Ops[:2]
+
+	dst := NewMemory()
+	if err := Execute(context.Background(), p, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com/foo/csrc/a.c", "example.com/foo/csrc/a.h"}
+	if got := dst.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Execute wrote %q, want %q", got, want)
+	}
+	if got := string(dst.Files["example.com/foo/csrc/a.h"].Data); got != "extern int a;\n" {
+		t.Errorf("a.h = %q", got)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	p := &CopyPlan{Ops: []*Op{{Module: testModule(), Src: "missing.c", Dest: "example.com/foo/missing.c"}}}
+	err := Execute(context.Background(), p, NewMemory())
+	var cerr *CopyError
+	if !errors.As(err, &cerr) || cerr.Dst != "example.com/foo/missing.c" || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Execute of a missing file returned %v, want a CopyError", err)
+	}
+
+	// Failing files don't stop the others.
+	p.Ops = append(p.Ops,
+		&Op{Module: testModule(), Src: "csrc/a.c", Dest: "example.com/foo/csrc/a.c"},
+		&Op{Module: testModule(), Src: "gone.c", Dest: "example.com/foo/gone.c"})
+	dst := NewMemory()
+	err = Execute(context.Background(), p, dst)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || !strings.Contains(err.Error(), "gone.c") {
+		t.Errorf("Execute of two missing files returned %v, want both", err)
+	}
+	if _, ok := dst.Files["example.com/foo/csrc/a.c"]; !ok {
+		t.Errorf("Execute stopped at the first failing file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Ops = p.Ops[1:2]
+	if err := Execute(ctx, p, NewMemory()); err != context.Canceled {
+		t.Errorf("Execute with a canceled context returned %v, want context.Canceled", err)
+	}
+}
+
+func TestPlanCallbacks(t *testing.T) {
+	var events []string
+	opts := Options{
+		Modules:  []*Module{testModule()},
+		Patterns: []string{"csrc/*.c"},
+		Filters:  []Filter{MaxSize(20)},
+		OnModuleStart: func(mod *Module) {
+			events = append(events, "start "+mod.Path)
+		},
+		OnFileCopied: func(op *Op) {
+			events = append(events, "copied "+op.Dest)
+		},
+		OnSkip: func(mod *Module, name, reason string) {
+			events = append(events, "skip "+name+": "+reason)
+		},
+		OnWarning: func(mod *Module, msg string) {
+			events = append(events, "warning "+msg)
+		},
+	}
+	p, err := Plan(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Execute(context.Background(), p, NewMemory()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"start example.com/foo",
+		"skip csrc/big.c: excluded by a filter",
+		"start example.com/foo",
+		"copied example.com/foo/csrc/a.c",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("callbacks = %q, want %q", events, want)
+	}
+}
+
+// The callbacks of one run don't hold up those of another.
+func TestPlanCallbacksApart(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := Plan(context.Background(), Options{
+			Modules:  []*Module{testModule()},
+			Patterns: []string{"csrc/*.c"},
+			OnModuleStart: func(mod *Module) {
+				close(started)
+				<-release
+			},
+		})
+		done <- err
+	}()
+	<-started
+	defer func() {
+		close(release)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	other := make(chan error)
+	go func() {
+		_, err := Plan(context.Background(), Options{
+			Modules:       []*Module{testModule()},
+			Patterns:      []string{"csrc/*.c"},
+			OnModuleStart: func(mod *Module) {},
+		})
+		other <- err
+	}()
+	select {
+	case err := <-other:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Plan waited for the callback of another run")
+	}
+}
@@ -0,0 +1,160 @@
+// Package vendortest builds synthetic projects and module caches from
+// declarative fixtures, for hermetic tests of code embedding the vendoring
+// library, or running modvendor:
+//
+//	fixture := &vendortest.Fixture{Modules: []vendortest.Module{{
+//		Path:    "example.com/foo",
+//		Version: "v1.0.0",
+//		Files:   map[string]string{"foo.go": "package foo\n", "csrc/foo.c": "int foo;\n"},
+//	}}}
+//	plan, err := vendoring.Plan(ctx, vendoring.NewOptions(
+//		vendoring.WithModules(fixture.VendoringModules()...),
+//		vendoring.WithPatterns("**/*.c"),
+//	))
+package vendortest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing/fstest"
+	"unicode"
+
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+// Directories of the fixture filesystem, see Fixture.FS.
+const (
+	ProjectDir  = "project"  // the project vendoring the modules
+	ModCacheDir = "modcache" // the module cache, for $GOMODCACHE
+)
+
+// Module is a module of a fixture.
+type Module struct {
+	Path    string
+	Version string
+
+	// Packages are the packages listed in vendor/modules.txt, the module
+	// path alone when empty.
+	Packages []string
+
+	// Files are the content of the module by slash separated name, a
+	// go.mod is added unless given.
+	Files map[string]string
+}
+
+// Fixture is a project and the modules it vendors.
+type Fixture struct {
+	Path    string // module path of the project, example.com/project when empty
+	Modules []Module
+}
+
+// FS returns the fixture as a filesystem: the project, with its go.mod and
+// vendor/modules.txt, in ProjectDir and the modules extracted in
+// ModCacheDir, as the go command lays them out.
+func (f *Fixture) FS() fstest.MapFS {
+	fsys := fstest.MapFS{}
+	file := func(name, data string) {
+		fsys[name] = &fstest.MapFile{Data: []byte(data), Mode: 0644}
+	}
+
+	var gomod, modtxt strings.Builder
+	fmt.Fprintf(&gomod, "module %s\n\ngo 1.18\n", f.path())
+	if len(f.Modules) > 0 {
+		gomod.WriteString("\nrequire (\n")
+		for _, m := range f.Modules {
+			fmt.Fprintf(&gomod, "\t%s %s\n", m.Path, m.Version)
+			fmt.Fprintf(&modtxt, "# %s %s\n## explicit\n", m.Path, m.Version)
+			for _, pkg := range m.packages() {
+				fmt.Fprintln(&modtxt, pkg)
+			}
+		}
+		gomod.WriteString(")\n")
+	}
+	file(path.Join(ProjectDir, "go.mod"), gomod.String())
+	file(path.Join(ProjectDir, "vendor", "modules.txt"), modtxt.String())
+
+	for _, m := range f.Modules {
+		dir := m.dir()
+		if _, ok := m.Files["go.mod"]; !ok {
+			file(path.Join(dir, "go.mod"), "module "+m.Path+"\n")
+		}
+		for name, data := range m.Files {
+			file(path.Join(dir, name), data)
+		}
+	}
+	return fsys
+}
+
+// VendoringModules returns the modules of the fixture as the vendoring
+// library takes them, their files in memory.
+func (f *Fixture) VendoringModules() []*vendoring.Module {
+	fsys := f.FS()
+	modules := make([]*vendoring.Module, 0, len(f.Modules))
+	for _, m := range f.Modules {
+		sub, err := fs.Sub(fsys, m.dir())
+		if err != nil {
+			panic(err) // the name is valid, fs.Sub only fails for invalid names
+		}
+		modules = append(modules, &vendoring.Module{Path: m.Path, Version: m.Version, Packages: m.packages(), FS: sub})
+	}
+	return modules
+}
+
+// Write writes the fixture to dir, as FS lays it out. Run modvendor in
+// dir/ProjectDir with $GOMODCACHE set to dir/ModCacheDir.
+func (f *Fixture) Write(dir string) error {
+	fsys := f.FS()
+	names := make([]string, 0, len(fsys))
+	for name := range fsys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, fsys[name].Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Fixture) path() string {
+	if f.Path == "" {
+		return "example.com/project"
+	}
+	return f.Path
+}
+
+func (m *Module) packages() []string {
+	if len(m.Packages) == 0 {
+		return []string{m.Path}
+	}
+	return m.Packages
+}
+
+// dir returns the directory of the module within the fixture filesystem.
+func (m *Module) dir() string {
+	return path.Join(ModCacheDir, escape(m.Path)+"@"+escape(m.Version))
+}
+
+// escape escapes upper case letters as the module cache does, "!" followed
+// by the lower case letter.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
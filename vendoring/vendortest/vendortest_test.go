@@ -0,0 +1,89 @@
+package vendortest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ansoda/modvendor/modtxt"
+	"github.com/ansoda/modvendor/vendoring"
+)
+
+func testFixture() *Fixture {
+	return &Fixture{Modules: []Module{
+		{Path: "example.com/foo", Version: "v1.0.0", Files: map[string]string{
+			"foo.go":     "package foo\n",
+			"csrc/foo.c": "int foo;\n",
+		}},
+		{Path: "github.com/Org/Bar", Version: "v0.2.0", Packages: []string{"github.com/Org/Bar/lib"}, Files: map[string]string{
+			"go.mod":     "module github.com/Org/Bar\n\ngo 1.20\n",
+			"bar.c":      "int bar;\n",
+			"lib/lib.go": "package lib\n",
+			"lib/lib.c":  "int lib;\n",
+		}},
+	}}
+}
+
+func TestFixtureFS(t *testing.T) {
+	fsys := testFixture().FS()
+	for _, name := range []string{
+		"project/go.mod",
+		"project/vendor/modules.txt",
+		"modcache/example.com/foo@v1.0.0/go.mod",
+		"modcache/example.com/foo@v1.0.0/csrc/foo.c",
+		"modcache/github.com/!org/!bar@v0.2.0/lib/lib.c",
+	} {
+		if _, ok := fsys[name]; !ok {
+			t.Errorf("FS has no %s", name)
+		}
+	}
+	if got := string(fsys["modcache/github.com/!org/!bar@v0.2.0/go.mod"].Data); got != "module github.com/Org/Bar\n\ngo 1.20\n" {
+		t.Errorf("go.mod given by the fixture = %q", got)
+	}
+
+	modules, err := modtxt.Parse(bytes.NewReader(fsys["project/vendor/modules.txt"].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 2 || modules[0].Path != "example.com/foo" || !modules[0].Explicit ||
+		!reflect.DeepEqual(modules[0].Packages, []string{"example.com/foo"}) ||
+		!reflect.DeepEqual(modules[1].Packages, []string{"github.com/Org/Bar/lib"}) {
+		t.Errorf("modules.txt parses as %+v", modules)
+	}
+}
+
+func TestFixtureVendoringModules(t *testing.T) {
+	p, err := vendoring.Plan(context.Background(), vendoring.NewOptions(
+		vendoring.WithModules(testFixture().VendoringModules()...),
+		vendoring.WithPatterns("**/*.c"),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dests []string
+	for _, op := range p.Ops {
+		dests = append(dests, op.Dest)
+	}
+	// bar.c isn't within the vendored packages of its module.
+	want := []string{"example.com/foo/csrc/foo.c", "github.com/Org/Bar/lib/lib.c"}
+	if !reflect.DeepEqual(dests, want) {
+		t.Errorf("Plan = %q, want %q", dests, want)
+	}
+}
+
+func TestFixtureWrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := testFixture().Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ModCacheDir, "github.com", "!org", "!bar@v0.2.0", "lib", "lib.c"))
+	if err != nil || string(data) != "int lib;\n" {
+		t.Errorf("lib.c = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ProjectDir, "vendor", "modules.txt")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,135 @@
+package vendoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansoda/modvendor/internal/checksum"
+	"github.com/ansoda/modvendor/internal/copyer"
+)
+
+// ManifestName is the file, within the output directory, recording what
+// modvendor copied and the checksums of the files.
+const ManifestName = "modvendor.json"
+
+// ProblemKind is what is wrong with a vendored file.
+type ProblemKind string
+
+const (
+	// Missing files are recorded in the manifest, but not in the output
+	// directory anymore.
+	Missing ProblemKind = "missing"
+
+	// Modified files don't have the checksum recorded in the manifest.
+	Modified ProblemKind = "modified"
+
+	// Differs is a file whose content differs from that of its module, as
+	// given to Verify.
+	Differs ProblemKind = "differs"
+)
+
+// Problem is a vendored file failing verification.
+type Problem struct {
+	Kind   ProblemKind
+	Module string // path of the module the file was copied from
+	File   string // slash separated, relative to the output directory
+}
+
+func (p Problem) String() string {
+	return string(p.Kind) + ": " + p.File
+}
+
+// Report is the outcome of Verify.
+type Report struct {
+	Modules  int // recorded in the manifest
+	Files    int // checked
+	Problems []Problem
+}
+
+// OK reports whether every file checked passed.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// manifest is what Verify reads of the manifest.
+type manifest struct {
+	Modules []struct {
+		Path  string `json:"path"`
+		Files []struct {
+			Path string `json:"path"`
+			Sum  string `json:"sha256"` // prefixed with the algorithm unless sha256
+		} `json:"files"`
+	} `json:"modules"`
+}
+
+// Verify checks that every file the manifest of opts.Dir records exists and
+// has the recorded checksum. Files of the modules of opts, by module path,
+// are compared with their source as well. A missing manifest is an error
+// for errors.Is(err, fs.ErrNotExist), other failures are problems of the
+// report. Once ctx is done, Verify stops with its error.
+func Verify(ctx context.Context, opts Options) (*Report, error) {
+	data, err := os.ReadFile(filepath.Join(opts.Dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestName, err)
+	}
+	sources := map[string]*Module{}
+	for _, mod := range opts.Modules {
+		sources[mod.Path] = mod
+	}
+
+	r := &Report{Modules: len(m.Modules)}
+	for _, mm := range m.Modules {
+		for _, f := range mm.Files {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			r.Files++
+			sum, err := fileSum(os.DirFS(opts.Dir), f.Path, checksum.Of(f.Sum))
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				r.Problems = append(r.Problems, Problem{Kind: Missing, Module: mm.Path, File: f.Path})
+				continue
+			case err != nil:
+				return nil, fmt.Errorf("%w - unable to read %s", err, f.Path)
+			case sum != f.Sum:
+				r.Problems = append(r.Problems, Problem{Kind: Modified, Module: mm.Path, File: f.Path})
+				continue
+			}
+
+			mod := sources[mm.Path]
+			if mod == nil {
+				continue
+			}
+			src := strings.TrimPrefix(strings.TrimPrefix(f.Path, mm.Path), "/")
+			if srcSum, err := fileSum(mod.FS, src, checksum.Of(f.Sum)); err != nil || srcSum != sum {
+				r.Problems = append(r.Problems, Problem{Kind: Differs, Module: mm.Path, File: f.Path})
+			}
+		}
+	}
+	return r, nil
+}
+
+// fileSum returns the checksum of the content of name within fsys, by the
+// algorithm.
+func fileSum(fsys fs.FS, name string, a checksum.Algorithm) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := a.New()
+	if _, err := copyer.Buffered(h, f); err != nil {
+		return "", err
+	}
+	return a.Sum(h), nil
+}
@@ -0,0 +1,114 @@
+package vendoring
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func sum(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+// writeVendored writes the files to dir, and a manifest recording them as
+// copied from example.com/foo with the sums of want.
+func writeVendored(t *testing.T, dir string, files, want map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var records string
+	for name, data := range want {
+		if records != "" {
+			records += ","
+		}
+		records += fmt.Sprintf(`{"path": %q, "sha256": %q}`, name, sum(data))
+	}
+	manifest := `{"modules": [{"path": "example.com/foo", "version": "v1.0.0", "files": [` + records + `]}]}`
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeVendored(t, dir, map[string]string{
+		"example.com/foo/csrc/a.c":  "int a;\n",
+		"example.com/foo/csrc/a.h":  "changed\n",
+		"example.com/foo/lib/lib.c": "int lib;\n",
+	}, map[string]string{
+		"example.com/foo/csrc/a.c":   "int a;\n",
+		"example.com/foo/csrc/a.h":   "extern int a;\n",
+		"example.com/foo/csrc/big.c": "gone",
+		"example.com/foo/lib/lib.c":  "int lib;\n",
+	})
+
+	report, err := Verify(context.Background(), Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Problem{
+		{Kind: Missing, Module: "example.com/foo", File: "example.com/foo/csrc/big.c"},
+		{Kind: Modified, Module: "example.com/foo", File: "example.com/foo/csrc/a.h"},
+	}
+	if report.OK() || report.Files != 4 || report.Modules != 1 || !sameProblems(report.Problems, want) {
+		t.Errorf("Verify = %+v, want problems %v", report, want)
+	}
+
+	// Compared with the module, lib.c differs from its source.
+	mod := testModule()
+	mod.FS.(fstest.MapFS)["lib/lib.c"].Data = []byte("int changed;\n")
+	report, err = Verify(context.Background(), Options{Dir: dir, Modules: []*Module{mod}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, Problem{Kind: Differs, Module: "example.com/foo", File: "example.com/foo/lib/lib.c"})
+	if !sameProblems(report.Problems, want) {
+		t.Errorf("Verify with modules = %v, want %v", report.Problems, want)
+	}
+	if got := want[2].String(); got != "differs: example.com/foo/lib/lib.c" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	if _, err := Verify(context.Background(), Options{Dir: t.TempDir()}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Verify without a manifest returned %v, want fs.ErrNotExist", err)
+	}
+
+	dir := t.TempDir()
+	writeVendored(t, dir, map[string]string{"example.com/foo/a.c": "a"}, map[string]string{"example.com/foo/a.c": "a"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Verify(ctx, Options{Dir: dir}); err != context.Canceled {
+		t.Errorf("Verify with a canceled context returned %v, want context.Canceled", err)
+	}
+}
+
+// sameProblems compares problems regardless of order, writeVendored records
+// files in map order.
+func sameProblems(got, want []Problem) bool {
+	set := func(problems []Problem) map[Problem]bool {
+		m := map[Problem]bool{}
+		for _, p := range problems {
+			m[p] = true
+		}
+		return m
+	}
+	return len(got) == len(want) && reflect.DeepEqual(set(got), set(want))
+}
@@ -0,0 +1,91 @@
+package vendoring
+
+import (
+	"sort"
+	"sync"
+)
+
+// WarningCode tells warnings apart, for callers to turn some of them into
+// errors by policy.
+type WarningCode string
+
+const (
+	// NoMatch is a copy pattern matching no file to vendor, of any module.
+	NoMatch WarningCode = "no-match"
+
+	// SymlinkSkipped is a symlink left out as it resolves outside of its
+	// module.
+	SymlinkSkipped WarningCode = "symlink-skipped"
+
+	// FileTooLarge is a file left out by MaxSize.
+	FileTooLarge WarningCode = "file-too-large"
+
+	// CollisionResolved is a file more than one module would write, with the
+	// same content, only copied from the first of them as ResolveCollisions
+	// allows.
+	CollisionResolved WarningCode = "collision-resolved"
+)
+
+// Warning is something Plan left out or decided on its own, vendoring went
+// on regardless.
+type Warning struct {
+	Code    WarningCode
+	Module  string // path of the module, empty for warnings about no module in particular
+	File    string // slash separated, relative to the module root, if about a file
+	Message string
+}
+
+func (w Warning) String() string {
+	return string(w.Code) + ": " + w.Message
+}
+
+// Warnings are those of a plan, by module then file.
+type Warnings []Warning
+
+// Of returns the warnings having one of the codes, say to fail on:
+//
+//	if w := p.Warnings.Of(vendoring.NoMatch, vendoring.SymlinkSkipped); len(w) > 0 {
+//		return fmt.Errorf("vendoring: %s", w[0])
+//	}
+func (w Warnings) Of(codes ...WarningCode) Warnings {
+	var of Warnings
+	for _, warning := range w {
+		for _, code := range codes {
+			if warning.Code == code {
+				of = append(of, warning)
+				break
+			}
+		}
+	}
+	return of
+}
+
+// warnings collects the warnings of a module, once per file however many
+// patterns match it, walks may add them concurrently. A nil *warnings drops
+// them.
+type warnings struct {
+	mu   sync.Mutex
+	list Warnings
+}
+
+func (w *warnings) add(warning Warning) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, prev := range w.list {
+		if prev.Code == warning.Code && prev.File == warning.File {
+			return
+		}
+	}
+	w.list = append(w.list, warning)
+}
+
+// sorted returns the warnings sorted by file.
+func (w *warnings) sorted() Warnings {
+	sort.SliceStable(w.list, func(i, j int) bool {
+		return w.list[i].File < w.list[j].File
+	})
+	return w.list
+}
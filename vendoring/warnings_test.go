@@ -0,0 +1,67 @@
+package vendoring
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func warningCodes(w Warnings) []string {
+	codes := make([]string, 0, len(w))
+	for _, warning := range w {
+		codes = append(codes, string(warning.Code)+" "+warning.Module+" "+warning.File)
+	}
+	return codes
+}
+
+func TestPlanWarnings(t *testing.T) {
+	// The fork has csrc/a.c as testModule has it.
+	fork := otherModule("example.com/foo")
+	fork.FS.(fstest.MapFS)["csrc/a.c"] = &fstest.MapFile{Data: []byte("int a;\n")}
+	p, err := Plan(context.Background(), Options{
+		Modules:  []*Module{testModule(), fork},
+		Patterns: []string{"**/*.c", "nothing/*"},
+		Filters:  []Filter{MaxSize(20)},
+
+		ResolveCollisions: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"file-too-large example.com/foo csrc/big.c",
+		"collision-resolved example.com/foo csrc/a.c",
+		"no-match  ",
+	}
+	if got := warningCodes(p.Warnings); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings = %q, want %q", got, want)
+	}
+	if got := p.Warnings[2].String(); got != `no-match: pattern "nothing/*" matched no file to vendor` {
+		t.Errorf("String() = %q", got)
+	}
+	if got := opDests(p); !reflect.DeepEqual(got, []string{"example.com/foo/csrc/a.c", "example.com/foo/lib/lib.c", "example.com/foo/x.c"}) {
+		t.Errorf("Plan = %q", got)
+	}
+	if p.Ops[0].Module == fork {
+		t.Errorf("the collision was resolved copying the file of the second module")
+	}
+}
+
+func TestWarningsOf(t *testing.T) {
+	w := Warnings{{Code: NoMatch}, {Code: SymlinkSkipped, File: "a"}, {Code: FileTooLarge}, {Code: SymlinkSkipped, File: "b"}}
+	tests := []struct {
+		codes []WarningCode
+		want  Warnings
+	}{
+		{nil, nil},
+		{[]WarningCode{CollisionResolved}, nil},
+		{[]WarningCode{SymlinkSkipped}, Warnings{w[1], w[3]}},
+		{[]WarningCode{NoMatch, FileTooLarge}, Warnings{w[0], w[2]}},
+	}
+	for _, tt := range tests {
+		if got := w.Of(tt.codes...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Of(%q) = %v, want %v", tt.codes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansoda/modvendor/internal/copyer"
+	"github.com/ansoda/modvendor/internal/plan"
+)
+
+// modZipPath returns the location of the module zip in the download cache,
+// $GOMODCACHE/cache/download/<path>/@v/<version>.zip.
+func modZipPath(importPath, version string) string {
+	return filepath.Join(modCacheDir(), "cache", "download", normString(importPath), "@v", normString(version)+".zip")
+}
+
+// openModZip opens the module zip of mod, its filesystem is that of the
+// module extracted.
+func openModZip(mod *Mod) (*zip.ReadCloser, fs.FS, error) {
+	zr, err := zip.OpenReader(mod.Zip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Every file in a module zip is prefixed with "<path>@<version>/".
+	prefix := zipPrefix(mod)
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		rel := f.Name[len(prefix):]
+		if rel == "" || strings.Contains("/"+rel+"/", "/../") {
+			_ = zr.Close()
+			return nil, nil, fmt.Errorf("%s: invalid file name %q", mod.Zip, f.Name)
+		}
+	}
+	fsys, err := fs.Sub(zr, strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		_ = zr.Close()
+		return nil, nil, err
+	}
+	return zr, fsys, nil
+}
+
+func zipPrefix(mod *Mod) string {
+	if mod.SourceVersion != "" {
+		return mod.SourcePath + "@" + mod.SourceVersion + "/"
+	}
+	return mod.ImportPath + "@" + mod.Version + "/"
+}
+
+// zipMayMatch reports whether the extracted module mod may have files,
+// or directories, ending with one of suffixes. Without suffixes, or without
+// the zip of the module in the download cache, it may.
+func zipMayMatch(mod *Mod, suffixes []string) bool {
+	if len(suffixes) == 0 {
+		return true
+	}
+	path, version := mod.ImportPath, mod.Version
+	if mod.SourcePath != "" {
+		path, version = mod.SourcePath, mod.SourceVersion
+	}
+	if version == "" {
+		return true
+	}
+	zr, err := zip.OpenReader(modZipPath(path, version))
+	if err != nil {
+		return true
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+	for _, f := range zr.File {
+		for _, elem := range strings.Split(f.Name, "/") {
+			for _, suffix := range suffixes {
+				if plan.HasSuffix(elem, suffix) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// extractFile writes the content of r, a file described by info, to dst,
+// and to tee if not nil.
+func extractFile(r io.Reader, info fs.FileInfo, dst string, tee io.Writer) error {
+	copyer.Ops.Wait(1)
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm()|0644)
+	if err != nil {
+		return err
+	}
+	src := copyer.Throttled(r)
+	if tee != nil {
+		src = io.TeeReader(src, tee)
+	}
+	if _, err := copyer.Buffered(w, src); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// As copies from the module cache, see copyEntry.
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// hashZip computes the "h1:" hash of a module zip, see hashDir.
+func hashZip(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = zr.Close()
+	}()
+
+	var files []string
+	index := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		files = append(files, f.Name)
+		index[f.Name] = f
+	}
+	return hash1(files, func(name string) (io.ReadCloser, error) {
+		return index[name].Open()
+	})
+}